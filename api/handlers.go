@@ -0,0 +1,625 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/router"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// maxBodySize limits the size of request bodies.
+const maxBodySize = 1 << 20
+
+// Group is a target group as returned by the API.
+type Group struct {
+	ID       string            `json:"id"`
+	File     string            `json:"file"`
+	Name     string            `json:"name,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+	Jobs     []string          `json:"jobs"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Targets  []targets.Target  `json:"targets"`
+}
+
+// GroupRequest is the body used to create or replace a group. File is the name of the source
+// file to add a new group to and defaults to the first source file.
+type GroupRequest struct {
+	File     string            `json:"file,omitempty"`
+	Name     string            `json:"name"`
+	Priority int               `json:"priority,omitempty"`
+	Jobs     []string          `json:"jobs"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Targets  []targets.Target  `json:"targets"`
+}
+
+// GroupPatch is the body used to update a group. Only the fields that are set are changed.
+// Labels are merged into the group labels and a null value removes the label.
+type GroupPatch struct {
+	Name     *string            `json:"name,omitempty"`
+	Priority *int               `json:"priority,omitempty"`
+	Jobs     []string           `json:"jobs,omitempty"`
+	Labels   map[string]*string `json:"labels,omitempty"`
+	Targets  []targets.Target   `json:"targets,omitempty"`
+}
+
+// TargetInfo is a single target as returned by the API. Labels include the group labels. Jobs
+// are the jobs the target is exported for, including jobs whose selector matches it.
+type TargetInfo struct {
+	Address string            `json:"address"`
+	Group   string            `json:"group"`
+	Jobs    []string          `json:"jobs"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// TargetRequest is the body used to add a target to a group.
+type TargetRequest struct {
+	Group   string            `json:"group"`
+	Address string            `json:"address"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// Job is a job as returned by the API.
+type Job struct {
+	Name    string `json:"name"`
+	Targets int    `json:"targets"`
+}
+
+// ErrorResponse is returned for every failed request. Problems lists what failed validation.
+type ErrorResponse struct {
+	Error    string           `json:"error"`
+	Problems targets.Problems `json:"problems,omitempty"`
+}
+
+// apiError is an error with the status code to respond with.
+type apiError struct {
+	status   int
+	err      error
+	problems targets.Problems
+}
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func newAPIError(status int, format string, a ...any) *apiError {
+	return &apiError{status: status, err: fmt.Errorf(format, a...)}
+}
+
+// change loads the sources, calls fn to change them and saves the files fn returns. The targets
+// are exported again after a successful change. Changes are serialized so two requests can not
+// overwrite each other.
+func (a *API) change(fn func(s *sources) ([]string, error)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, err := loadSources(a.server.Config)
+	if err != nil {
+		return err
+	}
+
+	files, err := fn(s)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := s.save(f); err != nil {
+			return err
+		}
+	}
+
+	if a.export == nil {
+		return nil
+	}
+
+	if err := a.export(); err != nil {
+		return fmt.Errorf("sources were saved but the export failed: %w", err)
+	}
+
+	return nil
+}
+
+// read loads the sources for handlers that do not change them.
+func (a *API) read() (*sources, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return loadSources(a.server.Config)
+}
+
+func (a *API) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	resp := ErrorResponse{Error: err.Error()}
+
+	var aerr *apiError
+	if errors.As(err, &aerr) {
+		status = aerr.status
+		resp.Problems = aerr.problems
+	}
+
+	if status == http.StatusInternalServerError {
+		a.server.Logger.Printf("%s %s: %s\n", r.Method, r.RequestURI, err)
+	}
+
+	if err := router.RenderJSON(w, status, resp); err != nil {
+		a.server.Logger.Printf("%s %s: %s\n", r.Method, r.RequestURI, err)
+	}
+}
+
+func (a *API) render(w http.ResponseWriter, r *http.Request, status int, obj any) {
+	if err := router.RenderJSON(w, status, obj); err != nil {
+		a.server.Logger.Printf("%s %s: %s\n", r.Method, r.RequestURI, err)
+	}
+}
+
+// readBody decodes the request body into T and rejects unknown fields.
+func readBody[T any](w http.ResponseWriter, r *http.Request) (T, error) {
+	var obj T
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&obj); err != nil {
+		return obj, newAPIError(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+
+	return obj, nil
+}
+
+// validateGroup returns an error listing the problems with tg.
+func validateGroup(config *core.Config, tg *targets.TargetGroup) error {
+	problems := tg.Validate(config)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &apiError{status: http.StatusBadRequest, err: errors.New("invalid group"), problems: problems}
+}
+
+func newGroup(s *sources, ref groupRef) Group {
+	tg := s.group(ref)
+	return Group{
+		ID:       s.id(ref),
+		File:     filepath.Base(ref.file),
+		Name:     tg.Name,
+		Priority: tg.Priority,
+		Jobs:     tg.Jobs,
+		Labels:   tg.Labels,
+		Targets:  tg.Targets,
+	}
+}
+
+func (a *API) handleListGroups() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.read()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			groups := make([]Group, 0)
+			for _, ref := range s.all() {
+				groups = append(groups, newGroup(s, ref))
+			}
+
+			a.render(w, r, http.StatusOK, groups)
+		})
+}
+
+func (a *API) handleGetGroup() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.read()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			ref, ok := s.find(r.PathValue("id"))
+			if !ok {
+				a.renderError(w, r, newAPIError(http.StatusNotFound, "group not found: %s", r.PathValue("id")))
+				return
+			}
+
+			a.render(w, r, http.StatusOK, newGroup(s, ref))
+		})
+}
+
+func (a *API) handleCreateGroup() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req, err := readBody[GroupRequest](w, r)
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			// New groups must be named so they can be addressed after other groups change.
+			if req.Name == "" {
+				a.renderError(w, r, newAPIError(http.StatusBadRequest, "name is required"))
+				return
+			}
+
+			tg := &targets.TargetGroup{
+				Name:     req.Name,
+				Priority: req.Priority,
+				Jobs:     req.Jobs,
+				Labels:   req.Labels,
+				Targets:  req.Targets,
+			}
+			if err := validateGroup(a.server.Config, tg); err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			var group Group
+			err = a.change(func(s *sources) ([]string, error) {
+				if s.nameTaken(tg.Name, nil) {
+					return nil, newAPIError(http.StatusConflict, "group already exists: %s", tg.Name)
+				}
+
+				file, err := s.sourceFile(req.File)
+				if err != nil {
+					return nil, &apiError{status: http.StatusBadRequest, err: err}
+				}
+
+				ref := s.add(file, tg)
+				group = newGroup(s, ref)
+				return []string{file}, nil
+			})
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			a.render(w, r, http.StatusCreated, group)
+		})
+}
+
+func (a *API) handleReplaceGroup() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req, err := readBody[GroupRequest](w, r)
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			tg := &targets.TargetGroup{
+				Name:     req.Name,
+				Priority: req.Priority,
+				Jobs:     req.Jobs,
+				Labels:   req.Labels,
+				Targets:  req.Targets,
+			}
+			a.updateGroup(w, r, req.File, func(*targets.TargetGroup) *targets.TargetGroup { return tg })
+		})
+}
+
+func (a *API) handlePatchGroup() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			patch, err := readBody[GroupPatch](w, r)
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			a.updateGroup(w, r, "", func(old *targets.TargetGroup) *targets.TargetGroup {
+				tg := &targets.TargetGroup{
+					Name:     old.Name,
+					Priority: old.Priority,
+					Jobs:     old.Jobs,
+					Labels:   maps.Clone(old.Labels),
+					Targets:  old.Targets,
+				}
+
+				if patch.Name != nil {
+					tg.Name = *patch.Name
+				}
+
+				if patch.Priority != nil {
+					tg.Priority = *patch.Priority
+				}
+
+				if patch.Jobs != nil {
+					tg.Jobs = patch.Jobs
+				}
+
+				if patch.Targets != nil {
+					tg.Targets = patch.Targets
+				}
+
+				for k, v := range patch.Labels {
+					if v == nil {
+						delete(tg.Labels, k)
+						continue
+					}
+
+					if tg.Labels == nil {
+						tg.Labels = make(map[string]string)
+					}
+					tg.Labels[k] = *v
+				}
+
+				return tg
+			})
+		})
+}
+
+// updateGroup replaces the group in the request path with the group returned by update.
+func (a *API) updateGroup(
+	w http.ResponseWriter,
+	r *http.Request,
+	file string,
+	update func(old *targets.TargetGroup) *targets.TargetGroup,
+) {
+	id := r.PathValue("id")
+	var group Group
+	err := a.change(func(s *sources) ([]string, error) {
+		ref, ok := s.find(id)
+		if !ok {
+			return nil, newAPIError(http.StatusNotFound, "group not found: %s", id)
+		}
+
+		if file != "" && file != filepath.Base(ref.file) {
+			return nil, newAPIError(http.StatusBadRequest, "groups can not be moved to another file")
+		}
+
+		tg := update(s.group(ref))
+		if err := validateGroup(a.server.Config, tg); err != nil {
+			return nil, err
+		}
+
+		if tg.Name != "" && s.nameTaken(tg.Name, &ref) {
+			return nil, newAPIError(http.StatusConflict, "group already exists: %s", tg.Name)
+		}
+
+		s.groups[ref.file][ref.index] = tg
+		group = newGroup(s, ref)
+		return []string{ref.file}, nil
+	})
+	if err != nil {
+		a.renderError(w, r, err)
+		return
+	}
+
+	a.render(w, r, http.StatusOK, group)
+}
+
+func (a *API) handleDeleteGroup() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.PathValue("id")
+			err := a.change(func(s *sources) ([]string, error) {
+				ref, ok := s.find(id)
+				if !ok {
+					return nil, newAPIError(http.StatusNotFound, "group not found: %s", id)
+				}
+
+				s.remove(ref)
+				return []string{ref.file}, nil
+			})
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+}
+
+// targetInfos lists every target in every group. If job is set only targets exported for that
+// job are returned, the same way export picks them with job selectors.
+func targetInfos(s *sources, job string) []TargetInfo {
+	infos := make([]TargetInfo, 0)
+	for _, ref := range s.all() {
+		tg := s.group(ref)
+		for _, t := range tg.Targets {
+			info := newTargetInfo(s.config, s.id(ref), tg, t)
+			if job != "" && !slices.Contains(info.Jobs, job) {
+				continue
+			}
+
+			infos = append(infos, info)
+		}
+	}
+
+	slices.SortStableFunc(infos, func(a, b TargetInfo) int { return strings.Compare(a.Address, b.Address) })
+	return infos
+}
+
+func newTargetInfo(config *core.Config, id string, tg *targets.TargetGroup, t targets.Target) TargetInfo {
+	labels := maps.Clone(tg.Labels)
+	if labels == nil && len(t.Labels) > 0 {
+		labels = make(map[string]string)
+	}
+	maps.Copy(labels, t.Labels)
+
+	// Selectors see the labels of the source the group was read from.
+	jobs := targets.TargetGroups{tg}.WithSources(config)[0].TargetJobs(config, t)
+	return TargetInfo{Address: t.Address, Group: id, Jobs: jobs, Labels: labels}
+}
+
+func (a *API) handleListTargets() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.read()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			a.render(w, r, http.StatusOK, targetInfos(s, r.URL.Query().Get("job")))
+		})
+}
+
+func (a *API) handleAddTarget() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			req, err := readBody[TargetRequest](w, r)
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			if req.Group == "" {
+				a.renderError(w, r, newAPIError(http.StatusBadRequest, "group is required"))
+				return
+			}
+
+			var info TargetInfo
+			err = a.change(func(s *sources) ([]string, error) {
+				ref, ok := s.find(req.Group)
+				if !ok {
+					return nil, newAPIError(http.StatusNotFound, "group not found: %s", req.Group)
+				}
+
+				old := s.group(ref)
+				for _, t := range old.Targets {
+					if t.Address == req.Address {
+						return nil, newAPIError(http.StatusConflict, "target already in group: %s", req.Address)
+					}
+				}
+
+				t := targets.Target{Address: req.Address, Labels: req.Labels}
+				tg := *old
+				tg.Targets = append(slices.Clone(old.Targets), t)
+				if err := validateGroup(a.server.Config, &tg); err != nil {
+					return nil, err
+				}
+
+				s.groups[ref.file][ref.index] = &tg
+				info = newTargetInfo(s.config, s.id(ref), &tg, t)
+				return []string{ref.file}, nil
+			})
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			a.render(w, r, http.StatusCreated, info)
+		})
+}
+
+// handleRemoveTarget removes a target from every group it is in, or only from the group set by
+// the group query parameter. Groups with no targets left are removed like pim target remove does.
+func (a *API) handleRemoveTarget() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			addr := r.PathValue("address")
+			only := r.URL.Query().Get("group")
+			err := a.change(func(s *sources) ([]string, error) {
+				files := make([]string, 0)
+				empty := make([]groupRef, 0)
+				for _, ref := range s.all() {
+					tg := s.group(ref)
+					if only != "" && s.id(ref) != only {
+						continue
+					}
+
+					kept := slices.DeleteFunc(slices.Clone(tg.Targets), func(t targets.Target) bool {
+						return t.Address == addr
+					})
+					if len(kept) == len(tg.Targets) {
+						continue
+					}
+
+					if len(kept) == 0 {
+						empty = append(empty, ref)
+					}
+
+					tg.Targets = kept
+					if !slices.Contains(files, ref.file) {
+						files = append(files, ref.file)
+					}
+				}
+
+				if len(files) == 0 {
+					return nil, newAPIError(http.StatusNotFound, "target not found: %s", addr)
+				}
+
+				// Remove the empty groups last so the positions of the other refs stay correct.
+				for i := len(empty) - 1; i >= 0; i-- {
+					s.remove(empty[i])
+				}
+
+				return files, nil
+			})
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		})
+}
+
+func (a *API) handleListJobs() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.read()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			tgs, err := s.targetGroups()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			jobMap := tgs.JobGroups(s.config)
+			jobs := make([]Job, 0, len(jobMap))
+			names := make([]string, 0, len(jobMap))
+			for name := range jobMap {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+
+			for _, name := range names {
+				count := 0
+				for _, eg := range jobMap[name] {
+					count += len(eg.Targets)
+				}
+
+				jobs = append(jobs, Job{Name: name, Targets: count})
+			}
+
+			a.render(w, r, http.StatusOK, jobs)
+		})
+}
+
+// handleGetJob returns the target groups for a job as they would be exported.
+func (a *API) handleGetJob() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.read()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			tgs, err := s.targetGroups()
+			if err != nil {
+				a.renderError(w, r, err)
+				return
+			}
+
+			egs, ok := tgs.JobGroups(s.config)[r.PathValue("job")]
+			if !ok {
+				a.renderError(w, r, newAPIError(http.StatusNotFound, "job not found: %s", r.PathValue("job")))
+				return
+			}
+
+			a.render(w, r, http.StatusOK, egs)
+		})
+}
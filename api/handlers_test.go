@@ -0,0 +1,363 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/router"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+	"github.com/stretchr/testify/require"
+)
+
+var testSource = `- jobs:
+    - node
+  labels:
+    environment: prod
+  targets:
+    - host01
+    - host02
+`
+
+// newTestServer creates a sources dir with testSource in a webapp_targets.yml file and a server
+// with the api routes. exports counts the calls to export.
+func newTestServer(t *testing.T, tempDir string, exports *int) *router.HTTPServer {
+	require := require.New(t)
+
+	err := os.WriteFile(filepath.Join(tempDir, "webapp_targets.yml"), []byte(testSource), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+
+	var buf bytes.Buffer
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	srv := router.NewHTTPServer(logger, config)
+	err = AddRoutes(&srv, func() error {
+		*exports++
+		return nil
+	})
+	require.NoError(err, "AddRoutes returned an unexpected error")
+
+	return &srv
+}
+
+func doRequest(srv *router.HTTPServer, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	var obj T
+	err := json.Unmarshal(w.Body.Bytes(), &obj)
+	require.NoError(t, err, "failed to decode response: %s", w.Body.String())
+	return obj
+}
+
+func TestAPIGroups(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	exports := 0
+	srv := newTestServer(t, tempDir, &exports)
+	file := filepath.Join(tempDir, "webapp_targets.yml")
+
+	t.Run("List", func(t *testing.T) {
+		w := doRequest(srv, http.MethodGet, "/api/v1/groups", "")
+		require.Equal(http.StatusOK, w.Code, "status code did not match")
+		groups := decode[[]Group](t, w)
+		require.Len(groups, 1, "wrong number of groups")
+		require.Equal("webapp_targets.yml:0", groups[0].ID, "unnamed group id did not match")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		body := `{"name": "db", "jobs": ["mysql"], "labels": {"role": "db"}, "targets": ["db01"]}`
+		w := doRequest(srv, http.MethodPost, "/api/v1/groups", body)
+		require.Equal(http.StatusCreated, w.Code, "status code did not match: %s", w.Body.String())
+		require.Equal("db", decode[Group](t, w).ID, "group id did not match")
+		require.Equal(1, exports, "export was not called")
+
+		tgs, err := targets.ReadSourceFile(file)
+		require.NoError(err, "failed to read sources file")
+		require.Len(tgs, 2, "group was not saved")
+		require.Equal("db", tgs[1].Name, "saved group did not match")
+	})
+
+	t.Run("CreateErrors", func(t *testing.T) {
+		tests := map[string]struct {
+			body string
+			want int
+		}{
+			"NoName":       {`{"jobs": ["mysql"], "targets": ["db01"]}`, http.StatusBadRequest},
+			"UnknownField": {`{"name": "x", "jobs": ["a"], "targets": ["b"], "port": 1}`, http.StatusBadRequest},
+			"InvalidLabel": {`{"name": "x", "jobs": ["a"], "labels": {"9x": "y"}, "targets": ["b"]}`, http.StatusBadRequest},
+			"NoTargets":    {`{"name": "x", "jobs": ["a"]}`, http.StatusBadRequest},
+			"BadFile":      {`{"file": "../x.yml", "name": "x", "jobs": ["a"], "targets": ["b"]}`, http.StatusBadRequest},
+			"Duplicate":    {`{"name": "db", "jobs": ["a"], "targets": ["b"]}`, http.StatusConflict},
+		}
+
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				w := doRequest(srv, http.MethodPost, "/api/v1/groups", tt.body)
+				require.Equal(tt.want, w.Code, "status code did not match: %s", w.Body.String())
+				require.NotEmpty(decode[ErrorResponse](t, w).Error, "error was empty")
+			})
+		}
+
+		w := doRequest(srv, http.MethodPost, "/api/v1/groups", `{"name": "x", "jobs": ["a"], "labels": {"__x": "y"}, "targets": ["b"]}`)
+		require.Equal(
+			targets.Problems{{Message: `label name "__x" is reserved for internal use`}},
+			decode[ErrorResponse](t, w).Problems,
+			"problems did not match",
+		)
+		require.Equal(1, exports, "export was called for a failed request")
+	})
+
+	t.Run("CreateNewFile", func(t *testing.T) {
+		body := `{"file": "db_targets.json", "name": "db2", "jobs": ["mysql"], "targets": ["db02"]}`
+		w := doRequest(srv, http.MethodPost, "/api/v1/groups", body)
+		require.Equal(http.StatusCreated, w.Code, "status code did not match: %s", w.Body.String())
+		require.FileExists(filepath.Join(tempDir, "db_targets.json"), "new source file was not created")
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		w := doRequest(srv, http.MethodGet, "/api/v1/groups/db", "")
+		require.Equal(http.StatusOK, w.Code, "status code did not match")
+		require.Equal([]string{"mysql"}, decode[Group](t, w).Jobs, "jobs did not match")
+
+		w = doRequest(srv, http.MethodGet, "/api/v1/groups/missing", "")
+		require.Equal(http.StatusNotFound, w.Code, "status code did not match")
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		body := `{"name": "db", "priority": 5, "jobs": ["mysql", "node"], "targets": ["db01", "db03"]}`
+		w := doRequest(srv, http.MethodPut, "/api/v1/groups/db", body)
+		require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+		group := decode[Group](t, w)
+		require.Equal([]string{"mysql", "node"}, group.Jobs, "jobs did not match")
+		require.Equal(5, group.Priority, "priority did not match")
+		require.Empty(group.Labels, "labels were not replaced")
+	})
+
+	t.Run("PatchPriority", func(t *testing.T) {
+		w := doRequest(srv, http.MethodPatch, "/api/v1/groups/db", `{"labels": {"role": "db"}}`)
+		require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+		require.Equal(5, decode[Group](t, w).Priority, "priority was changed")
+
+		w = doRequest(srv, http.MethodPatch, "/api/v1/groups/db", `{"priority": 2}`)
+		require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+		require.Equal(2, decode[Group](t, w).Priority, "priority did not match")
+
+		tgs, err := targets.ReadSourceFile(file)
+		require.NoError(err, "failed to read sources file")
+		require.Equal(2, tgs[1].Priority, "saved priority did not match")
+		require.Equal(map[string]string{"role": "db"}, tgs[1].Labels, "saved labels did not match")
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		body := `{"labels": {"role": "db", "environment": null}}`
+		w := doRequest(srv, http.MethodPatch, "/api/v1/groups/webapp_targets.yml:0", body)
+		require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+		group := decode[Group](t, w)
+		require.Equal(map[string]string{"role": "db"}, group.Labels, "labels did not match")
+		require.Equal([]string{"node"}, group.Jobs, "jobs were changed")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := doRequest(srv, http.MethodDelete, "/api/v1/groups/db", "")
+		require.Equal(http.StatusNoContent, w.Code, "status code did not match")
+
+		w = doRequest(srv, http.MethodDelete, "/api/v1/groups/db", "")
+		require.Equal(http.StatusNotFound, w.Code, "status code did not match")
+	})
+}
+
+func TestAPITargets(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	exports := 0
+	srv := newTestServer(t, tempDir, &exports)
+	id := "webapp_targets.yml:0"
+
+	t.Run("Add", func(t *testing.T) {
+		body := `{"group": "` + id + `", "address": "host03", "labels": {"rack": "r12"}}`
+		w := doRequest(srv, http.MethodPost, "/api/v1/targets", body)
+		require.Equal(http.StatusCreated, w.Code, "status code did not match: %s", w.Body.String())
+		require.Equal(TargetInfo{
+			Address: "host03",
+			Group:   id,
+			Jobs:    []string{"node"},
+			Labels:  map[string]string{"environment": "prod", "rack": "r12"},
+		}, decode[TargetInfo](t, w), "target did not match")
+
+		w = doRequest(srv, http.MethodPost, "/api/v1/targets", body)
+		require.Equal(http.StatusConflict, w.Code, "status code did not match")
+
+		w = doRequest(srv, http.MethodPost, "/api/v1/targets", `{"group": "missing", "address": "host04"}`)
+		require.Equal(http.StatusNotFound, w.Code, "status code did not match")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w := doRequest(srv, http.MethodGet, "/api/v1/targets?job=node", "")
+		require.Equal(http.StatusOK, w.Code, "status code did not match")
+		infos := decode[[]TargetInfo](t, w)
+		require.Len(infos, 3, "wrong number of targets")
+		require.Equal("host01", infos[0].Address, "targets were not sorted")
+
+		w = doRequest(srv, http.MethodGet, "/api/v1/targets?job=other", "")
+		require.Empty(decode[[]TargetInfo](t, w), "targets for other job were not empty")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		w := doRequest(srv, http.MethodDelete, "/api/v1/targets/host03", "")
+		require.Equal(http.StatusNoContent, w.Code, "status code did not match")
+
+		w = doRequest(srv, http.MethodDelete, "/api/v1/targets/host03", "")
+		require.Equal(http.StatusNotFound, w.Code, "status code did not match")
+
+		w = doRequest(srv, http.MethodDelete, "/api/v1/targets/host02", "")
+		require.Equal(http.StatusNoContent, w.Code, "status code did not match")
+
+		// The last target removes the group, the same as pim target remove.
+		w = doRequest(srv, http.MethodDelete, "/api/v1/targets/host01", "")
+		require.Equal(http.StatusNoContent, w.Code, "status code did not match: %s", w.Body.String())
+
+		w = doRequest(srv, http.MethodGet, "/api/v1/groups", "")
+		require.Empty(decode[[]Group](t, w), "empty group was not removed")
+	})
+
+	require.Equal(4, exports, "export was not called for each change")
+}
+
+func TestAPIJobs(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	exports := 0
+	srv := newTestServer(t, tempDir, &exports)
+
+	w := doRequest(srv, http.MethodGet, "/api/v1/jobs", "")
+	require.Equal(http.StatusOK, w.Code, "status code did not match")
+	require.Equal([]Job{{Name: "node", Targets: 2}}, decode[[]Job](t, w), "jobs did not match")
+
+	w = doRequest(srv, http.MethodGet, "/api/v1/jobs/node", "")
+	require.Equal(http.StatusOK, w.Code, "status code did not match")
+	require.JSONEq(
+		`[{"labels": {"environment": "prod", "job": "node"}, "targets": ["host01", "host02"]}]`,
+		w.Body.String(),
+		"job groups did not match",
+	)
+
+	w = doRequest(srv, http.MethodGet, "/api/v1/jobs/missing", "")
+	require.Equal(http.StatusNotFound, w.Code, "status code did not match")
+}
+
+func TestAPIJobSelectors(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	exports := 0
+	srv := newTestServer(t, tempDir, &exports)
+	srv.Config.Jobs = map[string]*core.JobConfig{"blackbox_ssh": {Match: map[string]string{"role": "db"}}}
+
+	w := doRequest(srv, http.MethodPost, "/api/v1/groups", `{"name": "db", "labels": {"role": "db"}, "targets": ["db01"]}`)
+	require.Equal(http.StatusCreated, w.Code, "status code did not match: %s", w.Body.String())
+
+	w = doRequest(srv, http.MethodPost, "/api/v1/groups", `{"name": "web", "labels": {"role": "web"}, "targets": ["web01"]}`)
+	require.Equal(http.StatusBadRequest, w.Code, "status code did not match: %s", w.Body.String())
+
+	w = doRequest(srv, http.MethodGet, "/api/v1/jobs/blackbox_ssh", "")
+	require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+	require.Equal([]string{"db01"}, decode[targets.ExportGroups](t, w)[0].Targets, "job targets did not match")
+
+	w = doRequest(srv, http.MethodGet, "/api/v1/targets?job=blackbox_ssh", "")
+	require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+	infos := decode[[]TargetInfo](t, w)
+	require.Len(infos, 1, "selected targets did not match")
+	require.Equal("db01", infos[0].Address, "selected target did not match")
+	require.Equal([]string{"blackbox_ssh"}, infos[0].Jobs, "selected target jobs did not match")
+}
+
+func TestAPIKeepsSourceFormatting(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	exports := 0
+	srv := newTestServer(t, tempDir, &exports)
+	file := filepath.Join(tempDir, "webapp_targets.yml")
+	source := `# Web servers, owned by the web team.
+- jobs: [node]
+  labels:
+    environment: prod   # production only
+  targets:
+    - host01
+    - host02
+
+# Databases
+- name: db
+  jobs:
+      - mysql
+  targets:
+      - db01    # primary
+  owner: dba
+`
+	err = os.WriteFile(file, []byte(source), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	t.Run("AddTarget", func(t *testing.T) {
+		w := doRequest(srv, http.MethodPost, "/api/v1/targets", `{"group": "webapp_targets.yml:0", "address": "host03"}`)
+		require.Equal(http.StatusConflict, w.Code, "unknown key was not reported: %s", w.Body.String())
+		require.Contains(decode[ErrorResponse](t, w).Error, `unknown key "owner"`, "error did not match")
+		require.Equal(source, readFile(t, file), "file was changed")
+	})
+
+	source = strings.Replace(source, "  owner: dba\n", "", 1)
+	err = os.WriteFile(file, []byte(source), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	t.Run("PatchGroup", func(t *testing.T) {
+		w := doRequest(srv, http.MethodPost, "/api/v1/targets", `{"group": "db", "address": "db02"}`)
+		require.Equal(http.StatusCreated, w.Code, "status code did not match: %s", w.Body.String())
+
+		want := strings.Replace(source, "# primary\n", "# primary\n      - db02\n", 1)
+		require.Equal(want, readFile(t, file), "only the new target should be added")
+
+		w = doRequest(srv, http.MethodPatch, "/api/v1/groups/db", `{"priority": 2}`)
+		require.Equal(http.StatusOK, w.Code, "status code did not match: %s", w.Body.String())
+
+		got := readFile(t, file)
+		require.True(strings.HasPrefix(got, strings.Split(source, "- name: db")[0]), "untouched group was changed")
+		require.Contains(got, "- db01 # primary", "target comment was not kept")
+		require.Contains(got, "priority: 2", "priority was not saved")
+	})
+}
+
+func readFile(t *testing.T, file string) string {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err, "failed to read %s", file)
+	return string(data)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/chadeldridge/prometheus-import-manager/router"
+)
+
+// API serves the /api/v1 routes. Changes are saved back to the source files they were read from.
+type API struct {
+	server *router.HTTPServer
+	// export is called after every successful change to export the new targets.
+	export func() error
+	// mu serializes reading and writing the source files.
+	mu sync.Mutex
+}
+
+// AddRoutes adds the /api/v1 routes to server. export is called after the sources are changed
+// and may be nil.
+func AddRoutes(server *router.HTTPServer, export func() error) error {
+	a := &API{server: server, export: export}
+
+	// Initialize middleware
+	mwLogger := router.LoggerMiddleware(server.Logger)
+
+	root, err := router.NewRouterGroup(server.Mux, "/api/v1")
+	if err != nil {
+		return err
+	}
+
+	server.Logger.Debug("adding api routes")
+	groups := root.Group("/groups", mwLogger)
+	groups.GET("/", a.handleListGroups())
+	groups.POST("/", a.handleCreateGroup())
+	groups.GET("/{id}", a.handleGetGroup())
+	groups.PUT("/{id}", a.handleReplaceGroup())
+	groups.PATCH("/{id}", a.handlePatchGroup())
+	groups.DELETE("/{id}", a.handleDeleteGroup())
+
+	targets := root.Group("/targets", mwLogger)
+	targets.GET("/", a.handleListTargets())
+	targets.POST("/", a.handleAddTarget())
+	targets.DELETE("/{address}", a.handleRemoveTarget())
+
+	jobs := root.Group("/jobs", mwLogger)
+	jobs.GET("/", a.handleListJobs())
+	jobs.GET("/{job}", a.handleGetJob())
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// sources holds the target groups of every source file so they can be changed and saved back to
+// the file they were read from. from holds the position in the file each group was read from, or
+// -1 for new groups, so saving only changes the groups that changed.
+type sources struct {
+	config *core.Config
+	files  []string
+	groups map[string]targets.TargetGroups
+	from   map[string][]int
+}
+
+// groupRef points at a single group in sources.
+type groupRef struct {
+	file  string
+	index int
+}
+
+// loadSources reads every source file in config.Sources or the config sources list.
+func loadSources(config *core.Config) (*sources, error) {
+	files, err := targets.SourceFiles(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sources{
+		config: config,
+		files:  files,
+		groups: make(map[string]targets.TargetGroups),
+		from:   make(map[string][]int),
+	}
+	for _, f := range files {
+		tgs, err := targets.ReadSource(config, f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		s.groups[f] = tgs
+		s.from[f] = make([]int, len(tgs))
+		for i := range tgs {
+			s.from[f][i] = i
+		}
+	}
+
+	return s, nil
+}
+
+// groupID returns the id used to address a group. Named groups use their name. Other groups use
+// the source file name and their position in the file, which changes if an earlier group in the
+// same file is removed. With a config sources list the file name is prefixed with the source
+// name since two sources can have files with the same name.
+func groupID(config *core.Config, file string, index int, tg *targets.TargetGroup) string {
+	if tg.Name != "" {
+		return tg.Name
+	}
+
+	id := filepath.Base(file) + ":" + strconv.Itoa(index)
+	if src := targets.SourceName(config, file); src != "" {
+		id = src + ":" + id
+	}
+
+	return id
+}
+
+// all returns a reference to every group in file order.
+func (s *sources) all() []groupRef {
+	refs := make([]groupRef, 0)
+	for _, f := range s.files {
+		for i := range s.groups[f] {
+			refs = append(refs, groupRef{file: f, index: i})
+		}
+	}
+
+	return refs
+}
+
+func (s *sources) group(ref groupRef) *targets.TargetGroup {
+	return s.groups[ref.file][ref.index]
+}
+
+func (s *sources) id(ref groupRef) string {
+	return groupID(s.config, ref.file, ref.index, s.group(ref))
+}
+
+// find returns the group with id.
+func (s *sources) find(id string) (groupRef, bool) {
+	for _, ref := range s.all() {
+		if s.id(ref) == id {
+			return ref, true
+		}
+	}
+
+	return groupRef{}, false
+}
+
+// nameTaken returns true if a group other than skip is already called name.
+func (s *sources) nameTaken(name string, skip *groupRef) bool {
+	for _, ref := range s.all() {
+		if skip != nil && ref == *skip {
+			continue
+		}
+
+		if s.group(ref).Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceFile returns the path of the source file a new group should be added to. name is the
+// requested file name and may be empty.
+func (s *sources) sourceFile(name string) (string, error) {
+	return targets.NewGroupFile(s.config, s.files, name)
+}
+
+// add appends tg to file and returns a reference to it. A new file is added among the files of
+// its dir without moving the files of other sources, so the order groups are merged in is the
+// same as when the sources are read again.
+func (s *sources) add(file string, tg *targets.TargetGroup) groupRef {
+	if !slices.Contains(s.files, file) {
+		s.files = slices.Insert(s.files, s.newFileIndex(file), file)
+	}
+
+	s.groups[file] = append(s.groups[file], tg)
+	s.from[file] = append(s.from[file], -1)
+	return groupRef{file: file, index: len(s.groups[file]) - 1}
+}
+
+// newFileIndex returns the position in s.files for a new file. Files in a sources dir are read in
+// name order, so it goes before the first file in the same dir that sorts after it, or after the
+// last file in the dir. A file in a dir with no files yet goes at the end.
+func (s *sources) newFileIndex(file string) int {
+	dir := filepath.Dir(file)
+	index := len(s.files)
+	found := false
+	for i, f := range s.files {
+		if filepath.Dir(f) != dir {
+			if found {
+				return i
+			}
+
+			continue
+		}
+
+		if f > file {
+			return i
+		}
+
+		found = true
+		index = i + 1
+	}
+
+	return index
+}
+
+// remove deletes the group at ref.
+func (s *sources) remove(ref groupRef) {
+	s.groups[ref.file] = slices.Delete(s.groups[ref.file], ref.index, ref.index+1)
+	s.from[ref.file] = slices.Delete(s.from[ref.file], ref.index, ref.index+1)
+}
+
+// save writes the changes to file back to disk. Only pim source files can be written. The file
+// is edited in place, so comments, unknown keys and untouched groups are kept, and it is not
+// written if the result would not pass validate.
+func (s *sources) save(file string) error {
+	if !targets.IsWritable(s.config, file) {
+		return newAPIError(http.StatusConflict, "%s is read only and can not be changed", filepath.Base(file))
+	}
+
+	err := targets.UpdateSourceFile(s.config, file, s.groups[file], s.from[file])
+	if errors.Is(err, os.ErrInvalid) {
+		return &apiError{status: http.StatusConflict, err: err}
+	}
+
+	return err
+}
+
+// targetGroups returns every group from every source file with the target address patterns
+// expanded as they are exported.
+func (s *sources) targetGroups() (targets.TargetGroups, error) {
+	tgs := make(targets.TargetGroups, 0)
+	for _, ref := range s.all() {
+		tgs = append(tgs, s.group(ref))
+	}
+
+	return tgs.WithSources(s.config).Expand()
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourcesSourceFile(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+
+	t.Run("Empty", func(t *testing.T) {
+		s, err := loadSources(config)
+		require.NoError(err, "loadSources returned an unexpected error")
+
+		file, err := s.sourceFile("")
+		require.NoError(err, "sourceFile returned an unexpected error")
+		require.Equal(filepath.Join(tempDir, targets.DefaultSourceFile), file, "file did not match")
+
+		_, err = s.sourceFile("hosts.yml")
+		require.ErrorIs(err, os.ErrInvalid, "sourceFile did not return the correct error")
+	})
+
+	err = os.WriteFile(filepath.Join(tempDir, "a_targets.yml"), []byte(testSource), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	t.Run("PatternFiles", func(t *testing.T) {
+		s, err := loadSources(config)
+		require.NoError(err, "loadSources returned an unexpected error")
+
+		file, err := s.sourceFile("")
+		require.NoError(err, "sourceFile returned an unexpected error")
+		require.Equal(filepath.Join(tempDir, "a_targets.yml"), file, "file did not match")
+
+		file, err = s.sourceFile("b_targets.json")
+		require.NoError(err, "sourceFile returned an unexpected error")
+		require.Equal(filepath.Join(tempDir, "b_targets.json"), file, "file did not match")
+
+		// targets.yml would hide a_targets.yml.
+		_, err = s.sourceFile("targets.yml")
+		require.ErrorIs(err, os.ErrInvalid, "sourceFile did not return the correct error")
+	})
+
+	t.Run("SingleFile", func(t *testing.T) {
+		config := core.DefaultConfig()
+		config.Sources = filepath.Join(tempDir, "a_targets.yml")
+		s, err := loadSources(config)
+		require.NoError(err, "loadSources returned an unexpected error")
+
+		file, err := s.sourceFile("")
+		require.NoError(err, "sourceFile returned an unexpected error")
+		require.Equal(config.Sources, file, "file did not match")
+
+		_, err = s.sourceFile("b_targets.yml")
+		require.ErrorIs(err, os.ErrInvalid, "sourceFile did not return the correct error")
+	})
+}
+
+func TestSourcesInventory(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+
+	inventory := filepath.Join(tempDir, "a_targets.ini")
+	err = os.WriteFile(inventory, []byte("[webservers]\nweb01\n"), 0o644)
+	require.NoError(err, "failed to write inventory file")
+	err = os.WriteFile(filepath.Join(tempDir, "b_targets.yml"), []byte(testSource), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	s, err := loadSources(config)
+	require.NoError(err, "loadSources returned an unexpected error")
+	require.Len(s.groups[inventory], 1, "inventory hosts were not loaded")
+
+	file, err := s.sourceFile("")
+	require.NoError(err, "sourceFile returned an unexpected error")
+	require.Equal(filepath.Join(tempDir, "b_targets.yml"), file, "new groups were added to the inventory")
+
+	err = s.save(inventory)
+	var aerr *apiError
+	require.True(errors.As(err, &aerr), "save did not return an apiError")
+	require.Equal(http.StatusConflict, aerr.status, "status did not match")
+}
+
+func TestSourcesRemote(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testSource))
+	}))
+	defer srv.Close()
+
+	config := core.DefaultConfig()
+	config.Sources = srv.URL
+	config.SourcesCacheDir = tempDir
+
+	s, err := loadSources(config)
+	require.NoError(err, "loadSources returned an unexpected error")
+	require.Len(s.files, 1, "remote sources were not loaded")
+
+	file, err := s.sourceFile("")
+	require.NoError(err, "sourceFile returned an unexpected error")
+	require.Equal(s.files[0], file, "file did not match")
+
+	err = s.save(file)
+	var aerr *apiError
+	require.True(errors.As(err, &aerr), "save did not return an apiError")
+	require.Equal(http.StatusConflict, aerr.status, "status did not match")
+}
+
+func TestSourcesSourceList(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	inventory := filepath.Join(tempDir, "hosts.ini")
+	err = os.WriteFile(inventory, []byte("[webservers]\nweb01\n"), 0o644)
+	require.NoError(err, "failed to write inventory file")
+	file := filepath.Join(tempDir, "local.yml")
+	err = os.WriteFile(file, []byte(testSource), 0o644)
+	require.NoError(err, "failed to write sources file")
+
+	config := core.DefaultConfig()
+	config.SourceList = []*core.SourceConfig{
+		{Name: "ansible", Path: inventory},
+		{Name: "local", Path: file, Labels: map[string]string{"source": "local"}},
+	}
+
+	s, err := loadSources(config)
+	require.NoError(err, "loadSources returned an unexpected error")
+	require.Equal([]string{inventory, file}, s.files, "files did not match")
+
+	got, err := s.sourceFile("")
+	require.NoError(err, "sourceFile returned an unexpected error")
+	require.Equal(file, got, "file did not match")
+
+	_, err = s.sourceFile("other_targets.yml")
+	require.ErrorIs(err, os.ErrInvalid, "sourceFile did not return the correct error")
+
+	tgs, err := s.targetGroups()
+	require.NoError(err, "targetGroups returned an unexpected error")
+	require.Equal("local", tgs[len(tgs)-1].Labels["source"], "source labels were not applied")
+	require.Empty(s.groups[file][0].Labels["source"], "source labels were added to the source file groups")
+}
+
+func TestSourcesGroupID(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "api_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	for _, name := range []string{"team-a", "team-b"} {
+		dir := filepath.Join(tempDir, name)
+		require.NoError(os.MkdirAll(dir, 0o755), "failed to create sources dir")
+		err := os.WriteFile(filepath.Join(dir, "targets.yml"), []byte(testSource), 0o644)
+		require.NoError(err, "failed to write sources file")
+		config.SourceList = append(config.SourceList, &core.SourceConfig{Name: name, Path: dir})
+	}
+
+	s, err := loadSources(config)
+	require.NoError(err, "loadSources returned an unexpected error")
+
+	ids := make([]string, 0)
+	for _, ref := range s.all() {
+		ids = append(ids, s.id(ref))
+	}
+	require.Equal([]string{"team-a:targets.yml:0", "team-b:targets.yml:0"}, ids, "ids did not match")
+
+	ref, ok := s.find("team-b:targets.yml:0")
+	require.True(ok, "group was not found")
+	require.Equal(filepath.Join(tempDir, "team-b", "targets.yml"), ref.file, "file did not match")
+}
+
+func TestSourcesAdd(t *testing.T) {
+	require := require.New(t)
+
+	s := &sources{
+		files:  []string{"/z/b_targets.yml", "/z/d_targets.yml", "/a/targets.yml"},
+		groups: make(map[string]targets.TargetGroups),
+		from:   make(map[string][]int),
+	}
+
+	s.add("/z/c_targets.yml", &targets.TargetGroup{Name: "c"})
+	s.add("/z/e_targets.yml", &targets.TargetGroup{Name: "e"})
+	s.add("/z/a_targets.yml", &targets.TargetGroup{Name: "a"})
+	ref := s.add("/y/targets.yml", &targets.TargetGroup{Name: "y"})
+	require.Equal(
+		[]string{
+			"/z/a_targets.yml",
+			"/z/b_targets.yml",
+			"/z/c_targets.yml",
+			"/z/d_targets.yml",
+			"/z/e_targets.yml",
+			"/a/targets.yml",
+			"/y/targets.yml",
+		},
+		s.files,
+		"files did not match",
+	)
+	require.Equal(groupRef{file: "/y/targets.yml", index: 0}, ref, "ref did not match")
+	require.Equal([]int{-1}, s.from["/y/targets.yml"], "new group position did not match")
+}
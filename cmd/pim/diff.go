@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// errDrift is returned by diff when an export would change the targets files. main exits with
+// status 2 so CI can tell drift apart from other errors.
+var errDrift = errors.New("targets files do not match sources")
+
+// diff compares the targets files an export would write with the files in the targets dir and
+// prints the changes.
+func diff(logger *core.Logger, config *core.Config) error {
+	logger.Debugf("diff: importing targets from %s\n", config.Sources)
+	tgs, err := targets.NewTargetGroups(config)
+	if err != nil {
+		return fmt.Errorf("diff: error loading source: %w", err)
+	}
+
+	logger.Debugf("diff: comparing targets with %s\n", config.TargetsDir)
+	diffs, err := tgs.Diff(config)
+	if err != nil {
+		return fmt.Errorf("diff: error comparing targets: %w", err)
+	}
+
+	if err := printDiffs(logger, diffs, config.Flags["output"]); err != nil {
+		return err
+	}
+
+	if diffs.Changed() {
+		return errDrift
+	}
+
+	return nil
+}
+
+// printDiffs prints diffs to stdout in the requested output format.
+func printDiffs(logger *core.Logger, diffs targets.Diffs, output string) error {
+	switch output {
+	case "json":
+		data, err := core.MarshalJSON(&diffs)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOut(string(data))
+	case "yaml":
+		data, err := core.MarshalYAML(&diffs)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOutf("%s", data)
+	default:
+		logger.PrintOutf("%s", formatDiffs(diffs))
+	}
+
+	return nil
+}
+
+// formatDiffs formats diffs like a unified diff with one section per changed targets file.
+//
+//	--- node_targets.json (updated)
+//	+ host03 {environment="prod", job="node"}
+//	- host01 {environment="prod", job="node"}
+//	~ host02 rack: "r01" -> "r12"
+func formatDiffs(diffs targets.Diffs) string {
+	var b strings.Builder
+	changed := 0
+	for _, d := range diffs {
+		if d.Status == targets.FileUnchanged {
+			continue
+		}
+
+		changed++
+		fmt.Fprintf(&b, "--- %s (%s)\n", d.File, d.Status)
+		for _, c := range d.Added {
+			fmt.Fprintf(&b, "+ %s %s\n", c.Target, formatLabels(c.New))
+		}
+
+		for _, c := range d.Removed {
+			fmt.Fprintf(&b, "- %s %s\n", c.Target, formatLabels(c.Old))
+		}
+
+		for _, c := range d.Changed {
+			fmt.Fprintf(&b, "~ %s %s\n", c.Target, formatLabelChanges(c.Old, c.New))
+		}
+	}
+
+	if changed == 0 {
+		b.WriteString("no changes\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%d of %d targets files would change\n", changed, len(diffs))
+	return b.String()
+}
+
+// formatLabels formats labels the same way Prometheus displays them.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, labels[k]))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// formatLabelChanges lists only the labels that differ between old and new.
+func formatLabelChanges(old, new map[string]string) string {
+	keys := make(map[string]string, len(old)+len(new))
+	for k := range old {
+		keys[k] = ""
+	}
+	for k := range new {
+		keys[k] = ""
+	}
+
+	changes := make([]string, 0)
+	for _, k := range sortedKeys(keys) {
+		o, inOld := old[k]
+		n, inNew := new[k]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("%s: <none> -> %q", k, n))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("%s: %q -> <none>", k, o))
+		case o != n:
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", k, o, n))
+		}
+	}
+
+	return strings.Join(changes, ", ")
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDirs creates a sources dir containing yamlSource and an empty targets dir and returns a
+// config pointing at them.
+func newTestDirs(t *testing.T, tempDir string) *core.Config {
+	require := require.New(t)
+
+	sourcesDir := filepath.Join(tempDir, "sources")
+	err := os.MkdirAll(sourcesDir, 0o755)
+	require.NoError(err, "failed to create temp sources dir")
+
+	sfile := filepath.Join(sourcesDir, "targets.yml")
+	err = core.WriteFile(sfile, []byte(yamlSource), 0o644)
+	require.NoError(err, "failed to write sources file to %s", sfile)
+
+	targetsDir := filepath.Join(tempDir, "targets")
+	err = os.MkdirAll(targetsDir, 0o755)
+	require.NoError(err, "failed to create temp targets dir")
+
+	config := newBaseConfig()
+	config.Flags = core.Flags{"command": "diff"}
+	config.Sources = sourcesDir
+	config.TargetsDir = targetsDir
+
+	return config
+}
+
+func TestMainDiff(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+
+	t.Run("Drift", func(t *testing.T) {
+		buf.Reset()
+		err := diff(logger, config)
+		require.ErrorIs(err, errDrift, "diff did not return the correct error")
+		require.Contains(buf.String(), "--- node-exporter_targets.json (created)")
+		require.Contains(buf.String(), `+ node1.example.com {datacenter="us-east-1", environment="stg", job="node-exporter"}`)
+		require.Contains(buf.String(), "3 of 3 targets files would change")
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "export", "dry_run": "true"}
+		err := export(logger, config)
+		require.NoError(err, "export returned an unexpected error")
+		require.Contains(buf.String(), "3 of 3 targets files would change")
+
+		entries, err := os.ReadDir(config.TargetsDir)
+		require.NoError(err, "failed to read targets dir")
+		require.Empty(entries, "dry run wrote to the targets dir")
+	})
+
+	t.Run("NoDrift", func(t *testing.T) {
+		config.Flags = core.Flags{"command": "export"}
+		err := export(logger, config)
+		require.NoError(err, "export returned an unexpected error")
+
+		buf.Reset()
+		config.Flags = core.Flags{"command": "diff", "output": "json"}
+		err = diff(logger, config)
+		require.NoError(err, "diff returned an unexpected error")
+		require.Contains(buf.String(), `"status": "unchanged"`)
+	})
+}
+
+func TestMainFormatDiffs(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoChanges", func(t *testing.T) {
+		diffs := targets.Diffs{{File: "node_targets.json", Status: targets.FileUnchanged}}
+		require.Equal("no changes\n", formatDiffs(diffs))
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		diffs := targets.Diffs{
+			{File: "mysql_targets.json", Status: targets.FileUnchanged},
+			{
+				File:    "node_targets.json",
+				Status:  targets.FileUpdated,
+				Added:   []targets.TargetChange{{Target: "host03", New: map[string]string{"job": "node"}}},
+				Removed: []targets.TargetChange{{Target: "host01", Old: map[string]string{"job": "node"}}},
+				Changed: []targets.TargetChange{
+					{
+						Target: "host02",
+						Old:    map[string]string{"job": "node", "rack": "r01", "owner": "dba"},
+						New:    map[string]string{"job": "node", "rack": "r12", "env": "prod"},
+					},
+				},
+			},
+		}
+
+		want := `--- node_targets.json (updated)
++ host03 {job="node"}
+- host01 {job="node"}
+~ host02 env: <none> -> "prod", owner: "dba" -> <none>, rack: "r01" -> "r12"
+1 of 2 targets files would change
+`
+		require.Equal(want, formatDiffs(diffs))
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// explain prints every group a target is in and how each step of the export changed its labels.
+func explain(logger *core.Logger, config *core.Config) error {
+	addr := config.Flags["explain_target"]
+	job := config.Flags["explain_job"]
+
+	logger.Debugf("explain: importing targets from %s\n", config.Sources)
+	tgs, err := targets.NewTargetGroups(config)
+	if tgs == nil {
+		return fmt.Errorf("explain: error loading source: %w", err)
+	}
+
+	// merge_precedence error fails on conflicting labels but explain is how they get tracked down.
+	if err != nil {
+		logger.Printf("explain: %s\n", err)
+	}
+
+	explanations := tgs.Explain(config, addr, job)
+	if len(explanations) == 0 {
+		if job != "" {
+			return fmt.Errorf("explain: %w: target %s is not in job %s", os.ErrNotExist, addr, job)
+		}
+
+		return fmt.Errorf("explain: %w: target %s is not in any job", os.ErrNotExist, addr)
+	}
+
+	return printExplanations(logger, explanations, config.Flags["output"])
+}
+
+// printExplanations prints explanations to stdout in the requested output format.
+func printExplanations(logger *core.Logger, explanations targets.Explanations, output string) error {
+	switch output {
+	case "json":
+		data, err := core.MarshalJSON(&explanations)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOut(string(data))
+	case "yaml":
+		data, err := core.MarshalYAML(&explanations)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOutf("%s", data)
+	default:
+		logger.PrintOutf("%s", formatExplanations(explanations))
+	}
+
+	return nil
+}
+
+// formatExplanations formats explanations with one section per job.
+//
+//	job node target host01:9100
+//	  group 0 "web" in /etc/pim/sources/targets.yml
+//	    address: host01 -> host01:9100
+//	    group labels: {rack="r01"}
+//	    relabel_configs 0 (replace): datacenter: <none> -> "atl"
+//	    labels: {datacenter="atl", job="node", rack="r01"}
+//	  labels: {datacenter="atl", job="node", rack="r01"}
+//	  file: node_targets.yml
+func formatExplanations(explanations targets.Explanations) string {
+	var b strings.Builder
+	for i, e := range explanations {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "job %s target %s\n", e.Job, e.Address)
+		for _, g := range e.Groups {
+			formatExplainedGroup(&b, e.Job, g)
+		}
+
+		if e.Dropped {
+			b.WriteString("  dropped by every group\n")
+			continue
+		}
+
+		if len(e.Conflicts) > 0 {
+			fmt.Fprintf(&b, "  conflicting labels: %s\n", strings.Join(e.Conflicts, ", "))
+		}
+
+		fmt.Fprintf(&b, "  labels: %s\n", formatLabels(e.Labels))
+		if e.File != "" {
+			fmt.Fprintf(&b, "  file: %s\n", e.File)
+		}
+	}
+
+	return b.String()
+}
+
+func formatExplainedGroup(b *strings.Builder, job string, g *targets.ExplainedGroup) {
+	fmt.Fprintf(b, "  group %d", g.Group)
+	if g.Name != "" {
+		fmt.Fprintf(b, " %q", g.Name)
+	}
+
+	fmt.Fprintf(b, " in %s", g.File)
+	details := make([]string, 0)
+	if g.Source != "" {
+		details = append(details, "source "+g.Source)
+	}
+
+	if g.Priority != 0 {
+		details = append(details, fmt.Sprintf("priority %d", g.Priority))
+	}
+
+	if g.Selected {
+		details = append(details, "selected by job selector")
+	}
+
+	if len(details) > 0 {
+		fmt.Fprintf(b, " (%s)", strings.Join(details, ", "))
+	}
+	b.WriteString("\n")
+
+	if g.Address != g.SourceAddress {
+		fmt.Fprintf(b, "    address: %s -> %s\n", g.SourceAddress, g.Address)
+	}
+
+	sets := []struct {
+		name   string
+		labels map[string]string
+	}{
+		{"source labels", g.SourceLabels},
+		{"job labels", g.JobLabels},
+		{"group labels", g.GroupLabels},
+		{"target labels", g.TargetLabels},
+	}
+	for _, s := range sets {
+		if len(s.labels) > 0 {
+			fmt.Fprintf(b, "    %s: %s\n", s.name, formatLabels(s.labels))
+		}
+	}
+
+	// Relabel steps only hold the labels after each rule, so track the labels before it.
+	prev := make(map[string]string)
+	maps.Copy(prev, g.JobLabels)
+	maps.Copy(prev, g.GroupLabels)
+	maps.Copy(prev, g.TargetLabels)
+	prev["job"] = job
+	prev["__address__"] = g.Address
+	for _, step := range g.Relabel {
+		if step.Dropped {
+			fmt.Fprintf(b, "    %s (%s): dropped\n", step.Rule, step.Action)
+			continue
+		}
+
+		fmt.Fprintf(b, "    %s (%s): %s\n", step.Rule, step.Action, formatLabelChanges(prev, step.Labels))
+		prev = step.Labels
+	}
+
+	if g.Dropped {
+		if len(g.Relabel) == 0 || !g.Relabel[len(g.Relabel)-1].Dropped {
+			b.WriteString("    dropped: empty address\n")
+		}
+
+		return
+	}
+
+	fmt.Fprintf(b, "    labels: %s\n", formatLabels(g.Labels))
+}
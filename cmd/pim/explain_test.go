@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainExplain(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	config.Jobs = map[string]*core.JobConfig{"node-exporter": {Port: 9100}}
+
+	t.Run("Text", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "explain", "explain_target": "node1.example.com"}
+		err := explain(logger, config)
+		require.NoError(err, "explain returned an unexpected error")
+
+		out := buf.String()
+		require.Contains(out, "job node-exporter target node1.example.com:9100\n")
+		require.Contains(out, "job mysql-exporter target node1.example.com\n")
+		require.Contains(out, "    address: node1.example.com -> node1.example.com:9100\n")
+		require.Contains(out, `    group labels: {datacenter="us-east-1", environment="stg"}`)
+		require.Contains(out, "  file: node-exporter_targets.json\n")
+	})
+
+	t.Run("Job", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{
+			"command":        "explain",
+			"explain_target": "node1.example.com:9100",
+			"explain_job":    "node-exporter",
+			"output":         "json",
+		}
+		err := explain(logger, config)
+		require.NoError(err, "explain returned an unexpected error")
+		require.Contains(buf.String(), `"job": "node-exporter"`)
+		require.NotContains(buf.String(), `"job": "mysql-exporter"`)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		config.Flags = core.Flags{"command": "explain", "explain_target": "node1.example.com", "explain_job": "blackbox_icmp"}
+		err := explain(logger, config)
+		require.ErrorIs(err, os.ErrNotExist, "explain did not return an ErrNotExist")
+		require.EqualError(err, "explain: file does not exist: target node1.example.com is not in job blackbox_icmp")
+	})
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
 )
 
 var help = `
@@ -17,24 +19,138 @@ Options:
 	--version			Print the version.
 	-c, --config-file <path>	Path to the configuration file.
 	--export-first			Export targets before running any other commands.
-	-e, --export-types <type>	Comma separated list of file export types (e.g. file_sd).
+	-e, --export-types <type>	Comma separated list of export types: file_sd, http_sd.
 	-s, --sources <path>		Path to file or directory to read in the traget groups from.
 	-t, --targets <path>		Path to the targets output directory.
 	--targets-ext		Targets output file extension (.yml, .ymal, .json, etc.).
 	--targets-suffix <string>	Change the suffix of the exported targets files.
 					Default "_targets". To remove use "".
+	--target-split <labels>		Comma separated list of labels to split the targets files
+					by (e.g. job,datacenter). Files are always split by job.
 	-v, --verbose			Enable verbose logging.
 
 Commands:
 	export
-		pim [options] export [<sources> [targets_dir]]
+		pim [options] export [--dry-run] [<sources> [targets_dir]]
 
 		Options:
 		sources			File or directory to read in the target groups from.
 		targets_dir		Directory to write the targets files to.
+		--dry-run		Print the changes export would make without writing anything.
+
+	diff
+		pim [options] diff [-o <format>] [<sources> [targets_dir]]
+
+		Compare the targets files an export would write with the files in targets_dir.
+		Exits with status 2 if an export would change any targets files.
+
+		Options:
+		sources			File or directory to read in the target groups from.
+		targets_dir		Directory containing the current targets files.
+		-o, --output		Output format: text, json, or yaml. Default text.
+
+	validate
+		pim [options] validate [-o <format>] [<sources>]
+
+		Check every source file and report all problems found with file and line
+		numbers. Exits with status 1 if any problems are found.
+
+		Options:
+		sources			File or directory to read in the target groups from.
+		-o, --output		Output format: text, json, or yaml. Default text.
+
+	explain
+		pim [options] explain [-j <job>] [-o <format>] <target> [<sources>]
+
+		Print every source file and group target is in, the labels each one adds, the job
+		defaults and relabel steps applied, and the final labels and targets file. target
+		can be the address in the sources or the exported address.
+
+		Options:
+		target			Address of the target to explain.
+		sources			File or directory to read in the target groups from.
+		-j, --job		Only explain the target for this job.
+		-o, --output		Output format: text, json, or yaml. Default text.
+
+	list
+		pim [options] list [-o <format>] <jobs|targets|labels> [<matcher>...]
+
+		List the jobs, targets or label values of the targets an export would write. Only
+		targets that match every matcher are included. See query.
+
+		Options:
+		-o, --output		Output format: text (a table), json, or yaml. Default text.
+
+	query
+		pim [options] query [-o <format>] [<matcher>...]
+
+		Print the targets an export would write that match every matcher, with their
+		labels. Matchers work like Prometheus label matchers, e.g. environment="prod",
+		rack=~"r0.*" or {job="node",datacenter!="atl"}. Use __address__ to match the
+		target address.
+
+		Options:
+		-o, --output		Output format: text (a table), json, or yaml. Default text.
+
+	target
+		pim [options] target add [--jobs <jobs>] [--label <name>=<value>]... [--group <name>]
+			[--file <name>] <target> [<sources>]
+		pim [options] target remove [--group <name>] [--file <name>] <target> [<sources>]
+
+		Add a target to or remove it from the source files without losing the comments and
+		key order of YAML files. A file is only written if it is still valid afterwards.
+		add puts the target in the first group with exactly the given jobs and labels, or in
+		a new group if there is none. remove takes the target out of every group it is in
+		and removes groups with no targets left.
+
+		Options:
+		target			Address of the target as written in the sources.
+		sources			File or directory to read in the target groups from.
+		--jobs			Comma separated list of jobs. Required for new groups.
+		-l, --label		Label to set on the group, or on the target only when adding to an
+					existing group with --group. Can be given more than once.
+		--group			Name of the group to add the target to or remove it from. A new
+					group is created with this name if there is none.
+		--file			Name of the source file to edit.
+
+	fmt
+		pim [options] fmt [--check] [<sources>]
+
+		Rewrite every writable source file in canonical form: keys in the same order, jobs
+		sorted, labels sorted by name, target addresses normalized and repeated targets
+		removed. Comments in YAML files are kept.
+
+		Options:
+		sources			File or directory to read in the target groups from.
+		--check			List the files that are not formatted without changing them.
+					Exits with status 1 if any are found.
+
+	import
+		pim [options] import [--force] [<targets_dir> [sources]]
+
+		Read the Prometheus file_sd files in targets_dir and write them to a single sources
+		file. The job label of each group becomes its jobs and groups with the same labels
+		and targets in more than one job are merged.
+
+		Options:
+		targets_dir		Directory containing the file_sd files to import.
+		sources			File or directory to write the sources to. If sources is a
+					directory, targets.yml is written to it.
+		--force			Replace the sources file if it exists.
+
+	watch
+		pim [options] watch [--interval <seconds>] [<sources> [targets_dir]]
+
+		Export the targets and export them again every time the sources change. If the
+		sources fail to load, the last good targets files are kept.
+
+		Options:
+		sources			File or directory to read in the target groups from.
+		targets_dir		Directory to write the targets files to.
+		--interval		How often to check the sources for changes in seconds. Default 2.
 	
 	run
-		pim [options] run [<url>]
+		pim [options] run [--watch] [--api] [<url>]
 
 		If set --targets, --targets-suffix, and --targets-ext will be used to serve the
 		/targets endpoint.
@@ -48,10 +164,15 @@ Commands:
 		--tls-certfile		Location of the cert file to use for tls.
 		--tls-keyfile		Location of the key file to use for tls.
 		--server-timeout	Server shutdown timeout in seconds
+		--watch			Export the targets every time the sources change.
+		--api			Enable the /api/v1 routes for changing the sources.
 `
 
 //	-e, --env <env>			Environment to run the server in.
 
+// outputFormats are the formats accepted by --output.
+var outputFormats = []string{"text", "json", "yaml"}
+
 // func printHelp(logger *core.Logger) {
 func printHelp() string {
 	return fmt.Sprintf("%s\n%s", Version(), help)
@@ -102,6 +223,24 @@ func parseArgs(args []string) (core.Flags, error) {
 	switch args[0] {
 	case "export":
 		args, err = parseExportCommand(flags, args)
+	case "diff":
+		args, err = parseDiffCommand(flags, args)
+	case "validate":
+		args, err = parseValidateCommand(flags, args)
+	case "explain":
+		args, err = parseExplainCommand(flags, args)
+	case "import":
+		args, err = parseImportCommand(flags, args)
+	case "list":
+		args, err = parseListCommand(flags, args)
+	case "target":
+		args, err = parseTargetCommand(flags, args)
+	case "fmt":
+		args, err = parseFmtCommand(flags, args)
+	case "query":
+		args, err = parseQueryCommand(flags, args)
+	case "watch":
+		args, err = parseWatchCommand(flags, args)
 	case "run":
 		args, err = parseRunCommand(flags, args)
 	}
@@ -195,6 +334,13 @@ func parseOptions(args []string) (core.Flags, []string, error) {
 			}
 
 			flags["targets_file_suffix"] = v
+		case "--target-split":
+			i, v, err = getNextValue(args, i)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w for %s", err, f)
+			}
+
+			flags["target_split"] = v
 		case "-v", "--verbose":
 			flags["debug"] = "true"
 		default:
@@ -234,6 +380,445 @@ func parseExportCommand(
 		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
 	}
 
+	args, err := parseExportOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["sources"] = v
+		case 2:
+			flags["targets_dir"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+func parseExportOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for _, f := range args {
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		switch f {
+		case "--dry-run":
+			flags["dry_run"] = "true"
+		default:
+			return nil, fmt.Errorf("export: %w %s", os.ErrInvalid, f)
+		}
+	}
+
+	return a, nil
+}
+
+// parseDiffCommand parses arguements for diff and returns any remaining args along with an error.
+func parseDiffCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseOutputOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["sources"] = v
+		case 2:
+			flags["targets_dir"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+// parseValidateCommand parses arguements for validate and returns any remaining args along with
+// an error.
+func parseValidateCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseOutputOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["sources"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+// parseExplainCommand parses arguements for explain and returns any remaining args along with an
+// error.
+func parseExplainCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseExplainOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf("explain: %w: missing target", os.ErrInvalid)
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["explain_target"] = v
+		case 2:
+			flags["sources"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+// parseExplainOptions reads --job and leaves the remaining options to parseOutputOptions.
+func parseExplainOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for i := 0; i < len(args); i++ {
+		var v string
+		var err error
+		f := args[i]
+		if f != "-j" && f != "--job" && !strings.HasPrefix(f, "--job=") {
+			a = append(a, f)
+			continue
+		}
+
+		i, v, err = getNextValue(args, i)
+		if err != nil {
+			return nil, fmt.Errorf("explain: %w", err)
+		}
+
+		flags["explain_job"] = v
+	}
+
+	return parseOutputOptions(flags, a)
+}
+
+// parseListCommand parses arguements for list and returns any remaining args along with an error.
+func parseListCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseOutputOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 || !slices.Contains(listTypes, args[1]) {
+		return nil, fmt.Errorf("list: %w: must list one of: %s", os.ErrInvalid, strings.Join(listTypes, ", "))
+	}
+
+	flags["command"] = args[0]
+	flags["list_type"] = args[1]
+	return nil, setMatchers(flags, args[0], args[2:])
+}
+
+// parseQueryCommand parses arguements for query and returns any remaining args along with an
+// error.
+func parseQueryCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseOutputOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	flags["command"] = args[0]
+	return nil, setMatchers(flags, args[0], args[1:])
+}
+
+// setMatchers checks matchers and stores them in flags one per line.
+func setMatchers(flags core.Flags, command string, matchers []string) error {
+	if len(matchers) == 0 {
+		return nil
+	}
+
+	if _, err := targets.ParseMatchers(matchers...); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+
+	flags["query_matchers"] = strings.Join(matchers, "\n")
+	return nil
+}
+
+// parseTargetCommand parses arguements for target and returns any remaining args along with an
+// error.
+func parseTargetCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseTargetOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < 2 || !slices.Contains(targetActions, args[1]) {
+		return nil, fmt.Errorf("target: %w: must be one of: %s", os.ErrInvalid, strings.Join(targetActions, ", "))
+	}
+
+	if len(args) < 3 {
+		return nil, fmt.Errorf("target: %w: missing target", os.ErrInvalid)
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["target_action"] = v
+		case 2:
+			flags["target_address"] = v
+		case 3:
+			flags["sources"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	if flags["target_action"] == "remove" && (flags["target_jobs"] != "" || flags["target_labels"] != "") {
+		return nil, fmt.Errorf("target: %w: remove does not take --jobs or --label", os.ErrInvalid)
+	}
+
+	return a, nil
+}
+
+func parseTargetOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+	labels := make([]string, 0)
+
+	for i := 0; i < len(args); i++ {
+		var v string
+		var err error
+		f := args[i]
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		var key string
+		switch {
+		case f == "--jobs" || strings.HasPrefix(f, "--jobs="):
+			key = "target_jobs"
+		case f == "-l" || f == "--label" || strings.HasPrefix(f, "--label="):
+			key = "target_labels"
+		case f == "--group" || strings.HasPrefix(f, "--group="):
+			key = "target_group"
+		case f == "--file" || strings.HasPrefix(f, "--file="):
+			key = "target_file"
+		default:
+			return nil, fmt.Errorf("target: %w %s", os.ErrInvalid, f)
+		}
+
+		i, v, err = getNextValue(args, i)
+		if err != nil {
+			return nil, fmt.Errorf("target: %w", err)
+		}
+
+		if key != "target_labels" {
+			flags[key] = v
+			continue
+		}
+
+		if name, _, ok := strings.Cut(v, "="); !ok || name == "" {
+			return nil, fmt.Errorf("target: %w: label must be <name>=<value>: %s", os.ErrInvalid, v)
+		}
+
+		labels = append(labels, v)
+	}
+
+	if len(labels) > 0 {
+		flags["target_labels"] = strings.Join(labels, "\n")
+	}
+
+	return a, nil
+}
+
+// parseFmtCommand parses arguements for fmt and returns any remaining args along with an error.
+func parseFmtCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseFmtOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["sources"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+func parseFmtOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for _, f := range args {
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		switch f {
+		case "--check":
+			flags["check"] = "true"
+		default:
+			return nil, fmt.Errorf("fmt: %w %s", os.ErrInvalid, f)
+		}
+	}
+
+	return a, nil
+}
+
+// parseImportCommand parses arguements for import and returns any remaining args along with an
+// error.
+func parseImportCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseImportOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range args {
+		switch i {
+		case 0:
+			flags["command"] = v
+		case 1:
+			flags["targets_dir"] = v
+		case 2:
+			flags["sources"] = v
+		default:
+			a = append(a, v)
+		}
+	}
+
+	return a, nil
+}
+
+func parseImportOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for _, f := range args {
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		switch f {
+		case "--force":
+			flags["force"] = "true"
+		default:
+			return nil, fmt.Errorf("import: %w %s", os.ErrInvalid, f)
+		}
+	}
+
+	return a, nil
+}
+
+// parseWatchCommand parses arguements for watch and returns any remaining args along with an
+// error.
+func parseWatchCommand(
+	flags core.Flags,
+	args []string,
+) ([]string, error) {
+	var a []string
+
+	if len(args) < 1 {
+		return nil, fmt.Errorf("%w: no command found", os.ErrInvalid)
+	}
+
+	args, err := parseWatchOptions(flags, args)
+	if err != nil {
+		return nil, err
+	}
+
 	for i, v := range args {
 		switch i {
 		case 0:
@@ -250,6 +835,73 @@ func parseExportCommand(
 	return a, nil
 }
 
+func parseWatchOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for i := 0; i < len(args); i++ {
+		var v string
+		var err error
+		f := args[i]
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		switch {
+		case f == "--interval" || strings.HasPrefix(f, "--interval="):
+			i, v, err = getNextValue(args, i)
+			if err != nil {
+				return nil, fmt.Errorf("watch: %w", err)
+			}
+
+			flags["watch_interval"] = v
+		default:
+			return nil, fmt.Errorf("watch: %w %s", os.ErrInvalid, f)
+		}
+	}
+
+	return a, nil
+}
+
+// parseOutputOptions parses the output format option used by commands that print results.
+func parseOutputOptions(flags core.Flags, args []string) ([]string, error) {
+	var a []string
+
+	for i := 0; i < len(args); i++ {
+		var v string
+		var err error
+		f := args[i]
+		if !strings.HasPrefix(f, "-") {
+			a = append(a, f)
+			continue
+		}
+
+		switch {
+		case f == "-o" || f == "--output" || strings.HasPrefix(f, "--output="):
+			i, v, err = getNextValue(args, i)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", args[0], err)
+			}
+
+			if !slices.Contains(outputFormats, v) {
+				return nil, fmt.Errorf(
+					"%s: %w output: %s; must be one of: %s",
+					args[0],
+					os.ErrInvalid,
+					v,
+					strings.Join(outputFormats, ", "),
+				)
+			}
+
+			flags["output"] = v
+		default:
+			return nil, fmt.Errorf("%s: %w %s", args[0], os.ErrInvalid, f)
+		}
+	}
+
+	return a, nil
+}
+
 // parseRunCommand parse arguements for the http server and returns any remaining args along
 // with an error.
 func parseRunCommand(
@@ -326,6 +978,10 @@ func parseRunOptions(flags core.Flags, args []string) ([]string, error) {
 			}
 
 			flags["http_tls_certfile"] = v
+		case "--watch":
+			flags["watch"] = "true"
+		case "--api":
+			flags["http_api_enabled"] = "true"
 		default:
 			return nil, fmt.Errorf("run: %w %s", os.ErrInvalid, f)
 		}
@@ -67,6 +67,7 @@ var flagTests = []testFlags{
 			"--sources":        "/tmp/source.file",
 			"--targets":        "/tmp/targets",
 			"--targets-suffix": "_sd_targets",
+			"--target-split":   "job,datacenter",
 		},
 		wantFlags: core.Flags{
 			"debug":               "true",
@@ -76,6 +77,7 @@ var flagTests = []testFlags{
 			"sources":             "/tmp/source.file",
 			"targets_dir":         "/tmp/targets",
 			"targets_file_suffix": "_sd_targets",
+			"target_split":        "job,datacenter",
 			"command":             "export",
 		},
 		args: []string{"export"},
@@ -177,6 +179,342 @@ func TestFlagsParseExportCommand(t *testing.T) {
 	})
 }
 
+func TestFlagsParseExportDryRun(t *testing.T) {
+	require := require.New(t)
+
+	want := core.Flags{"command": "export", "dry_run": "true", "sources": "/tmp/sources"}
+	flags := make(core.Flags)
+	r, err := parseExportCommand(flags, []string{"export", "--dry-run", "/tmp/sources"})
+	require.NoError(err, "parseExportCommand returned an unexpected error")
+	require.Equal(want, flags, "flags did not match")
+	require.Empty(r, "remainder not empty")
+
+	_, err = parseExportCommand(make(core.Flags), []string{"export", "--invalid"})
+	require.ErrorIs(err, os.ErrInvalid, "parseExportCommand did not return the correct error")
+}
+
+func TestFlagsParseDiffCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseDiffCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":     "diff",
+			"output":      "json",
+			"sources":     "/tmp/sources",
+			"targets_dir": "/tmp/targets",
+		}
+		flags := make(core.Flags)
+		args := []string{"diff", "-o", "json", "/tmp/sources", "/tmp/targets"}
+		r, err := parseDiffCommand(flags, args)
+		require.NoError(err, "parseDiffCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("InvalidOutput", func(t *testing.T) {
+		_, err := parseDiffCommand(make(core.Flags), []string{"diff", "--output=xml"})
+		require.ErrorIs(err, os.ErrInvalid, "parseDiffCommand did not return the correct error")
+	})
+}
+
+func TestFlagsParseValidateCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseValidateCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command": "validate",
+			"output":  "yaml",
+			"sources": "/tmp/sources",
+		}
+		flags := make(core.Flags)
+		r, err := parseValidateCommand(flags, []string{"validate", "/tmp/sources", "--output", "yaml"})
+		require.NoError(err, "parseValidateCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("ExtraArgs", func(t *testing.T) {
+		r, err := parseValidateCommand(make(core.Flags), []string{"validate", "/tmp/sources", "/tmp/targets"})
+		require.NoError(err, "parseValidateCommand returned an unexpected error")
+		require.Equal([]string{"/tmp/targets"}, r, "remainder did not match")
+	})
+}
+
+func TestFlagsParseFmtCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseFmtCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{"command": "fmt", "check": "true", "sources": "/tmp/sources"}
+		flags := make(core.Flags)
+		r, err := parseFmtCommand(flags, []string{"fmt", "/tmp/sources", "--check"})
+		require.NoError(err, "parseFmtCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("UnknownOption", func(t *testing.T) {
+		r, err := parseFmtCommand(make(core.Flags), []string{"fmt", "--write"})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+}
+
+func TestFlagsParseExplainCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseExplainCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("NoTarget", func(t *testing.T) {
+		_, err := parseExplainCommand(make(core.Flags), []string{"explain", "--job", "node"})
+		require.ErrorIs(err, os.ErrInvalid, "parseExplainCommand did not return the correct error")
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":        "explain",
+			"explain_job":    "node",
+			"explain_target": "host01:9100",
+			"output":         "yaml",
+			"sources":        "/tmp/sources",
+		}
+		flags := make(core.Flags)
+		args := []string{"explain", "-j", "node", "host01:9100", "-o", "yaml", "/tmp/sources"}
+		r, err := parseExplainCommand(flags, args)
+		require.NoError(err, "parseExplainCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		_, err := parseExplainCommand(make(core.Flags), []string{"explain", "--job"})
+		require.ErrorIs(err, os.ErrInvalid, "parseExplainCommand did not return the correct error")
+
+		_, err = parseExplainCommand(make(core.Flags), []string{"explain", "--invalid", "host01"})
+		require.ErrorIs(err, os.ErrInvalid, "parseExplainCommand did not return the correct error")
+	})
+}
+
+func TestFlagsParseListCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseListCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":        "list",
+			"list_type":      "targets",
+			"output":         "json",
+			"query_matchers": "job=\"node\"\nrack=~\"r0.*\"",
+		}
+		flags := make(core.Flags)
+		r, err := parseListCommand(flags, []string{"list", "targets", `job="node"`, "-o", "json", `rack=~"r0.*"`})
+		require.NoError(err, "parseListCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		_, err := parseListCommand(make(core.Flags), []string{"list", "files"})
+		require.ErrorIs(err, os.ErrInvalid, "parseListCommand did not return the correct error")
+
+		_, err = parseListCommand(make(core.Flags), []string{"list"})
+		require.ErrorIs(err, os.ErrInvalid, "parseListCommand did not return the correct error")
+	})
+
+	t.Run("InvalidMatcher", func(t *testing.T) {
+		_, err := parseListCommand(make(core.Flags), []string{"list", "jobs", "job"})
+		require.ErrorIs(err, os.ErrInvalid, "parseListCommand did not return the correct error")
+	})
+}
+
+func TestFlagsParseQueryCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseQueryCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":        "query",
+			"output":         "yaml",
+			"query_matchers": `{job="node",environment!="prod"}`,
+		}
+		flags := make(core.Flags)
+		r, err := parseQueryCommand(flags, []string{"query", "--output=yaml", `{job="node",environment!="prod"}`})
+		require.NoError(err, "parseQueryCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("NoMatchers", func(t *testing.T) {
+		flags := make(core.Flags)
+		_, err := parseQueryCommand(flags, []string{"query"})
+		require.NoError(err, "parseQueryCommand returned an unexpected error")
+		require.Equal(core.Flags{"command": "query"}, flags, "flags did not match")
+	})
+
+	t.Run("InvalidMatcher", func(t *testing.T) {
+		_, err := parseQueryCommand(make(core.Flags), []string{"query", `rack=~"("`})
+		require.ErrorIs(err, os.ErrInvalid, "parseQueryCommand did not return the correct error")
+	})
+}
+
+func TestFlagsParseTargetCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseTargetCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		want := core.Flags{
+			"command":        "target",
+			"target_action":  "add",
+			"target_address": "host01",
+			"target_jobs":    "node,blackbox_icmp",
+			"target_labels":  "rack=r01\nenvironment=prod",
+			"target_group":   "web",
+			"target_file":    "web_targets.yml",
+			"sources":        "/tmp/sources",
+		}
+		flags := make(core.Flags)
+		args := []string{
+			"target", "add", "--jobs", "node,blackbox_icmp", "-l", "rack=r01", "--label=environment=prod",
+			"--group", "web", "--file=web_targets.yml", "host01", "/tmp/sources",
+		}
+		r, err := parseTargetCommand(flags, args)
+		require.NoError(err, "parseTargetCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		want := core.Flags{"command": "target", "target_action": "remove", "target_address": "host01"}
+		flags := make(core.Flags)
+		r, err := parseTargetCommand(flags, []string{"target", "remove", "host01"})
+		require.NoError(err, "parseTargetCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"NoAction", []string{"target"}},
+		{"InvalidAction", []string{"target", "move", "host01"}},
+		{"NoTarget", []string{"target", "add", "--jobs", "node"}},
+		{"InvalidLabel", []string{"target", "add", "--label", "rack", "host01"}},
+		{"InvalidOption", []string{"target", "add", "--force", "host01"}},
+		{"RemoveLabels", []string{"target", "remove", "--label", "rack=r01", "host01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTargetCommand(make(core.Flags), tt.args)
+			require.ErrorIs(err, os.ErrInvalid, "parseTargetCommand did not return the correct error")
+		})
+	}
+}
+
+func TestFlagsParseImportCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseImportCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":     "import",
+			"force":       "true",
+			"targets_dir": "/tmp/targets",
+			"sources":     "/tmp/sources",
+		}
+		flags := make(core.Flags)
+		r, err := parseImportCommand(flags, []string{"import", "/tmp/targets", "/tmp/sources", "--force"})
+		require.NoError(err, "parseImportCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		_, err := parseImportCommand(make(core.Flags), []string{"import", "--invalid"})
+		require.ErrorIs(err, os.ErrInvalid, "parseImportCommand did not return the correct error")
+	})
+}
+
+func TestFlagsParseWatchCommand(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("NoArgs", func(t *testing.T) {
+		r, err := parseWatchCommand(make(core.Flags), []string{})
+		require.ErrorIs(err, os.ErrInvalid)
+		require.Nil(r)
+	})
+
+	t.Run("WithOptions", func(t *testing.T) {
+		want := core.Flags{
+			"command":        "watch",
+			"watch_interval": "10",
+			"sources":        "/tmp/sources",
+			"targets_dir":    "/tmp/targets",
+		}
+		flags := make(core.Flags)
+		args := []string{"watch", "--interval", "10", "/tmp/sources", "/tmp/targets"}
+		r, err := parseWatchCommand(flags, args)
+		require.NoError(err, "parseWatchCommand returned an unexpected error")
+		require.Equal(want, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		_, err := parseWatchCommand(make(core.Flags), []string{"watch", "--invalid"})
+		require.ErrorIs(err, os.ErrInvalid, "parseWatchCommand did not return the correct error")
+	})
+
+	t.Run("RunWatch", func(t *testing.T) {
+		flags := make(core.Flags)
+		r, err := parseRunCommand(flags, []string{"run", "--watch"})
+		require.NoError(err, "parseRunCommand returned an unexpected error")
+		require.Equal(core.Flags{"command": "run", "watch": "true"}, flags, "flags did not match")
+		require.Empty(r, "remainder not empty")
+	})
+}
+
 func TestFlagsParseArgs(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// formatSources rewrites every writable source file in canonical form. With --check nothing is
+// written and an error is returned if any file is not formatted so CI can fail on it.
+func formatSources(logger *core.Logger, config *core.Config) error {
+	check := config.Flags["check"] == "true"
+
+	logger.Debugf("fmt: formatting sources in %s\n", config.Sources)
+	results, err := targets.FormatSources(config, !check)
+	changed := 0
+	for _, r := range results {
+		if r.Status == targets.FileUnchanged {
+			logger.Debugf("fmt: %s is formatted\n", r.File)
+			continue
+		}
+
+		changed++
+		if check {
+			logger.PrintOutf("%s is not formatted\n", r.File)
+			continue
+		}
+
+		logger.PrintOutf("formatted %s\n", r.File)
+	}
+
+	if err != nil {
+		return fmt.Errorf("fmt: %w", err)
+	}
+
+	if check && changed > 0 {
+		return fmt.Errorf("fmt: %d of %d source files are not formatted", changed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainFormatSources(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	sfile := filepath.Join(config.Sources, "targets.yml")
+	source := "- targets: [Host02, host01, host02] # web\n  jobs: [node, blackbox]\n"
+	err := core.WriteFile(sfile, []byte(source), 0o644)
+	require.NoError(err, "failed to write sources file to %s", sfile)
+
+	t.Run("Check", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "fmt", "check": "true"}
+		err := formatSources(logger, config)
+		require.EqualError(err, "fmt: 1 of 1 source files are not formatted")
+		require.Equal(sfile+" is not formatted\n", buf.String())
+
+		data, err := os.ReadFile(sfile)
+		require.NoError(err, "failed to read %s", sfile)
+		require.Equal(source, string(data), "file was written")
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "fmt"}
+		err := formatSources(logger, config)
+		require.NoError(err, "formatSources returned an unexpected error")
+		require.Equal("formatted "+sfile+"\n", buf.String())
+
+		data, err := os.ReadFile(sfile)
+		require.NoError(err, "failed to read %s", sfile)
+		require.Equal("- jobs:\n    - blackbox\n    - node\n  targets: # web\n    - host02\n    - host01\n", string(data))
+	})
+
+	t.Run("Formatted", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "fmt", "check": "true"}
+		err := formatSources(logger, config)
+		require.NoError(err, "formatSources returned an unexpected error")
+		require.Empty(buf.String(), "output not empty")
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// importSourceFile is the file import writes when sources is a directory.
+const importSourceFile = "targets.yml"
+
+// importTargets reads the file_sd files in the targets dir and writes them to a single sources
+// file. An existing sources file is only replaced with --force.
+func importTargets(logger *core.Logger, config *core.Config) error {
+	if targets.IsRemote(config.Sources) {
+		return fmt.Errorf("import: %w: can not write to remote sources: %s", os.ErrInvalid, config.Sources)
+	}
+
+	if len(config.SourceList) > 0 {
+		return fmt.Errorf("import: %w: sources is a list; give the sources file to write", os.ErrInvalid)
+	}
+
+	file, err := importFile(config.Sources)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if config.Flags["force"] != "true" {
+		_, err := os.Stat(file)
+		if err == nil {
+			return fmt.Errorf("import: %w: %s; use --force to replace it", os.ErrExist, file)
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+
+	logger.Debugf("import: reading file_sd targets from %s\n", config.TargetsDir)
+	tgs, err := targets.ImportTargets(config, config.TargetsDir)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	if err := targets.WriteSourceFile(file, tgs); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	logger.PrintOutf("imported %d target groups to %s\n", len(tgs), file)
+	return nil
+}
+
+// importFile returns the sources file to write. A directory, or a missing sources path without a
+// file extension, gets importSourceFile and is created when missing. A new importSourceFile is
+// refused when the directory holds *_targets source files, since it would hide them.
+func importFile(sources string) (string, error) {
+	info, err := os.Stat(sources)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err != nil {
+		if filepath.Ext(sources) != "" && !strings.HasSuffix(sources, string(filepath.Separator)) {
+			return sources, nil
+		}
+
+		if err := os.MkdirAll(sources, 0o755); err != nil {
+			return "", err
+		}
+	} else if !info.IsDir() {
+		return sources, nil
+	}
+
+	file := filepath.Join(sources, importSourceFile)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+
+	files, err := targets.PatternSourceFiles(sources)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) > 0 {
+		return "", fmt.Errorf(
+			"%w: %s would hide the source files %s; give a file name to write",
+			os.ErrExist, file, strings.Join(files, ", "),
+		)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainImport(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+
+	// Export the test sources so there are file_sd files to import.
+	config.Flags = core.Flags{"command": "export"}
+	err := export(logger, config)
+	require.NoError(err, "export returned an unexpected error")
+
+	config.Flags = core.Flags{"command": "import"}
+
+	t.Run("Exists", func(t *testing.T) {
+		err := importTargets(logger, config)
+		require.ErrorIs(err, os.ErrExist, "import did not return the correct error")
+	})
+
+	t.Run("Force", func(t *testing.T) {
+		sfile := filepath.Join(config.Sources, "targets.yml")
+		old, err := targets.NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+
+		buf.Reset()
+		config.Flags["force"] = "true"
+		err = importTargets(logger, config)
+		require.NoError(err, "import returned an unexpected error")
+		require.Contains(buf.String(), "target groups to "+sfile)
+
+		tgs, err := targets.NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Equal(old.JobGroups(config), tgs.JobGroups(config), "imported targets did not match")
+
+		// The imported sources export to the same targets files.
+		config.Flags = core.Flags{"command": "diff"}
+		err = diff(logger, config)
+		require.NoError(err, "imported sources did not match the targets files")
+	})
+
+	t.Run("File", func(t *testing.T) {
+		config.Sources = filepath.Join(tempDir, "imported.json")
+		config.Flags = core.Flags{"command": "import"}
+		err := importTargets(logger, config)
+		require.NoError(err, "import returned an unexpected error")
+		require.FileExists(config.Sources, "sources file was not written")
+	})
+
+	t.Run("NewDir", func(t *testing.T) {
+		config.Sources = filepath.Join(tempDir, "new", "sources")
+		err := importTargets(logger, config)
+		require.NoError(err, "import returned an unexpected error")
+		require.FileExists(filepath.Join(config.Sources, "targets.yml"), "sources file was not written")
+	})
+
+	t.Run("DirWithExt", func(t *testing.T) {
+		config.Sources = filepath.Join(tempDir, "sources.d")
+		err := os.MkdirAll(config.Sources, 0o755)
+		require.NoError(err, "failed to create sources dir")
+
+		err = importTargets(logger, config)
+		require.NoError(err, "import returned an unexpected error")
+		require.FileExists(filepath.Join(config.Sources, "targets.yml"), "sources file was not written")
+	})
+
+	t.Run("PatternSources", func(t *testing.T) {
+		config.Sources = filepath.Join(tempDir, "pattern")
+		err := os.MkdirAll(config.Sources, 0o755)
+		require.NoError(err, "failed to create sources dir")
+
+		pfile := filepath.Join(config.Sources, "web_targets.yml")
+		err = core.WriteFile(pfile, []byte(yamlSource), 0o644)
+		require.NoError(err, "failed to write sources file to %s", pfile)
+
+		err = importTargets(logger, config)
+		require.ErrorIs(err, os.ErrExist, "import did not return the correct error")
+		require.ErrorContains(err, pfile, "import error did not name the hidden file")
+		require.NoFileExists(filepath.Join(config.Sources, "targets.yml"), "sources file was written")
+	})
+}
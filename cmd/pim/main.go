@@ -7,8 +7,13 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/chadeldridge/prometheus-import-manager/api"
 	"github.com/chadeldridge/prometheus-import-manager/core"
 	"github.com/chadeldridge/prometheus-import-manager/router"
 	"github.com/chadeldridge/prometheus-import-manager/targets"
@@ -16,12 +21,46 @@ import (
 )
 
 var commands = map[string]bool{
-	"export": true,
-	"run":    true,
+	"export":   true,
+	"diff":     true,
+	"explain":  true,
+	"fmt":      true,
+	"import":   true,
+	"list":     true,
+	"query":    true,
+	"run":      true,
+	"target":   true,
+	"validate": true,
+	"watch":    true,
+}
+
+// sdStore holds the target groups served by the http_sd endpoint. export updates it when the
+// http_sd export type is enabled.
+var sdStore = targets.NewStore()
+
+// exportMu serializes exports started by the api, watch and export_first.
+var exportMu sync.Mutex
+
+// noExportFirst lists the commands that should never run an export first, either because they
+// export themselves or because they only inspect the sources and targets.
+var noExportFirst = map[string]bool{
+	"export":   true,
+	"diff":     true,
+	"explain":  true,
+	"fmt":      true,
+	"import":   true,
+	"list":     true,
+	"query":    true,
+	"target":   true,
+	"validate": true,
+	"watch":    true,
 }
 
 func main() {
-	ctx := context.Background()
+	// Cancel the context on an interrupt so long running commands can shutdown gracefully.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	logger, config, err := prep(os.Stdout, os.Args, getEnv())
 	if err != nil {
 		log.Printf("%v\n", err)
@@ -36,6 +75,10 @@ func main() {
 	}
 
 	err = handler(ctx, logger, config)
+	if errors.Is(err, errDrift) {
+		os.Exit(2)
+	}
+
 	if err != nil {
 		logger.Printf("%v\n", err)
 		if errors.Is(err, os.ErrInvalid) {
@@ -108,7 +151,7 @@ func handler(ctx context.Context, logger *core.Logger, config *core.Config) erro
 		return fmt.Errorf("handler: %w: missing command", os.ErrInvalid)
 	}
 
-	if config.ExportFirst && command != "export" {
+	if config.ExportFirst && !noExportFirst[command] {
 		logger.Debugf("handler: export_first: %s\n", config.ExportFirst)
 		logger.Debugf("handler: exporting targets before %s\n", command)
 		err := export(logger, config)
@@ -121,6 +164,33 @@ func handler(ctx context.Context, logger *core.Logger, config *core.Config) erro
 	case "export":
 		logger.Debug("running exporter")
 		return export(logger, config)
+	case "diff":
+		logger.Debug("running diff")
+		return diff(logger, config)
+	case "validate":
+		logger.Debug("running validate")
+		return validate(logger, config)
+	case "explain":
+		logger.Debug("running explain")
+		return explain(logger, config)
+	case "list":
+		logger.Debug("running list")
+		return list(logger, config)
+	case "query":
+		logger.Debug("running query")
+		return query(logger, config)
+	case "target":
+		logger.Debug("running target")
+		return editTarget(logger, config)
+	case "fmt":
+		logger.Debug("running fmt")
+		return formatSources(logger, config)
+	case "import":
+		logger.Debug("running import")
+		return importTargets(logger, config)
+	case "watch":
+		logger.Debug("running watch")
+		return watch(ctx, logger, config)
 	case "run":
 		logger.Debug("running http server")
 		return run(ctx, logger, config)
@@ -130,12 +200,41 @@ func handler(ctx context.Context, logger *core.Logger, config *core.Config) erro
 	return fmt.Errorf("handler: %w", os.ErrInvalid)
 }
 
-// export targets from sources to file_sd.
+// export targets from sources to file_sd and http_sd.
 func export(logger *core.Logger, config *core.Config) error {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+
+	if config.Flags["dry_run"] == "true" {
+		return dryRun(logger, config)
+	}
+
+	start := time.Now()
+	stats, err := exportTargets(logger, config)
+	targets.RecordExport(stats, time.Since(start), err)
+	return err
+}
+
+// exportTargets loads the sources and exports them to each export type. The counts of the loaded
+// sources are returned so export metrics can be recorded. They are nil if the sources failed to
+// load.
+func exportTargets(logger *core.Logger, config *core.Config) (*targets.ExportStats, error) {
 	logger.Debugf("export: importing targets from %s\n", config.Sources)
 	tgs, err := targets.NewTargetGroups(config)
 	if err != nil {
-		return fmt.Errorf("export: error loading source: %w", err)
+		return nil, fmt.Errorf("export: error loading source: %w", err)
+	}
+
+	conflicts := tgs.Conflicts(config)
+	logConflicts(logger, conflicts)
+	jobs := tgs.JobGroups(config)
+	if config.ExportTypes[core.ExportTypeHTTPSD] {
+		sdStore.Set(jobs)
+		logger.Debugf("export: updated http_sd targets for %d jobs\n", len(jobs))
+	}
+
+	if !config.ExportTypes[core.ExportTypeFileSD] {
+		return targets.NewExportStats(jobs, conflicts, nil), nil
 	}
 
 	logger.Debugf(
@@ -144,13 +243,53 @@ func export(logger *core.Logger, config *core.Config) error {
 		config.TargetsFileSuffix,
 		config.TargetsFileExt,
 	)
-	err = tgs.ExportTargets(config)
+	results, err := tgs.ExportTargets(config)
+	logResults(logger, results)
+	stats := targets.NewExportStats(jobs, conflicts, results)
 	if err != nil {
-		return fmt.Errorf("export: error exporting targets: %w", err)
+		return stats, fmt.Errorf("export: error exporting targets: %w", err)
 	}
 
 	logger.Debug("export: targets exported successfully")
-	return nil
+	return stats, nil
+}
+
+// dryRun prints the changes an export would make without writing anything.
+func dryRun(logger *core.Logger, config *core.Config) error {
+	logger.Debugf("export: importing targets from %s\n", config.Sources)
+	tgs, err := targets.NewTargetGroups(config)
+	if err != nil {
+		return fmt.Errorf("export: error loading source: %w", err)
+	}
+
+	logger.Debugf("export: dry run, comparing targets with %s\n", config.TargetsDir)
+	diffs, err := tgs.Diff(config)
+	if err != nil {
+		return fmt.Errorf("export: error comparing targets: %w", err)
+	}
+
+	return printDiffs(logger, diffs, config.Flags["output"])
+}
+
+// logResults logs each targets file that was changed by an export. Unchanged files are only
+// logged in debug mode.
+func logResults(logger *core.Logger, results targets.ExportResults) {
+	for _, r := range results {
+		if r.Status == targets.FileUnchanged {
+			logger.Debugf("export: %s %s\n", r.Status, r.File)
+			continue
+		}
+
+		logger.Printf("export: %s %s\n", r.Status, r.File)
+	}
+}
+
+// logConflicts warns about each target with conflicting labels. The export goes ahead using
+// merge_precedence to pick the labels.
+func logConflicts(logger *core.Logger, conflicts targets.Conflicts) {
+	for _, c := range conflicts {
+		logger.Printf("export: warning: %s\n", c)
+	}
 }
 
 // run allows us to setup and implement in testing and production.
@@ -165,15 +304,42 @@ func run(ctx context.Context, logger *core.Logger, config *core.Config) error {
 		return err
 	}
 
-	/*
-		// Add API routes.
-		err = api.AddRoutes(&srv)
+	// Add API routes.
+	if config.APIEnabled {
+		err = api.AddRoutes(&srv, func() error { return export(logger, config) })
 		if err != nil {
 			return err
 		}
-	*/
+	}
+
+	// Load the targets for http_sd before serving so Prometheus never sees an empty list.
+	if config.ExportTypes[core.ExportTypeHTTPSD] {
+		if err := loadSD(logger, config); err != nil {
+			return err
+		}
+
+		if err := web.AddSDRoutes(&srv, sdStore); err != nil {
+			return err
+		}
+	}
+
+	if config.Watch {
+		go watchSources(ctx, logger, config)
+	}
 
 	// Start API Server.
 	logger.Debug("run: starting http server")
 	return srv.Start(ctx, config.ShutdownTimeout)
 }
+
+// loadSD loads the sources into sdStore for the http_sd endpoint.
+func loadSD(logger *core.Logger, config *core.Config) error {
+	logger.Debugf("run: loading http_sd targets from %s\n", config.Sources)
+	tgs, err := targets.NewTargetGroups(config)
+	if err != nil {
+		return fmt.Errorf("run: error loading source: %w", err)
+	}
+
+	sdStore.Set(tgs.JobGroups(config))
+	return nil
+}
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/router"
+	"github.com/chadeldridge/prometheus-import-manager/web"
 	"github.com/stretchr/testify/require"
 )
 
@@ -57,12 +61,10 @@ var (
 	targetsFilesJSON = map[string]string{
 		"blackbox_icmp_targets.json": `[
   {
-    "jobs": [
-      "blackbox_icmp"
-    ],
     "labels": {
       "datacenter": "us-east-1",
       "environment": "prod",
+      "job": "blackbox_icmp",
       "role": "monitoring"
     },
     "targets": [
@@ -73,12 +75,10 @@ var (
 ]`,
 		"mysql-exporter_targets.json": `[
   {
-    "jobs": [
-      "mysql-exporter"
-    ],
     "labels": {
       "datacenter": "us-east-1",
-      "environment": "stg"
+      "environment": "stg",
+      "job": "mysql-exporter"
     },
     "targets": [
       "node1.example.com",
@@ -88,12 +88,10 @@ var (
 ]`,
 		"node-exporter_targets.json": `[
   {
-    "jobs": [
-      "node-exporter"
-    ],
     "labels": {
       "datacenter": "us-east-1",
-      "environment": "stg"
+      "environment": "stg",
+      "job": "node-exporter"
     },
     "targets": [
       "node1.example.com",
@@ -103,30 +101,27 @@ var (
 ]`,
 	}
 	targetsFilesYAML = map[string]string{
-		"blackbox_icmp_targets.yml": `- jobs:
-    - blackbox_icmp
-  labels:
+		"blackbox_icmp_targets.yml": `- labels:
     datacenter: us-east-1
     environment: prod
+    job: blackbox_icmp
     role: monitoring
   targets:
     - prom.example.com
     - grafana.example.com
 `,
-		"mysql-exporter_targets.yml": `- jobs:
-    - mysql-exporter
-  labels:
+		"mysql-exporter_targets.yml": `- labels:
     datacenter: us-east-1
     environment: stg
+    job: mysql-exporter
   targets:
     - node1.example.com
     - node2.example.com
 `,
-		"node-exporter_targets.yml": `- jobs:
-    - node-exporter
-  labels:
+		"node-exporter_targets.yml": `- labels:
     datacenter: us-east-1
     environment: stg
+    job: node-exporter
   targets:
     - node1.example.com
     - node2.example.com
@@ -160,9 +155,16 @@ func newBaseConfig() *core.Config {
 		ExportTypes:       map[string]bool{"file_sd": true},
 		ConfigFile:        core.DefaultConfigFile,
 		Sources:           core.DefaultSources,
+		SourcesCacheDir:   core.DefaultSourcesCacheDir,
+		SourcesTimeout:    core.DefaultSourcesTimeout,
+		SourceFormat:      core.DefaultSourceFormat,
 		TargetsDir:        core.DefaultTargetsDir,
 		TargetsFileExt:    core.DefaultTargetsFileExt,
 		TargetsFileSuffix: core.DefaultTargetsFileSuffix,
+		TargetSplit:       make([]string, 0),
+		Jobs:              make(map[string]*core.JobConfig),
+		MergePrecedence:   core.DefaultMergePrecedence,
+		WatchInterval:     core.DefaultWatchInterval,
 		APIHost:           core.DefaultAPIHost,
 		APIPort:           core.DefaultAPIPort,
 		ShutdownTimeout:   core.DefaultShutdownTimeout,
@@ -441,6 +443,39 @@ func TestMainExport(t *testing.T) {
 	})
 }
 
+func TestMainExportConflicts(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	config.Flags = core.Flags{"command": "export"}
+
+	sfile := filepath.Join(config.Sources, "targets.yml")
+	content := "- jobs: [node]\n  labels: {environment: prod}\n  targets: [host01]\n" +
+		"- jobs: [node]\n  labels: {environment: stg}\n  targets: [host01]\n"
+	err := core.WriteFile(sfile, []byte(content), 0o644)
+	require.NoError(err, "failed to write sources file to %s", sfile)
+
+	t.Run("Warning", func(t *testing.T) {
+		buf.Reset()
+		err := export(logger, config)
+		require.NoError(err, "export returned an unexpected error")
+		require.Contains(buf.String(), `export: warning: job node target "host01" has conflicting labels environment`)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		config.MergePrecedence = core.MergeError
+		defer func() { config.MergePrecedence = core.DefaultMergePrecedence }()
+
+		err := export(logger, config)
+		require.ErrorIs(err, os.ErrInvalid, "export did not return the correct error")
+		require.ErrorContains(err, "targets have conflicting labels", "export returned the wrong error")
+	})
+}
+
 func testRunServer(ctx context.Context, logger *core.Logger, config *core.Config) error {
 	// Capture the interrupt signal to gracefully shutdown the server.
 	ctx, cancel := context.WithCancel(context.Background())
@@ -561,3 +596,47 @@ func TestMainHandler(t *testing.T) {
 		)
 	})
 }
+
+func TestMainHTTPSD(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	config.Flags = core.Flags{"command": "export"}
+	config.ExportTypes = map[string]bool{core.ExportTypeHTTPSD: true}
+
+	t.Run("Export", func(t *testing.T) {
+		err := export(logger, config)
+		require.NoError(err, "export returned an unexpected error")
+		require.Equal([]string{"blackbox_icmp", "mysql-exporter", "node-exporter"}, sdStore.Jobs())
+
+		entries, err := os.ReadDir(config.TargetsDir)
+		require.NoError(err, "failed to read targets dir")
+		require.Empty(entries, "http_sd export wrote to the targets dir")
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		srv := router.NewHTTPServer(logger, config)
+		err := web.AddSDRoutes(&srv, sdStore)
+		require.NoError(err, "AddSDRoutes returned an unexpected error")
+
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sd/node-exporter", nil))
+		require.Equal(http.StatusOK, w.Code, "status code did not match")
+		require.Equal("application/json", w.Header().Get("Content-Type"))
+		require.JSONEq(
+			`[{"labels": {"datacenter": "us-east-1", "environment": "stg", "job": "node-exporter"},
+			"targets": ["node1.example.com", "node2.example.com"]}]`,
+			w.Body.String(),
+			"http_sd response did not match",
+		)
+
+		w = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sd/unknown", nil))
+		require.Equal(http.StatusOK, w.Code, "status code did not match")
+		require.JSONEq(`[]`, w.Body.String(), "unknown job was not empty")
+	})
+}
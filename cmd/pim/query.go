@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// listTypes are the things pim list can list.
+var listTypes = []string{"jobs", "targets", "labels"}
+
+// list prints the jobs, targets or labels of the exported targets that match the matchers.
+func list(logger *core.Logger, config *core.Config) error {
+	qts, err := queryTargets(logger, config)
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+
+	output := config.Flags["output"]
+	switch config.Flags["list_type"] {
+	case "jobs":
+		jobs := qts.Jobs()
+		return printQuery(logger, &jobs, output, func(w io.Writer) {
+			fmt.Fprintln(w, "JOB\tTARGETS\tFILES")
+			for _, j := range jobs {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", j.Job, j.Targets, strings.Join(j.Files, ","))
+			}
+		})
+	case "labels":
+		labels := qts.Labels()
+		return printQuery(logger, &labels, output, func(w io.Writer) {
+			fmt.Fprintln(w, "LABEL\tVALUES")
+			for _, l := range labels {
+				fmt.Fprintf(w, "%s\t%s\n", l.Name, strings.Join(l.Values, ","))
+			}
+		})
+	default:
+		return printQuery(logger, &qts, output, func(w io.Writer) {
+			fmt.Fprintln(w, "JOB\tTARGET\tFILE")
+			for _, qt := range qts {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", qt.Job, qt.Address, qt.File)
+			}
+		})
+	}
+}
+
+// query prints the exported targets that match the matchers with their labels.
+func query(logger *core.Logger, config *core.Config) error {
+	qts, err := queryTargets(logger, config)
+	if err != nil {
+		return fmt.Errorf("query: %w", err)
+	}
+
+	return printQuery(logger, &qts, config.Flags["output"], func(w io.Writer) {
+		fmt.Fprintln(w, "JOB\tTARGET\tLABELS")
+		for _, qt := range qts {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", qt.Job, qt.Address, formatLabels(qt.Labels))
+		}
+	})
+}
+
+// queryTargets loads the sources and returns the targets that match the matchers in the flags.
+func queryTargets(logger *core.Logger, config *core.Config) (targets.QueryTargets, error) {
+	matchers, err := targets.ParseMatchers(splitMatchers(config.Flags["query_matchers"])...)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("query: importing targets from %s\n", config.Sources)
+	tgs, err := targets.NewTargetGroups(config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading source: %w", err)
+	}
+
+	return tgs.Query(config, matchers), nil
+}
+
+// splitMatchers returns the matcher args joined by parseQueryOptions.
+func splitMatchers(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// printQuery prints v to stdout as json or yaml, or as the table written by table.
+func printQuery[T any](logger *core.Logger, v *T, output string, table func(w io.Writer)) error {
+	switch output {
+	case "json":
+		data, err := core.MarshalJSON(v)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOut(string(data))
+	case "yaml":
+		data, err := core.MarshalYAML(v)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOutf("%s", data)
+	default:
+		var b strings.Builder
+		w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		table(w)
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		logger.PrintOutf("%s", b.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainQuery(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+
+	t.Run("Query", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "query", "query_matchers": "job=~\".*-exporter\"\nenvironment=\"stg\""}
+		err := query(logger, config)
+		require.NoError(err, "query returned an unexpected error")
+
+		want := "JOB             TARGET             LABELS\n" +
+			`mysql-exporter  node1.example.com  {datacenter="us-east-1", environment="stg", job="mysql-exporter"}` + "\n" +
+			`mysql-exporter  node2.example.com  {datacenter="us-east-1", environment="stg", job="mysql-exporter"}` + "\n" +
+			`node-exporter   node1.example.com  {datacenter="us-east-1", environment="stg", job="node-exporter"}` + "\n" +
+			`node-exporter   node2.example.com  {datacenter="us-east-1", environment="stg", job="node-exporter"}` + "\n"
+		require.Equal(want, buf.String(), "query output did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "query", "query_matchers": `environment="prod"`, "output": "json"}
+		err := query(logger, config)
+		require.NoError(err, "query returned an unexpected error")
+		require.Contains(buf.String(), `"target": "prom.example.com"`)
+		require.NotContains(buf.String(), "node1.example.com")
+	})
+
+	t.Run("ListJobs", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "list", "list_type": "jobs"}
+		err := list(logger, config)
+		require.NoError(err, "list returned an unexpected error")
+
+		want := "JOB             TARGETS  FILES\n" +
+			"blackbox_icmp   2        blackbox_icmp_targets.json\n" +
+			"mysql-exporter  2        mysql-exporter_targets.json\n" +
+			"node-exporter   2        node-exporter_targets.json\n"
+		require.Equal(want, buf.String(), "list jobs output did not match")
+	})
+
+	t.Run("ListTargets", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "list", "list_type": "targets", "query_matchers": `job="blackbox_icmp"`}
+		err := list(logger, config)
+		require.NoError(err, "list returned an unexpected error")
+
+		want := "JOB            TARGET               FILE\n" +
+			"blackbox_icmp  grafana.example.com  blackbox_icmp_targets.json\n" +
+			"blackbox_icmp  prom.example.com     blackbox_icmp_targets.json\n"
+		require.Equal(want, buf.String(), "list targets output did not match")
+	})
+
+	t.Run("ListLabels", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "list", "list_type": "labels", "output": "yaml"}
+		err := list(logger, config)
+		require.NoError(err, "list returned an unexpected error")
+		require.Contains(buf.String(), "- name: environment\n  values:\n    - prod\n    - stg\n")
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// targetActions are the subcommands of pim target.
+var targetActions = []string{"add", "remove"}
+
+// editTarget adds a target to or removes it from the source files.
+func editTarget(logger *core.Logger, config *core.Config) error {
+	edit, err := targetEdit(config.Flags)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+
+	if config.Flags["target_action"] == "remove" {
+		logger.Debugf("target: removing %s from %s\n", edit.Address, config.Sources)
+		results, err := targets.RemoveTarget(config, edit)
+		for _, r := range results {
+			if r.Removed {
+				logger.PrintOutf("removed %s and its empty group %d from %s\n", edit.Address, r.Group, r.File)
+				continue
+			}
+
+			logger.PrintOutf("removed %s from group %d in %s\n", edit.Address, r.Group, r.File)
+		}
+
+		if err != nil {
+			return fmt.Errorf("target: %w", err)
+		}
+
+		return nil
+	}
+
+	logger.Debugf("target: adding %s to %s\n", edit.Address, config.Sources)
+	r, err := targets.AddTarget(config, edit)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+
+	if r.Created {
+		logger.PrintOutf("added %s to new group %d in %s\n", edit.Address, r.Group, r.File)
+		return nil
+	}
+
+	logger.PrintOutf("added %s to group %d in %s\n", edit.Address, r.Group, r.File)
+	return nil
+}
+
+// targetEdit returns the target edit set by the flags. Labels are stored one k=v per line.
+func targetEdit(flags core.Flags) (targets.TargetEdit, error) {
+	edit := targets.TargetEdit{
+		Address: flags["target_address"],
+		Group:   flags["target_group"],
+		File:    flags["target_file"],
+	}
+
+	for _, j := range strings.Split(flags["target_jobs"], ",") {
+		if j = strings.TrimSpace(j); j != "" {
+			edit.Jobs = append(edit.Jobs, j)
+		}
+	}
+
+	for _, l := range strings.Split(flags["target_labels"], "\n") {
+		if l == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || k == "" {
+			return edit, fmt.Errorf("%w: label must be <name>=<value>: %s", os.ErrInvalid, l)
+		}
+
+		if edit.Labels == nil {
+			edit.Labels = make(map[string]string)
+		}
+
+		edit.Labels[k] = v
+	}
+
+	return edit, nil
+}
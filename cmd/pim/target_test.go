@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainEditTarget(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	sfile := filepath.Join(config.Sources, "targets.yml")
+
+	t.Run("Add", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{
+			"command":        "target",
+			"target_action":  "add",
+			"target_address": "node3.example.com",
+			"target_jobs":    "node-exporter, mysql-exporter",
+			"target_labels":  "environment=stg\ndatacenter=us-east-1",
+		}
+		err := editTarget(logger, config)
+		require.NoError(err, "editTarget returned an unexpected error")
+		require.Equal("added node3.example.com to group 1 in "+sfile+"\n", buf.String())
+	})
+
+	t.Run("AddNewGroup", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{
+			"command":        "target",
+			"target_action":  "add",
+			"target_address": "db01.example.com",
+			"target_jobs":    "mysql-exporter",
+		}
+		err := editTarget(logger, config)
+		require.NoError(err, "editTarget returned an unexpected error")
+		require.Equal("added db01.example.com to new group 2 in "+sfile+"\n", buf.String())
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		buf.Reset()
+		config.Flags = core.Flags{"command": "target", "target_action": "remove", "target_address": "db01.example.com"}
+		err := editTarget(logger, config)
+		require.NoError(err, "editTarget returned an unexpected error")
+		require.Equal("removed db01.example.com and its empty group 2 from "+sfile+"\n", buf.String())
+	})
+
+	t.Run("InvalidLabel", func(t *testing.T) {
+		config.Flags = core.Flags{
+			"command":        "target",
+			"target_action":  "add",
+			"target_address": "node4.example.com",
+			"target_labels":  "rack",
+		}
+		err := editTarget(logger, config)
+		require.ErrorIs(err, os.ErrInvalid, "editTarget did not return an ErrInvalid")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		config.Flags = core.Flags{"command": "target", "target_action": "remove", "target_address": "db01.example.com"}
+		err := editTarget(logger, config)
+		require.ErrorIs(err, os.ErrNotExist, "editTarget did not return an ErrNotExist")
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// validate lints every source file and prints all of the problems found. Warnings are printed but
+// do not fail validation.
+func validate(logger *core.Logger, config *core.Config) error {
+	logger.Debugf("validate: checking sources in %s\n", config.Sources)
+	problems, err := targets.ValidateSources(config)
+	if err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	if err := printProblems(logger, problems, config.Flags["output"]); err != nil {
+		return err
+	}
+
+	if n := problems.Errors(); n > 0 {
+		return fmt.Errorf("validate: %d problems found", n)
+	}
+
+	return nil
+}
+
+// printProblems prints problems to stdout in the requested output format.
+func printProblems(logger *core.Logger, problems targets.Problems, output string) error {
+	switch output {
+	case "json":
+		data, err := core.MarshalJSON(&problems)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOut(string(data))
+	case "yaml":
+		data, err := core.MarshalYAML(&problems)
+		if err != nil {
+			return err
+		}
+
+		logger.PrintOutf("%s", data)
+	default:
+		for _, p := range problems {
+			logger.PrintOut(p.String())
+		}
+
+		if len(problems) == 0 {
+			logger.PrintOut("no problems found")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainValidate(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	config.Flags = core.Flags{"command": "validate"}
+
+	t.Run("Valid", func(t *testing.T) {
+		buf.Reset()
+		err := validate(logger, config)
+		require.NoError(err, "validate returned an unexpected error")
+		require.Contains(buf.String(), "no problems found")
+	})
+
+	t.Run("Problems", func(t *testing.T) {
+		sfile := filepath.Join(config.Sources, "targets.yml")
+		err := core.WriteFile(sfile, []byte("- jobs: [node]\n  labels:\n    __meta: value\n"), 0o644)
+		require.NoError(err, "failed to write sources file to %s", sfile)
+
+		buf.Reset()
+		err = validate(logger, config)
+		require.EqualError(err, "validate: 2 problems found")
+		require.NotErrorIs(err, os.ErrInvalid, "validate error would print help")
+		require.Contains(buf.String(), sfile+`:3:5: group 0: label name "__meta" is reserved for internal use`)
+		require.Contains(buf.String(), sfile+":1:3: group 0: has no targets")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		buf.Reset()
+		config.Flags["output"] = "json"
+		err := validate(logger, config)
+		require.Error(err, "validate did not return an error")
+		require.Contains(buf.String(), `"message": "group 0: has no targets"`)
+	})
+
+	t.Run("Warnings", func(t *testing.T) {
+		sfile := filepath.Join(config.Sources, "targets.yml")
+		content := "- jobs: [node]\n  labels: {rack: r01}\n  targets: [host01]\n" +
+			"- jobs: [node]\n  labels: {rack: r12}\n  targets: [host01]\n"
+		err := core.WriteFile(sfile, []byte(content), 0o644)
+		require.NoError(err, "failed to write sources file to %s", sfile)
+
+		buf.Reset()
+		config.Flags["output"] = ""
+		err = validate(logger, config)
+		require.NoError(err, "validate returned an error for warnings")
+		require.Contains(buf.String(), sfile+`: warning: job node target "host01" has conflicting labels rack`)
+	})
+}
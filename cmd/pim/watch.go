@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
+)
+
+// watch exports the targets and then re-exports them every time the sources change until ctx is
+// done.
+func watch(ctx context.Context, logger *core.Logger, config *core.Config) error {
+	// Keep watching even if the first export fails so fixing the sources does not need a
+	// restart.
+	if err := export(logger, config); err != nil {
+		logger.Printf("watch: %v\n", err)
+	}
+
+	watchSources(ctx, logger, config)
+	return nil
+}
+
+// watchSources re-exports the targets every time the sources change until ctx is done. If the
+// new sources fail to load, the targets files from the last good export are left in place.
+func watchSources(ctx context.Context, logger *core.Logger, config *core.Config) {
+	w := targets.NewWatcher(config)
+	logger.Printf("watch: watching %s for changes every %s\n", config.Sources, w.Interval)
+	w.Watch(ctx, func() {
+		logger.Printf("watch: sources changed, exporting targets\n")
+		if err := export(logger, config); err != nil {
+			logger.Printf("watch: keeping the last good targets: %v\n", err)
+		}
+	})
+	logger.Debug("watch: stopped watching sources")
+}
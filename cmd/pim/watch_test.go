@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMainWatch(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	var buf bytes.Buffer
+	core.Stdout = &buf
+	logger := core.NewLogger(&buf, "pim: ", log.LstdFlags, false)
+	config := newTestDirs(t, tempDir)
+	config.Flags = core.Flags{"command": "watch"}
+
+	// Cancel right away so watch only runs the first export.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("Export", func(t *testing.T) {
+		buf.Reset()
+		err := watch(ctx, logger, config)
+		require.NoError(err, "watch returned an unexpected error")
+		require.FileExists(filepath.Join(config.TargetsDir, "node-exporter_targets.json"))
+		require.Contains(buf.String(), "watch: watching "+config.Sources)
+	})
+
+	t.Run("InvalidSources", func(t *testing.T) {
+		sfile := filepath.Join(config.Sources, "targets.yml")
+		err := core.WriteFile(sfile, []byte("- jobs: [unclosed"), 0o644)
+		require.NoError(err, "failed to write sources file to %s", sfile)
+
+		buf.Reset()
+		err = watch(ctx, logger, config)
+		require.NoError(err, "watch returned an unexpected error")
+		require.Contains(buf.String(), "watch: export: error loading source")
+		require.FileExists(
+			filepath.Join(config.TargetsDir, "node-exporter_targets.json"),
+			"last good targets file was removed",
+		)
+	})
+}
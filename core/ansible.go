@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// AnsibleConfig maps the groups and host vars of Ansible inventory sources to jobs and labels.
+/*
+	ansible:
+	  address_var: ansible_host
+	  host_vars:
+	    - rack
+	  groups:
+	    - group: webservers
+	      jobs: [node_exporter]
+	      labels:
+	        role: web
+	    - group: "dc_(.*)"
+	      labels:
+	        datacenter: "$1"
+*/
+type AnsibleConfig struct {
+	// Groups are applied in order to every inventory group a host is in, including its parent
+	// groups and all.
+	Groups []*AnsibleGroup `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// HostVars lists the host vars added to each target as labels. If empty, every scalar host
+	// var with a valid label name is added except the ansible_ vars.
+	HostVars []string `json:"host_vars,omitempty" yaml:"host_vars,omitempty"`
+	// AddressVar is the host var to use as the target address, such as ansible_host. Hosts
+	// without it use the inventory host name.
+	AddressVar string `json:"address_var,omitempty" yaml:"address_var,omitempty"`
+}
+
+// AnsibleGroup adds jobs and labels to the hosts of every inventory group Group matches.
+type AnsibleGroup struct {
+	// Group is a regular expression matched against the whole group name.
+	Group string `json:"group" yaml:"group"`
+	// Jobs the hosts are exported for.
+	Jobs []string `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	// Labels added to the hosts. Values can use $1 or ${name} capture groups from Group.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// Regexp returns the anchored Group regular expression.
+func (g *AnsibleGroup) Regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + g.Group + ")$")
+}
+
+// validate checks the group rules and host vars.
+func (a *AnsibleConfig) validate() error {
+	for i, g := range a.Groups {
+		if g == nil || g.Group == "" {
+			return fmt.Errorf("config: %w: ansible: groups %d: group is required", os.ErrInvalid, i)
+		}
+
+		if _, err := g.Regexp(); err != nil {
+			return fmt.Errorf("config: %w: ansible: groups %d: group: %w", os.ErrInvalid, i, err)
+		}
+
+		for _, job := range g.Jobs {
+			if strings.TrimSpace(job) == "" {
+				return fmt.Errorf("config: %w: ansible: groups %d: jobs can not be empty", os.ErrInvalid, i)
+			}
+		}
+
+		for l := range g.Labels {
+			if err := validateLabelName(l); err != nil {
+				return fmt.Errorf("config: %w: ansible: groups %d: %w", os.ErrInvalid, i, err)
+			}
+		}
+	}
+
+	for _, v := range a.HostVars {
+		if err := validateLabelName(v); err != nil {
+			return fmt.Errorf("config: %w: ansible: host_vars: %w", os.ErrInvalid, err)
+		}
+	}
+
+	return nil
+}
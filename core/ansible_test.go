@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnsibleValidate(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		a := &AnsibleConfig{
+			Groups: []*AnsibleGroup{
+				{Group: "webservers", Jobs: []string{"node_exporter"}},
+				{Group: "dc_(.*)", Labels: map[string]string{"datacenter": "$1"}},
+			},
+			HostVars:   []string{"rack"},
+			AddressVar: "ansible_host",
+		}
+		require.NoError(a.validate(), "valid ansible config returned an error")
+	})
+
+	tests := []struct {
+		name    string
+		ansible *AnsibleConfig
+		want    string
+	}{
+		{"NoGroup", &AnsibleConfig{Groups: []*AnsibleGroup{{}}}, "config: invalid argument: ansible: groups 0: group is required"},
+		{"Regex", &AnsibleConfig{Groups: []*AnsibleGroup{{Group: "dc_("}}}, "config: invalid argument: ansible: groups 0: group: error parsing regexp: missing closing ): `^(?:dc_()$`"},
+		{"EmptyJob", &AnsibleConfig{Groups: []*AnsibleGroup{{Group: "web", Jobs: []string{""}}}}, "config: invalid argument: ansible: groups 0: jobs can not be empty"},
+		{"LabelName", &AnsibleConfig{Groups: []*AnsibleGroup{{Group: "web", Labels: map[string]string{"bad-name": "x"}}}}, "config: invalid argument: ansible: groups 0: invalid label name: bad-name"},
+		{"Reserved", &AnsibleConfig{Groups: []*AnsibleGroup{{Group: "web", Labels: map[string]string{"job": "x"}}}}, "config: invalid argument: ansible: groups 0: label name is reserved: job"},
+		{"HostVar", &AnsibleConfig{HostVars: []string{"__meta"}}, "config: invalid argument: ansible: host_vars: label name is reserved: __meta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ansible.validate()
+			require.ErrorIs(err, os.ErrInvalid, "validate() did not return an ErrInvalid")
+			require.Equal(tt.want, err.Error(), "error did not match")
+		})
+	}
+}
+
+func TestAnsibleNewConfig(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// Other tests leave the file functions mocked so use the real ones and put them back after.
+	defer func(t func(string) error, r func(string) ([]byte, error), w func(string, []byte, os.FileMode) error) {
+		tester, reader, writer = t, r, w
+	}(tester, reader, writer)
+	tester, reader, writer = AssertReadable, os.ReadFile, os.WriteFile
+
+	f := filepath.Join(tempDir, "pim.yml")
+	l := NewLogger(&bytes.Buffer{}, "pim: ", log.LstdFlags, false)
+
+	t.Run("Success", func(t *testing.T) {
+		data := `source_format: ansible
+ansible:
+  address_var: ansible_host
+  groups:
+    - group: webservers
+      jobs: [node_exporter]
+`
+		require.NoError(WriteFile(f, []byte(data), PermStdRead), "failed to write config file")
+
+		config, err := NewConfig(l, Flags{"config_file": f}, map[string]string{})
+		require.NoError(err, "NewConfig returned unexpected error")
+		require.Equal(SourceFormatAnsible, config.SourceFormat, "source_format did not match")
+		require.Equal(
+			AnsibleConfig{
+				Groups:     []*AnsibleGroup{{Group: "webservers", Jobs: []string{"node_exporter"}}},
+				AddressVar: "ansible_host",
+			},
+			config.Ansible,
+			"ansible did not match",
+		)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		require.NoError(WriteFile(f, []byte("source_format: chef\n"), PermStdRead), "failed to write config file")
+
+		_, err := NewConfig(l, Flags{"config_file": f}, map[string]string{})
+		require.ErrorIs(err, os.ErrInvalid, "NewConfig did not return an ErrInvalid")
+	})
+
+	t.Run("InvalidGroup", func(t *testing.T) {
+		data := "ansible:\n  groups:\n    - jobs: [node]\n"
+		require.NoError(WriteFile(f, []byte(data), PermStdRead), "failed to write config file")
+
+		_, err := NewConfig(l, Flags{"config_file": f}, map[string]string{})
+		require.ErrorIs(err, os.ErrInvalid, "NewConfig did not return an ErrInvalid")
+	})
+}
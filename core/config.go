@@ -12,9 +12,13 @@ import (
 
 const (
 	DefaultExportFirst       = false
-	DefaultExportType        = "file_sd"
+	ExportTypeFileSD         = "file_sd"
+	ExportTypeHTTPSD         = "http_sd"
+	DefaultExportType        = ExportTypeFileSD
 	DefaultConfigFile        = "/etc/pim/pim.yml"
 	DefaultSources           = "/etc/pim/sources"
+	DefaultSourcesCacheDir   = "/var/cache/pim"
+	DefaultSourcesTimeout    = 10
 	DefaultTargetsDir        = "/etc/prometheus/file_sd"
 	DefaultTargetsFileSuffix = "_targets"
 	DefaultJSONFileExt       = ".json"
@@ -24,14 +28,29 @@ const (
 	DefaultAPIHost         = "0.0.0.0"
 	DefaultAPIPort         = "9900"
 	DefaultShutdownTimeout = 5
+
+	DefaultWatchInterval = 2
+
+	// How labels are merged when the same target is in more than one group for a job.
+	MergeOrder             = "order"
+	MergePriority          = "priority"
+	MergeError             = "error"
+	DefaultMergePrecedence = MergeOrder
+
+	// How source files are read. .ini files are always read as Ansible inventories.
+	SourceFormatPim     = "pim"
+	SourceFormatAnsible = "ansible"
+	DefaultSourceFormat = SourceFormatPim
 )
 
 var (
 	// command                string
 	envPrefix              = "PIM_"
-	validExportTypes       = []string{"file_sd"}
+	validExportTypes       = []string{ExportTypeFileSD, ExportTypeHTTPSD}
 	validConfigExtensions  = []string{".yml", ".yaml", ".json"}
 	validTargetsExtensions = []string{".yml", ".yaml", ".json"}
+	validMergePrecedences  = []string{MergeOrder, MergePriority, MergeError}
+	validSourceFormats     = []string{SourceFormatPim, SourceFormatAnsible}
 )
 
 type Flags map[string]string
@@ -46,8 +65,24 @@ type Config struct {
 
 	// The path to the config file.
 	ConfigFile string
-	// The path to the pim sources file or directory.
+	// The path to the pim sources file or directory, or an http(s) URL that returns target
+	// groups in JSON or YAML.
 	Sources string `json:"sources,omitempty" yaml:"sources,omitempty"`
+	// The directory remote sources are cached in so the last good copy can be used when the
+	// sources URL can not be reached.
+	SourcesCacheDir string `json:"sources_cache_dir,omitempty" yaml:"sources_cache_dir,omitempty"`
+	// How long to wait for remote sources in seconds.
+	SourcesTimeout int `json:"sources_timeout,omitempty" yaml:"sources_timeout,omitempty"`
+	// Sources to load instead of Sources. Set by a sources list in the config file and cleared
+	// when sources is set by a flag or environment variable.
+	SourceList []*SourceConfig `json:"-" yaml:"-"`
+	// How to read the source files. ansible reads every source file as an Ansible inventory.
+	SourceFormat string `json:"source_format,omitempty" yaml:"source_format,omitempty"`
+	// How Ansible inventory groups and host vars map to jobs and labels. Only read from the config
+	// file.
+	Ansible AnsibleConfig `json:"ansible,omitempty" yaml:"ansible,omitempty"`
+	// The jobs and labels of list sources by file name. Only read from the config file.
+	ListFiles map[string]*ListFileConfig `json:"list_files,omitempty" yaml:"list_files,omitempty"`
 	// The path to the directory to write the targets files.
 	TargetsDir string `json:"targets_dir,omitempty" yaml:"targets_dir,omitempty"`
 
@@ -56,7 +91,8 @@ type Config struct {
 	// "_targets" would create $job_targets.yml
 	TargetsFileSuffix string `json:"targets_file_suffix,omitempty" yaml:"targets_file_suffix,omitempty"`
 
-	// How to to name the target files.
+	// How to to name the target files. The job is always part of the name and is placed first
+	// unless it is listed elsewhere.
 	/*
 		target_split:
 		  - job
@@ -68,9 +104,25 @@ type Config struct {
 		  blackbox_http_atl_webapp_targets.json
 		  blackbox_icmp_jfk_mysql_targets.json
 	*/
-	//TargetSplit []string `json:"target_split,omitempty" yaml:"target_split,omitempty"`
+	TargetSplit []string `json:"target_split,omitempty" yaml:"target_split,omitempty"`
+
+	// Defaults applied to the targets of each job by job name. Only read from the config file.
+	Jobs map[string]*JobConfig `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	// Which labels win when the same target is in more than one group for a job. order lets later
+	// groups win, priority lets the group with the highest priority win and error fails the export.
+	MergePrecedence string `json:"merge_precedence,omitempty" yaml:"merge_precedence,omitempty"`
+
+	// Relabel rules applied to every target before it is exported. Only read from the config file.
+	RelabelConfigs []*RelabelConfig `json:"relabel_configs,omitempty" yaml:"relabel_configs,omitempty"`
+
+	// Watch the sources for changes and re-export the targets when they change.
+	Watch bool `json:"watch,omitempty" yaml:"watch,omitempty"`
+	// How often to check the sources for changes in seconds.
+	WatchInterval int `json:"watch_interval,omitempty" yaml:"watch_interval,omitempty"`
 
 	// HTTP Endpont
+	// Enable the /api/v1 routes which can change the source files.
+	APIEnabled  bool   `json:"http_api_enabled,omitempty" yaml:"http_api_enabled,omitempty"`
 	APIHost     string `json:"http_api_host,omitempty" yaml:"http_api_host,omitempty"`
 	APIPort     string `json:"http_api_port,omitempty" yaml:"http_api_port,omitempty"`
 	TLSCertFile string `json:"http_tls_cert_file,omitempty" yaml:"http_tls_cert_file,omitempty"`
@@ -87,13 +139,19 @@ func DefaultConfig() *Config {
 		ExportFirst:       DefaultExportFirst,
 		ConfigFile:        DefaultConfigFile,
 		Sources:           DefaultSources,
+		SourcesCacheDir:   DefaultSourcesCacheDir,
+		SourcesTimeout:    DefaultSourcesTimeout,
+		SourceFormat:      DefaultSourceFormat,
 		TargetsDir:        DefaultTargetsDir,
 		TargetsFileSuffix: DefaultTargetsFileSuffix,
 		TargetsFileExt:    DefaultTargetsFileExt,
 		APIHost:           DefaultAPIHost,
 		APIPort:           DefaultAPIPort,
 		ShutdownTimeout:   DefaultShutdownTimeout,
-		// TargetSplit:       make([]string, 0),
+		TargetSplit:       make([]string, 0),
+		Jobs:              make(map[string]*JobConfig),
+		MergePrecedence:   DefaultMergePrecedence,
+		WatchInterval:     DefaultWatchInterval,
 	}
 }
 
@@ -141,6 +199,31 @@ func NewConfig(
 		logger.Debugf("cound not find config file at %s", c.ConfigFile)
 	}
 
+	if err := c.validateJobs(); err != nil {
+		return c, err
+	}
+
+	if err := c.Ansible.validate(); err != nil {
+		return c, err
+	}
+
+	if err := c.validateListFiles(); err != nil {
+		return c, err
+	}
+
+	if err := c.validateSources(); err != nil {
+		return c, err
+	}
+
+	// Values from the config file skip setConfigValue so check the ones that have to be valid.
+	if err := c.setConfigValue("merge_precedence", c.MergePrecedence); err != nil {
+		return c, err
+	}
+
+	if err := c.setConfigValue("source_format", c.SourceFormat); err != nil {
+		return c, err
+	}
+
 	// Try to find each supported variable passed in by flags or env. If found, overwrite the
 	// value in config.
 	logger.Debug("updating settings from flags and environment variables")
@@ -207,6 +290,22 @@ func (c *Config) splitExportTypes(v string) error {
 	return nil
 }
 
+// splitTargetSplit replaces TargetSplit with the comma separated list of label names in v.
+func (c *Config) splitTargetSplit(v string) error {
+	split := make([]string, 0)
+	for _, l := range strings.Split(v, ",") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			return fmt.Errorf("config: %w: target_split: empty label name in '%s'", os.ErrInvalid, v)
+		}
+
+		split = append(split, l)
+	}
+
+	c.TargetSplit = split
+	return nil
+}
+
 func (c *Config) setConfigValue(k, v string) error {
 	switch k {
 	// Do nothing with config_file since we've already handled it.
@@ -236,10 +335,64 @@ func (c *Config) setConfigValue(k, v string) error {
 		c.TargetsFileExt = v
 	case "targets_file_suffix":
 		c.TargetsFileSuffix = v
+	case "target_split":
+		return c.splitTargetSplit(v)
 	case "sources":
 		c.Sources = v
-	case "command":
+		c.SourceList = nil
+	case "sources_cache_dir":
+		c.SourcesCacheDir = v
+	case "sources_timeout":
+		timeout, err := strconv.Atoi(v)
+		if err != nil || timeout < 1 {
+			return fmt.Errorf("config: %w: %s must be a positive int '%s'", os.ErrInvalid, k, v)
+		}
+		c.SourcesTimeout = timeout
+	case "source_format":
+		if !slices.Contains(validSourceFormats, v) {
+			return fmt.Errorf(
+				"config: %w: %s: %s; must be one of: %s",
+				os.ErrInvalid,
+				k,
+				v,
+				strings.Join(validSourceFormats, ", "),
+			)
+		}
+		c.SourceFormat = v
+	case "merge_precedence":
+		if !slices.Contains(validMergePrecedences, v) {
+			return fmt.Errorf(
+				"config: %w: %s: %s; must be one of: %s",
+				os.ErrInvalid,
+				k,
+				v,
+				strings.Join(validMergePrecedences, ", "),
+			)
+		}
+		c.MergePrecedence = v
+	case "watch":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %w", k, os.ErrInvalid, err)
+		}
+		c.Watch = b
+	case "watch_interval":
+		interval, err := strconv.Atoi(v)
+		if err != nil || interval < 1 {
+			return fmt.Errorf("config: %w: %s must be a positive int '%s'", os.ErrInvalid, k, v)
+		}
+		c.WatchInterval = interval
+	// Command options are read from Flags by the command that uses them.
+	case "check", "command", "dry_run", "explain_job", "explain_target", "force", "list_type",
+		"output", "query_matchers", "target_action", "target_address", "target_file", "target_group",
+		"target_jobs", "target_labels":
 		break
+	case "http_api_enabled":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %w", k, os.ErrInvalid, err)
+		}
+		c.APIEnabled = b
 	case "http_api_host":
 		c.APIHost = v
 	case "http_api_port":
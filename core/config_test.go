@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -22,9 +23,17 @@ var (
 		RawExportTypes:    []string{DefaultExportType},
 		ExportTypes:       map[string]bool{DefaultExportType: true},
 		Sources:           "/tmp/sources",
+		SourcesCacheDir:   "/tmp/cache",
+		SourcesTimeout:    30,
+		SourceFormat:      SourceFormatAnsible,
 		TargetsDir:        "/tmp/targets",
 		TargetsFileExt:    ".yaml",
 		TargetsFileSuffix: "_sd_targets",
+		TargetSplit:       []string{"job", "datacenter"},
+		MergePrecedence:   MergePriority,
+		Watch:             true,
+		WatchInterval:     10,
+		APIEnabled:        true,
 		APIHost:           DefaultAPIHost,
 		APIPort:           DefaultAPIPort,
 		ShutdownTimeout:   5,
@@ -35,8 +44,16 @@ var (
 		"export_types":          "file_sd",
 		"targets_file_ext":      ".yaml",
 		"sources":               "/tmp/sources",
+		"sources_cache_dir":     "/tmp/cache",
+		"sources_timeout":       "30",
+		"source_format":         "ansible",
 		"targets_dir":           "/tmp/targets",
 		"targets_file_suffix":   "_sd_targets",
+		"target_split":          "job,datacenter",
+		"merge_precedence":      "priority",
+		"watch":                 "true",
+		"watch_interval":        "10",
+		"http_api_enabled":      "true",
 		"http_api_host":         DefaultAPIHost,
 		"http_api_port":         DefaultAPIPort,
 		"http_shutdown_timeout": "5",
@@ -58,9 +75,16 @@ func newDefaultConfig() *Config {
 		ExportTypes:       make(map[string]bool),
 		ConfigFile:        DefaultConfigFile,
 		Sources:           DefaultSources,
+		SourcesCacheDir:   DefaultSourcesCacheDir,
+		SourcesTimeout:    DefaultSourcesTimeout,
+		SourceFormat:      DefaultSourceFormat,
 		TargetsDir:        DefaultTargetsDir,
 		TargetsFileExt:    DefaultTargetsFileExt,
 		TargetsFileSuffix: DefaultTargetsFileSuffix,
+		TargetSplit:       make([]string, 0),
+		Jobs:              make(map[string]*JobConfig),
+		MergePrecedence:   DefaultMergePrecedence,
+		WatchInterval:     DefaultWatchInterval,
 		APIHost:           DefaultAPIHost,
 		APIPort:           DefaultAPIPort,
 		ShutdownTimeout:   5,
@@ -140,8 +164,24 @@ func requireValue(t *testing.T, c *Config, k, v string) {
 		require.Equal(v, c.TargetsFileExt, fmt.Sprintf("%s did not match", k))
 	case "targets_file_suffix":
 		require.Equal(v, c.TargetsFileSuffix, fmt.Sprintf("%s did not match", k))
+	case "target_split":
+		require.Equal(strings.Split(v, ","), c.TargetSplit, fmt.Sprintf("%s did not match", k))
 	case "sources":
 		require.Equal(v, c.Sources, fmt.Sprintf("%s did not match", k))
+	case "sources_cache_dir":
+		require.Equal(v, c.SourcesCacheDir, fmt.Sprintf("%s did not match", k))
+	case "sources_timeout":
+		require.Equal(v, strconv.Itoa(c.SourcesTimeout), fmt.Sprintf("%s did not match", k))
+	case "merge_precedence":
+		require.Equal(v, c.MergePrecedence, fmt.Sprintf("%s did not match", k))
+	case "source_format":
+		require.Equal(v, c.SourceFormat, fmt.Sprintf("%s did not match", k))
+	case "watch":
+		require.Equal(v == "true", c.Watch, fmt.Sprintf("%s did not match", k))
+	case "watch_interval":
+		require.Equal(v, strconv.Itoa(c.WatchInterval), fmt.Sprintf("%s did not match", k))
+	case "http_api_enabled":
+		require.Equal(v == "true", c.APIEnabled, fmt.Sprintf("%s did not match", k))
 	}
 }
 
@@ -166,10 +206,10 @@ func TestConfigSetConfigValue(t *testing.T) {
 				case "debug":
 					require.Error(err, "setConfigValue did not return error")
 					require.ErrorIs(err, os.ErrInvalid, "setConfigValue returned wrong error")
-				case "export_types", "targets_file_ext":
+				case "export_types", "targets_file_ext", "target_split", "merge_precedence", "source_format":
 					require.Error(err, "setConfigValue() did not return error")
 					require.Equal(exp, config, "configs did not match")
-				case "http_shutdown_timeout":
+				case "http_shutdown_timeout", "watch", "watch_interval", "http_api_enabled", "sources_timeout":
 					require.Error(err, "setConfigValue did not return an error")
 				default:
 					require.NoError(err, "setConfigValue returned an unexpected error")
@@ -227,8 +267,24 @@ func requireConfigValue(t *testing.T, c *Config, k, v string, checkExportTypes b
 		require.Equal(v, c.TargetsFileExt, fmt.Sprintf("%s did not match", k))
 	case "targets_file_suffix":
 		require.Equal(v, c.TargetsFileSuffix, fmt.Sprintf("%s did not match", k))
+	case "target_split":
+		require.Equal(strings.Split(v, ","), c.TargetSplit, fmt.Sprintf("%s did not match", k))
 	case "sources":
 		require.Equal(v, c.Sources, fmt.Sprintf("%s did not match", k))
+	case "sources_cache_dir":
+		require.Equal(v, c.SourcesCacheDir, fmt.Sprintf("%s did not match", k))
+	case "sources_timeout":
+		require.Equal(v, strconv.Itoa(c.SourcesTimeout), fmt.Sprintf("%s did not match", k))
+	case "merge_precedence":
+		require.Equal(v, c.MergePrecedence, fmt.Sprintf("%s did not match", k))
+	case "source_format":
+		require.Equal(v, c.SourceFormat, fmt.Sprintf("%s did not match", k))
+	case "watch":
+		require.Equal(v == "true", c.Watch, fmt.Sprintf("%s did not match", k))
+	case "watch_interval":
+		require.Equal(v, strconv.Itoa(c.WatchInterval), fmt.Sprintf("%s did not match", k))
+	case "http_api_enabled":
+		require.Equal(v == "true", c.APIEnabled, fmt.Sprintf("%s did not match", k))
 	}
 }
 
@@ -315,6 +371,23 @@ func TestConfigParseConfigFile(t *testing.T) {
 	})
 }
 
+func TestConfigSplitExportTypes(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		config := newEmptyConfig()
+		err := config.splitExportTypes("file_sd,http_sd")
+		require.NoError(err, "splitExportTypes returned an unexpected error")
+		require.Equal([]string{ExportTypeFileSD, ExportTypeHTTPSD}, config.RawExportTypes)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		config := newEmptyConfig()
+		err := config.splitExportTypes("file_sd,consul_sd")
+		require.Error(err, "splitExportTypes did not return an error")
+	})
+}
+
 func TestConfigProcessExportTypes(t *testing.T) {
 	require := require.New(t)
 
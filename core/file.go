@@ -18,13 +18,15 @@ var (
 	tester func(string) error
 	reader func(string) ([]byte, error)
 	// func(name string, data []byte, perm os.FileMode) error
-	writer func(string, []byte, os.FileMode) error
+	writer  func(string, []byte, os.FileMode) error
+	remover func(string) error
 )
 
 func init() {
 	tester = AssertReadable
 	reader = os.ReadFile
 	writer = os.WriteFile
+	remover = os.Remove
 }
 
 func SetTester(t func(string) error) {
@@ -39,6 +41,10 @@ func SetWriter(t func(string, []byte, os.FileMode) error) {
 	writer = t
 }
 
+func SetRemover(t func(string) error) {
+	remover = t
+}
+
 func ReadYAML[T any](file string, obj *T) error {
 	data, err := ReadFile(file)
 	if err != nil {
@@ -58,7 +64,7 @@ func ReadJSON[T any](file string, obj *T) error {
 }
 
 func WriteYAML[T any](file string, obj *T, perm os.FileMode) error {
-	data, err := yaml.Marshal(obj)
+	data, err := MarshalYAML(obj)
 	if err != nil {
 		return err
 	}
@@ -67,7 +73,7 @@ func WriteYAML[T any](file string, obj *T, perm os.FileMode) error {
 }
 
 func WriteJSON[T any](file string, obj *T, perm os.FileMode) error {
-	data, err := json.MarshalIndent(obj, "", "  ")
+	data, err := MarshalJSON(obj)
 	if err != nil {
 		return err
 	}
@@ -75,6 +81,16 @@ func WriteJSON[T any](file string, obj *T, perm os.FileMode) error {
 	return WriteFile(file, data, perm)
 }
 
+// MarshalYAML returns obj in the same format WriteYAML writes it.
+func MarshalYAML[T any](obj *T) ([]byte, error) {
+	return yaml.Marshal(obj)
+}
+
+// MarshalJSON returns obj in the same format WriteJSON writes it.
+func MarshalJSON[T any](obj *T) ([]byte, error) {
+	return json.MarshalIndent(obj, "", "  ")
+}
+
 // FindInDir returns the contents of the first file found in the directory. dir should not contain
 // a trailing "/". If no file is found, return FileNotFound.
 func FindInDir(dir string, fileNames ...string) (string, error) {
@@ -118,6 +134,51 @@ func WriteFile(file string, data []byte, perm os.FileMode) error {
 	return writer(file, data, perm)
 }
 
+// WriteFileAtomic writes data to a temp file in the same directory as file and then renames it
+// into place so readers never see a partially written file.
+func WriteFileAtomic(file string, data []byte, perm os.FileMode) error {
+	if file == "" {
+		return os.ErrInvalid
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temp file if anything fails before the rename.
+	name := tmp.Name()
+	defer os.Remove(name)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(name, perm); err != nil {
+		return err
+	}
+
+	return os.Rename(name, file)
+}
+
+func RemoveFile(file string) error {
+	if file == "" {
+		return os.ErrInvalid
+	}
+
+	return remover(file)
+}
+
 func MapFiles(docRoot string) ([]string, error) {
 	var files []string
 	err := filepath.WalkDir(docRoot, func(path string, d os.DirEntry, err error) error {
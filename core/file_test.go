@@ -167,6 +167,65 @@ func TestFilesReadFile(t *testing.T) {
 	})
 }
 
+func TestFilesWriteFileAtomic(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	t.Run("empty filename", func(t *testing.T) {
+		err := WriteFileAtomic("", []byte("test"), PermStdRead)
+		require.ErrorIs(err, os.ErrInvalid, "WriteFileAtomic() did not return the expected error")
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		err := WriteFileAtomic(filepath.Join(tempDir, "missing", "test.file"), []byte("test"), PermStdRead)
+		require.ErrorIs(err, os.ErrNotExist, "WriteFileAtomic() did not return the expected error")
+	})
+
+	t.Run("valid file", func(t *testing.T) {
+		f := filepath.Join(tempDir, "test.file")
+		for _, data := range []string{"first", "second"} {
+			err := WriteFileAtomic(f, []byte(data), PermStdRead)
+			require.NoError(err, "WriteFileAtomic() returned an error: %s", err)
+
+			got, err := os.ReadFile(f)
+			require.NoError(err, "failed to read file")
+			require.Equal(data, string(got), "file contents did not match")
+		}
+
+		info, err := os.Stat(f)
+		require.NoError(err, "failed to stat file")
+		require.Equal(os.FileMode(PermStdRead), info.Mode().Perm(), "file mode did not match")
+
+		entries, err := os.ReadDir(tempDir)
+		require.NoError(err, "failed to read dir")
+		require.Len(entries, 1, "temp file was left behind")
+	})
+}
+
+func TestFilesRemoveFile(t *testing.T) {
+	require := require.New(t)
+	SetRemover(MockRemover)
+
+	t.Run("empty filename", func(t *testing.T) {
+		err := RemoveFile("")
+		require.ErrorIs(err, os.ErrInvalid, "RemoveFile() did not return the expected error")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		MockClearFS()
+		err := RemoveFile("test.file")
+		require.ErrorIs(err, os.ErrNotExist, "RemoveFile() did not return the expected error")
+	})
+
+	t.Run("valid file", func(t *testing.T) {
+		MockWriteFile("test.file", []byte("test"), true, nil)
+		err := RemoveFile("test.file")
+		require.NoError(err, "RemoveFile() returned an error: %s", err)
+		require.NotContains(MockFS, "test.file", "RemoveFile() did not remove the file")
+	})
+}
+
 func TestFilesAssertReadable(t *testing.T) {
 	require := require.New(t)
 	tempDir := createTempDir(t)
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+var (
+	validJobSchemes = []string{"http", "https"}
+	// jobLabelNameRE matches valid Prometheus label names.
+	jobLabelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// JobConfig holds the defaults applied to every target exported for a job. It is only read from
+// the jobs section of the config file.
+/*
+	jobs:
+	  node_exporter:
+	    port: 9100
+	  blackbox_http:
+	    scheme: https
+	    address: "{{.Scheme}}://{{.Host}}/health"
+	    labels:
+	      module: http_2xx
+*/
+type JobConfig struct {
+	// Port is added to target addresses without a port.
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+	// Scheme is added to target addresses without a scheme, such as https.
+	Scheme string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	// Address is a text/template that replaces the target address. See AddressVars for the
+	// available fields. Port and Scheme fill in .Port and .Scheme but are not added otherwise.
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	// Labels are added to every target of the job. Group and target labels take precedence.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// RelabelConfigs are applied to the targets of the job after the global RelabelConfigs.
+	RelabelConfigs []*RelabelConfig `json:"relabel_configs,omitempty" yaml:"relabel_configs,omitempty"`
+	// Match adds the job to every target whose group and target labels have all of these values,
+	// as well as the groups that list the job.
+	Match map[string]string `json:"match,omitempty" yaml:"match,omitempty"`
+	// Exclude skips targets matched by Match that have any of these label values.
+	Exclude map[string]string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+// AddressVars are the fields available to a JobConfig Address template.
+type AddressVars struct {
+	// Address is the target address as written in the source.
+	Address string
+	// Scheme is the scheme of the address, or the job scheme if the address has none.
+	Scheme string
+	// Host is the address without the scheme, port, path or IPv6 brackets.
+	Host string
+	// Port is the port of the address, or the job port if the address has none.
+	Port string
+	// Path is everything after the host and port, including the leading slash.
+	Path   string
+	Job    string
+	Labels map[string]string
+}
+
+// HasSelector returns true if the job picks up targets by their labels.
+func (j *JobConfig) HasSelector() bool {
+	return len(j.Match) > 0
+}
+
+// Selects returns true if labels have every Match value and none of the Exclude values.
+func (j *JobConfig) Selects(labels map[string]string) bool {
+	if !j.HasSelector() {
+		return false
+	}
+
+	for k, v := range j.Match {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+
+	for k, v := range j.Exclude {
+		if l, ok := labels[k]; ok && l == v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddressTemplate parses the Address template. It returns nil if Address is empty.
+func (j *JobConfig) AddressTemplate() (*template.Template, error) {
+	if j.Address == "" {
+		return nil, nil
+	}
+
+	return template.New("address").Option("missingkey=zero").Parse(j.Address)
+}
+
+// validate checks the job settings and makes sure the address template runs.
+func (j *JobConfig) validate(name string) error {
+	if j.Port < 0 || j.Port > 65535 {
+		return fmt.Errorf("config: %w: jobs: %s: port must be between 1 and 65535: %d", os.ErrInvalid, name, j.Port)
+	}
+
+	if j.Scheme != "" && !slices.Contains(validJobSchemes, j.Scheme) {
+		return fmt.Errorf(
+			"config: %w: jobs: %s: scheme: %s; must be one of: %s",
+			os.ErrInvalid,
+			name,
+			j.Scheme,
+			strings.Join(validJobSchemes, ", "),
+		)
+	}
+
+	tmpl, err := j.AddressTemplate()
+	if err != nil {
+		return fmt.Errorf("config: %w: jobs: %s: address: %w", os.ErrInvalid, name, err)
+	}
+
+	if tmpl != nil {
+		vars := AddressVars{Address: "host01", Host: "host01", Job: name, Labels: map[string]string{}}
+		if err := tmpl.Execute(io.Discard, vars); err != nil {
+			return fmt.Errorf("config: %w: jobs: %s: address: %w", os.ErrInvalid, name, err)
+		}
+	}
+
+	for l := range j.Labels {
+		if err := validateLabelName(l); err != nil {
+			return fmt.Errorf("config: %w: jobs: %s: %w", os.ErrInvalid, name, err)
+		}
+	}
+
+	if len(j.Exclude) > 0 && !j.HasSelector() {
+		return fmt.Errorf("config: %w: jobs: %s: exclude requires match", os.ErrInvalid, name)
+	}
+
+	for _, selector := range []map[string]string{j.Match, j.Exclude} {
+		for l := range selector {
+			if !jobLabelNameRE.MatchString(l) {
+				return fmt.Errorf("config: %w: jobs: %s: invalid selector label name: %s", os.ErrInvalid, name, l)
+			}
+		}
+	}
+
+	return validateRelabelConfigs("jobs: "+name+": relabel_configs", j.RelabelConfigs)
+}
+
+// validateJobs checks every job in the jobs section and the global relabel_configs.
+func (c *Config) validateJobs() error {
+	if err := validateRelabelConfigs("relabel_configs", c.RelabelConfigs); err != nil {
+		return err
+	}
+
+	for name, j := range c.Jobs {
+		if j == nil {
+			c.Jobs[name] = &JobConfig{}
+			continue
+		}
+
+		if err := j.validate(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateLabelName checks a label name set in the config file.
+func validateLabelName(name string) error {
+	switch {
+	case !jobLabelNameRE.MatchString(name):
+		return fmt.Errorf("invalid label name: %s", name)
+	case name == "job" || strings.HasPrefix(name, "__"):
+		return fmt.Errorf("label name is reserved: %s", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsValidate(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		j := &JobConfig{
+			Port:    9100,
+			Scheme:  "https",
+			Address: "{{.Scheme}}://{{.Host}}:{{.Port}}{{.Path}}?dc={{.Labels.datacenter}}",
+			Labels:  map[string]string{"module": "http_2xx"},
+		}
+		require.NoError(j.validate("node"), "valid job returned an error")
+	})
+
+	tests := []struct {
+		name string
+		job  *JobConfig
+		want string
+	}{
+		{"Port", &JobConfig{Port: 70000}, "config: invalid argument: jobs: node: port must be between 1 and 65535: 70000"},
+		{"Scheme", &JobConfig{Scheme: "ftp"}, "config: invalid argument: jobs: node: scheme: ftp; must be one of: http, https"},
+		{"Template", &JobConfig{Address: "{{.Host"}, "config: invalid argument: jobs: node: address: template: address:1: unclosed action"},
+		{"TemplateField", &JobConfig{Address: "{{.Hostname}}"}, "config: invalid argument: jobs: node: address: template: address:1:2: executing \"address\" at <.Hostname>: can't evaluate field Hostname in type core.AddressVars"},
+		{"LabelName", &JobConfig{Labels: map[string]string{"9bad": "x"}}, "config: invalid argument: jobs: node: invalid label name: 9bad"},
+		{"Reserved", &JobConfig{Labels: map[string]string{"job": "x"}}, "config: invalid argument: jobs: node: label name is reserved: job"},
+		{"ExcludeOnly", &JobConfig{Exclude: map[string]string{"os": "windows"}}, "config: invalid argument: jobs: node: exclude requires match"},
+		{"SelectorLabel", &JobConfig{Match: map[string]string{"bad-name": "x"}}, "config: invalid argument: jobs: node: invalid selector label name: bad-name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.job.validate("node")
+			require.ErrorIs(err, os.ErrInvalid, "validate() did not return an ErrInvalid")
+			require.Equal(tt.want, err.Error(), "error did not match")
+		})
+	}
+}
+
+func TestJobsNewConfig(t *testing.T) {
+	require := require.New(t)
+	tempDir := createTempDir(t)
+	defer os.RemoveAll(tempDir)
+
+	// Other tests leave the file functions mocked so use the real ones and put them back after.
+	defer func(t func(string) error, r func(string) ([]byte, error), w func(string, []byte, os.FileMode) error) {
+		tester, reader, writer = t, r, w
+	}(tester, reader, writer)
+	tester, reader, writer = AssertReadable, os.ReadFile, os.WriteFile
+
+	f := filepath.Join(tempDir, "pim.yml")
+	l := NewLogger(&bytes.Buffer{}, "pim: ", log.LstdFlags, false)
+
+	t.Run("Success", func(t *testing.T) {
+		data := `jobs:
+  node_exporter:
+    port: 9100
+  blackbox_http:
+    scheme: https
+    labels:
+      module: http_2xx
+  blackbox_icmp:
+`
+		require.NoError(WriteFile(f, []byte(data), PermStdRead), "failed to write config file")
+
+		config, err := NewConfig(l, Flags{"config_file": f}, map[string]string{})
+		require.NoError(err, "NewConfig returned unexpected error")
+		require.Equal(
+			map[string]*JobConfig{
+				"node_exporter": {Port: 9100},
+				"blackbox_http": {Scheme: "https", Labels: map[string]string{"module": "http_2xx"}},
+				"blackbox_icmp": {},
+			},
+			config.Jobs,
+			"jobs did not match",
+		)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		data := `jobs:
+  node_exporter:
+    scheme: tcp
+`
+		require.NoError(WriteFile(f, []byte(data), PermStdRead), "failed to write config file")
+
+		_, err := NewConfig(l, Flags{"config_file": f}, map[string]string{})
+		require.ErrorIs(err, os.ErrInvalid, "NewConfig did not return an ErrInvalid")
+	})
+}
+
+func TestJobsSelects(t *testing.T) {
+	require := require.New(t)
+	j := &JobConfig{
+		Match:   map[string]string{"environment": "prod", "application": "webapp"},
+		Exclude: map[string]string{"os": "windows"},
+	}
+
+	require.True(j.Selects(map[string]string{"environment": "prod", "application": "webapp", "os": "linux"}))
+	require.False(j.Selects(map[string]string{"environment": "prod", "application": "webapp", "os": "windows"}))
+	require.False(j.Selects(map[string]string{"environment": "prod"}))
+	require.False(j.Selects(map[string]string{"environment": "dev", "application": "webapp"}))
+	require.False((&JobConfig{}).Selects(map[string]string{"environment": "prod"}), "job without match selected")
+}
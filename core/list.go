@@ -0,0 +1,45 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ListFileConfig sets the jobs and labels of a list source, a file with one target address per
+// line. It is only read from the list_files section of the config file, keyed by file name.
+/*
+	list_files:
+	  webapp_targets.txt:
+	    jobs: [node_exporter]
+	    labels:
+	      application: webapp
+*/
+type ListFileConfig struct {
+	Jobs   []string          `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// validateListFiles checks the jobs and labels of every list file.
+func (c *Config) validateListFiles() error {
+	for name, l := range c.ListFiles {
+		if l == nil {
+			c.ListFiles[name] = &ListFileConfig{}
+			continue
+		}
+
+		for _, job := range l.Jobs {
+			if strings.TrimSpace(job) == "" {
+				return fmt.Errorf("config: %w: list_files: %s: jobs can not be empty", os.ErrInvalid, name)
+			}
+		}
+
+		for label := range l.Labels {
+			if err := validateLabelName(label); err != nil {
+				return fmt.Errorf("config: %w: list_files: %s: %w", os.ErrInvalid, name, err)
+			}
+		}
+	}
+
+	return nil
+}
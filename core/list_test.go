@@ -0,0 +1,40 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListFilesValidate(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		c := &Config{ListFiles: map[string]*ListFileConfig{
+			"webapp_targets.txt": {Jobs: []string{"node"}, Labels: map[string]string{"application": "webapp"}},
+			"empty_targets.txt":  nil,
+		}}
+		require.NoError(c.validateListFiles(), "valid list_files returned an error")
+		require.Equal(&ListFileConfig{}, c.ListFiles["empty_targets.txt"], "empty list file was not set")
+	})
+
+	tests := []struct {
+		name string
+		list *ListFileConfig
+		want string
+	}{
+		{"EmptyJob", &ListFileConfig{Jobs: []string{" "}}, "config: invalid argument: list_files: a.txt: jobs can not be empty"},
+		{"LabelName", &ListFileConfig{Labels: map[string]string{"9bad": "x"}}, "config: invalid argument: list_files: a.txt: invalid label name: 9bad"},
+		{"Reserved", &ListFileConfig{Labels: map[string]string{"__meta": "x"}}, "config: invalid argument: list_files: a.txt: label name is reserved: __meta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{ListFiles: map[string]*ListFileConfig{"a.txt": tt.list}}
+			err := c.validateListFiles()
+			require.ErrorIs(err, os.ErrInvalid, "validateListFiles() did not return an ErrInvalid")
+			require.Equal(tt.want, err.Error(), "error did not match")
+		})
+	}
+}
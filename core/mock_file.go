@@ -107,6 +107,24 @@ func MockWriter(file string, data []byte, perm os.FileMode) error {
 	return nil
 }
 
+func MockRemover(file string) error {
+	if file == "" {
+		return fmt.Errorf("mock_file: %w", os.ErrNotExist)
+	}
+
+	f, err := getFile(file)
+	if err != nil {
+		return err
+	}
+
+	if f.Error != nil {
+		return f.Error
+	}
+
+	delete(MockFS, file)
+	return nil
+}
+
 var (
 	MockTestConfigYAML = []byte(`debug: true
 config_file: /tmp/pim.yml
@@ -114,8 +132,18 @@ export_types:
   - file_sd
 targets_file_ext: ".yaml"
 sources: /tmp/sources
+sources_cache_dir: /tmp/cache
+sources_timeout: 30
+source_format: ansible
 targets_dir: /tmp/targets
 targets_file_suffix: "_sd_targets"
+target_split:
+  - job
+  - datacenter
+merge_precedence: priority
+watch: true
+watch_interval: 10
+http_api_enabled: true
 `)
 	MockTestConfigJSON = []byte(`{
 "debug":          true,
@@ -123,8 +151,16 @@ targets_file_suffix: "_sd_targets"
 "export_types": [ "file_sd" ],
 "targets_file_ext":       ".yaml",
 "sources":  "/tmp/sources",
+"sources_cache_dir": "/tmp/cache",
+"sources_timeout": 30,
+"source_format": "ansible",
 "targets_dir":    "/tmp/targets",
-"targets_file_suffix": "_sd_targets"
+"targets_file_suffix": "_sd_targets",
+"target_split": [ "job", "datacenter" ],
+"merge_precedence": "priority",
+"watch": true,
+"watch_interval": 10,
+"http_api_enabled": true
 }`)
 	MockTestCert = []byte(`-----BEGIN CERTIFICATE-----
 MIIF1TCCA72gAwIBAgIUQFIA0nAR355w7z6OfjUI3TY5K1EwDQYJKoZIhvcNAQEN
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Relabel actions. They work the same way as the Prometheus relabel_configs actions.
+const (
+	RelabelReplace   = "replace"
+	RelabelKeep      = "keep"
+	RelabelDrop      = "drop"
+	RelabelHashMod   = "hashmod"
+	RelabelLabelMap  = "labelmap"
+	RelabelLabelDrop = "labeldrop"
+	RelabelLabelKeep = "labelkeep"
+
+	DefaultRelabelSeparator   = ";"
+	DefaultRelabelRegex       = "(.*)"
+	DefaultRelabelReplacement = "$1"
+	DefaultRelabelAction      = RelabelReplace
+)
+
+var validRelabelActions = []string{
+	RelabelReplace,
+	RelabelKeep,
+	RelabelDrop,
+	RelabelHashMod,
+	RelabelLabelMap,
+	RelabelLabelDrop,
+	RelabelLabelKeep,
+}
+
+// RelabelConfig is a single relabel rule. Rules are applied to each target before it is exported
+// with the address in the __address__ label.
+/*
+	relabel_configs:
+	  - source_labels: [__address__]
+	    regex: "([a-z]{3})[a-z]+[0-9]+.*"
+	    target_label: datacenter
+*/
+type RelabelConfig struct {
+	SourceLabels []string `json:"source_labels,omitempty" yaml:"source_labels,omitempty"`
+	Separator    string   `json:"separator,omitempty" yaml:"separator,omitempty"`
+	Regex        string   `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Modulus      uint64   `json:"modulus,omitempty" yaml:"modulus,omitempty"`
+	TargetLabel  string   `json:"target_label,omitempty" yaml:"target_label,omitempty"`
+	Replacement  string   `json:"replacement,omitempty" yaml:"replacement,omitempty"`
+	Action       string   `json:"action,omitempty" yaml:"action,omitempty"`
+}
+
+// relabelConfig is used to unmarshal RelabelConfig without recursing into the custom unmarshalers.
+type relabelConfig RelabelConfig
+
+// NewRelabelConfig returns a RelabelConfig with the default separator, regex, replacement and
+// action set.
+func NewRelabelConfig() *RelabelConfig {
+	return &RelabelConfig{
+		Separator:   DefaultRelabelSeparator,
+		Regex:       DefaultRelabelRegex,
+		Replacement: DefaultRelabelReplacement,
+		Action:      DefaultRelabelAction,
+	}
+}
+
+// UnmarshalYAML sets the defaults for any fields not in the config so an empty replacement can
+// still be set on purpose.
+func (r *RelabelConfig) UnmarshalYAML(value *yaml.Node) error {
+	obj := relabelConfig(*NewRelabelConfig())
+	if err := value.Decode(&obj); err != nil {
+		return err
+	}
+
+	*r = RelabelConfig(obj)
+	return nil
+}
+
+// UnmarshalJSON sets the defaults for any fields not in the config.
+func (r *RelabelConfig) UnmarshalJSON(data []byte) error {
+	obj := relabelConfig(*NewRelabelConfig())
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	*r = RelabelConfig(obj)
+	return nil
+}
+
+// Regexp compiles Regex anchored at both ends like Prometheus does.
+func (r *RelabelConfig) Regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + r.Regex + ")$")
+}
+
+// validate checks that the rule has what its action needs. where describes the rule for messages.
+func (r *RelabelConfig) validate(where string) error {
+	if !slices.Contains(validRelabelActions, r.Action) {
+		return fmt.Errorf(
+			"config: %w: %s: action: %s; must be one of: %s",
+			os.ErrInvalid,
+			where,
+			r.Action,
+			strings.Join(validRelabelActions, ", "),
+		)
+	}
+
+	if _, err := r.Regexp(); err != nil {
+		return fmt.Errorf("config: %w: %s: regex: %w", os.ErrInvalid, where, err)
+	}
+
+	switch r.Action {
+	case RelabelReplace, RelabelHashMod:
+		if r.TargetLabel == "" {
+			return fmt.Errorf("config: %w: %s: target_label is required for action %s", os.ErrInvalid, where, r.Action)
+		}
+	case RelabelKeep, RelabelDrop:
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("config: %w: %s: source_labels is required for action %s", os.ErrInvalid, where, r.Action)
+		}
+	}
+
+	if r.Action == RelabelHashMod && r.Modulus == 0 {
+		return fmt.Errorf("config: %w: %s: modulus is required for action %s", os.ErrInvalid, where, r.Action)
+	}
+
+	return nil
+}
+
+// validateRelabelConfigs checks a list of rules. where is the config path of the list, such as
+// jobs: node: relabel_configs.
+func validateRelabelConfigs(where string, rules []*RelabelConfig) error {
+	for i, r := range rules {
+		if r == nil {
+			return fmt.Errorf("config: %w: %s %d: rule is empty", os.ErrInvalid, where, i)
+		}
+
+		if err := r.validate(fmt.Sprintf("%s %d", where, i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
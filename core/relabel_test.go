@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRelabelUnmarshal(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("YAML", func(t *testing.T) {
+		var got []*RelabelConfig
+		data := `- source_labels: [__address__]
+  target_label: host
+- action: labeldrop
+  regex: tmp_.*
+  replacement: ""
+`
+		require.NoError(yaml.Unmarshal([]byte(data), &got), "yaml.Unmarshal returned an error")
+		require.Equal([]*RelabelConfig{
+			{
+				SourceLabels: []string{"__address__"},
+				Separator:    DefaultRelabelSeparator,
+				Regex:        DefaultRelabelRegex,
+				TargetLabel:  "host",
+				Replacement:  DefaultRelabelReplacement,
+				Action:       RelabelReplace,
+			},
+			{Separator: DefaultRelabelSeparator, Regex: "tmp_.*", Action: RelabelLabelDrop},
+		}, got, "relabel configs did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var got RelabelConfig
+		data := `{"source_labels": ["a", "b"], "separator": "-", "target_label": "c"}`
+		require.NoError(json.Unmarshal([]byte(data), &got), "json.Unmarshal returned an error")
+		want := NewRelabelConfig()
+		want.SourceLabels = []string{"a", "b"}
+		want.Separator = "-"
+		want.TargetLabel = "c"
+		require.Equal(*want, got, "relabel config did not match")
+	})
+}
+
+func TestRelabelValidate(t *testing.T) {
+	require := require.New(t)
+
+	newRule := func(f func(r *RelabelConfig)) *RelabelConfig {
+		r := NewRelabelConfig()
+		f(r)
+		return r
+	}
+
+	require.NoError(
+		validateRelabelConfigs("relabel_configs", []*RelabelConfig{
+			newRule(func(r *RelabelConfig) { r.TargetLabel = "host" }),
+			newRule(func(r *RelabelConfig) { r.Action = RelabelLabelMap; r.Regex = "__meta_(.+)" }),
+		}),
+		"valid rules returned an error",
+	)
+
+	tests := []struct {
+		name string
+		rule *RelabelConfig
+		want string
+	}{
+		{"Nil", nil, "config: invalid argument: relabel_configs 0: rule is empty"},
+		{
+			"Action",
+			newRule(func(r *RelabelConfig) { r.Action = "rename" }),
+			"config: invalid argument: relabel_configs 0: action: rename; must be one of: " +
+				"replace, keep, drop, hashmod, labelmap, labeldrop, labelkeep",
+		},
+		{
+			"Regex",
+			newRule(func(r *RelabelConfig) { r.TargetLabel = "host"; r.Regex = "(" }),
+			"config: invalid argument: relabel_configs 0: regex: error parsing regexp: missing closing ): `^(?:()$`",
+		},
+		{
+			"TargetLabel",
+			newRule(func(r *RelabelConfig) {}),
+			"config: invalid argument: relabel_configs 0: target_label is required for action replace",
+		},
+		{
+			"SourceLabels",
+			newRule(func(r *RelabelConfig) { r.Action = RelabelKeep }),
+			"config: invalid argument: relabel_configs 0: source_labels is required for action keep",
+		},
+		{
+			"Modulus",
+			newRule(func(r *RelabelConfig) { r.Action = RelabelHashMod; r.TargetLabel = "shard" }),
+			"config: invalid argument: relabel_configs 0: modulus is required for action hashmod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRelabelConfigs("relabel_configs", []*RelabelConfig{tt.rule})
+			require.ErrorIs(err, os.ErrInvalid, "validateRelabelConfigs() did not return an ErrInvalid")
+			require.Equal(tt.want, err.Error(), "error did not match")
+		})
+	}
+
+	t.Run("Job", func(t *testing.T) {
+		j := &JobConfig{RelabelConfigs: []*RelabelConfig{newRule(func(r *RelabelConfig) {})}}
+		err := j.validate("node")
+		require.Equal(
+			"config: invalid argument: jobs: node: relabel_configs 0: target_label is required for action replace",
+			err.Error(),
+			"error did not match",
+		)
+	})
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceNameRE matches valid source names.
+var sourceNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// SourceConfig is an entry of the sources list in the config file. Sources are loaded in order.
+/*
+	sources:
+	  - name: cmdb
+	    path: https://cmdb.example.com/pim/targets.json
+	    priority: 100
+	    labels:
+	      source: cmdb
+	  - name: ansible
+	    path: /etc/ansible/hosts.ini
+	    format: ansible
+	    enabled: false
+*/
+type SourceConfig struct {
+	// Name identifies the source in logs and is the origin of its groups.
+	Name string `json:"name" yaml:"name"`
+	// Path is a sources file or directory, or an http(s) URL.
+	Path string `json:"path" yaml:"path"`
+	// Format overrides source_format for this source.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Priority is added to the priority of every group in the source.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// Labels are added to every group in the source. Group labels win.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Enabled can be set to false to skip the source. Default true.
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// IsEnabled returns true unless the source was disabled.
+func (s *SourceConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// EnabledSources returns the enabled entries of the sources list in order.
+func (c *Config) EnabledSources() []*SourceConfig {
+	enabled := make([]*SourceConfig, 0, len(c.SourceList))
+	for _, s := range c.SourceList {
+		if s.IsEnabled() {
+			enabled = append(enabled, s)
+		}
+	}
+
+	return enabled
+}
+
+// UnmarshalYAML reads sources as either a single path or a sources list.
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	type plain Config
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			if value.Content[i].Value != "sources" || value.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+
+			if err := value.Content[i+1].Decode(&c.SourceList); err != nil {
+				return err
+			}
+
+			// Decode everything else without the list so Sources keeps its value.
+			rest := *value
+			rest.Content = slices.Delete(slices.Clone(value.Content), i, i+2)
+			return rest.Decode((*plain)(c))
+		}
+	}
+
+	return value.Decode((*plain)(c))
+}
+
+// UnmarshalJSON reads sources as either a single path or a sources list.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if v, ok := raw["sources"]; ok && len(v) > 0 && v[0] == '[' {
+		if err := json.Unmarshal(v, &c.SourceList); err != nil {
+			return err
+		}
+
+		// Decode everything else without the list so Sources keeps its value.
+		delete(raw, "sources")
+		rest, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+
+		data = rest
+	}
+
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// validateSources checks every entry of the sources list.
+func (c *Config) validateSources() error {
+	names := make(map[string]bool)
+	paths := make(map[string]string)
+	local := make([]*SourceConfig, 0, len(c.SourceList))
+	for i, s := range c.SourceList {
+		if s == nil || s.Name == "" {
+			return fmt.Errorf("config: %w: sources %d: name is required", os.ErrInvalid, i)
+		}
+
+		if !sourceNameRE.MatchString(s.Name) {
+			return fmt.Errorf("config: %w: sources %d: invalid name: %s", os.ErrInvalid, i, s.Name)
+		}
+
+		if names[s.Name] {
+			return fmt.Errorf("config: %w: sources: duplicate name: %s", os.ErrInvalid, s.Name)
+		}
+		names[s.Name] = true
+
+		if s.Path == "" {
+			return fmt.Errorf("config: %w: sources: %s: path is required", os.ErrInvalid, s.Name)
+		}
+
+		path := s.Path
+		isLocal := false
+		if u, err := url.Parse(path); err != nil || u.Host == "" {
+			path = filepath.Clean(path)
+			isLocal = true
+		}
+
+		if other, ok := paths[path]; ok {
+			return fmt.Errorf("config: %w: sources: %s: path is already used by %s", os.ErrInvalid, s.Name, other)
+		}
+		paths[path] = s.Name
+
+		// Files are matched to the source whose path holds them, so a source can not be inside
+		// another one.
+		if isLocal {
+			for _, other := range local {
+				if isSubPath(filepath.Clean(other.Path), path) || isSubPath(path, filepath.Clean(other.Path)) {
+					return fmt.Errorf(
+						"config: %w: sources: %s: path is nested with the path of %s",
+						os.ErrInvalid,
+						s.Name,
+						other.Name,
+					)
+				}
+			}
+
+			local = append(local, s)
+		}
+
+		if s.Format != "" && !slices.Contains(validSourceFormats, s.Format) {
+			return fmt.Errorf(
+				"config: %w: sources: %s: format: %s; must be one of: %s",
+				os.ErrInvalid,
+				s.Name,
+				s.Format,
+				strings.Join(validSourceFormats, ", "),
+			)
+		}
+
+		for l := range s.Labels {
+			if err := validateLabelName(l); err != nil {
+				return fmt.Errorf("config: %w: sources: %s: %w", os.ErrInvalid, s.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSubPath returns true if path is inside dir.
+func isSubPath(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSourcesUnmarshal(t *testing.T) {
+	require := require.New(t)
+
+	disabled := false
+	want := []*SourceConfig{
+		{Name: "cmdb", Path: "https://cmdb/pim.json", Priority: 100, Labels: map[string]string{"source": "cmdb"}},
+		{Name: "ansible", Path: "/etc/ansible/hosts.ini", Format: SourceFormatAnsible, Enabled: &disabled},
+	}
+
+	t.Run("YAML", func(t *testing.T) {
+		data := `sources:
+  - name: cmdb
+    path: https://cmdb/pim.json
+    priority: 100
+    labels:
+      source: cmdb
+  - name: ansible
+    path: /etc/ansible/hosts.ini
+    format: ansible
+    enabled: false
+merge_precedence: priority
+`
+		c := DefaultConfig()
+		require.NoError(yaml.Unmarshal([]byte(data), c), "yaml.Unmarshal returned an unexpected error")
+		require.Equal(want, c.SourceList, "sources did not match")
+		require.Equal(DefaultSources, c.Sources, "sources path was changed")
+		require.Equal(MergePriority, c.MergePrecedence, "merge_precedence did not match")
+		require.Equal([]*SourceConfig{want[0]}, c.EnabledSources(), "enabled sources did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data := `{
+"sources": [
+  {"name": "cmdb", "path": "https://cmdb/pim.json", "priority": 100, "labels": {"source": "cmdb"}},
+  {"name": "ansible", "path": "/etc/ansible/hosts.ini", "format": "ansible", "enabled": false}
+],
+"merge_precedence": "priority"
+}`
+		c := DefaultConfig()
+		require.NoError(json.Unmarshal([]byte(data), c), "json.Unmarshal returned an unexpected error")
+		require.Equal(want, c.SourceList, "sources did not match")
+		require.Equal(DefaultSources, c.Sources, "sources path was changed")
+		require.Equal(MergePriority, c.MergePrecedence, "merge_precedence did not match")
+	})
+
+	t.Run("Path", func(t *testing.T) {
+		c := DefaultConfig()
+		require.NoError(yaml.Unmarshal([]byte("sources: /tmp/sources\n"), c), "yaml.Unmarshal returned an unexpected error")
+		require.Equal("/tmp/sources", c.Sources, "sources did not match")
+		require.Nil(c.SourceList, "sources list was set")
+
+		require.NoError(json.Unmarshal([]byte(`{"sources": "/tmp/json"}`), c), "json.Unmarshal returned an unexpected error")
+		require.Equal("/tmp/json", c.Sources, "sources did not match")
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		c := &Config{SourceList: want}
+		require.NoError(c.setConfigValue("sources", "/tmp/sources"), "setConfigValue returned an unexpected error")
+		require.Nil(c.SourceList, "sources flag did not replace the sources list")
+	})
+}
+
+func TestSourcesValidate(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		c := &Config{SourceList: []*SourceConfig{
+			{Name: "local", Path: "/etc/pim/sources"},
+			{Name: "team", Path: "/etc/pim/sources-team"},
+			{Name: "cmdb", Path: "https://cmdb/pim.json", Format: SourceFormatPim},
+		}}
+		require.NoError(c.validateSources(), "valid sources returned an error")
+	})
+
+	tests := []struct {
+		name    string
+		sources []*SourceConfig
+		want    string
+	}{
+		{"Nil", []*SourceConfig{nil}, "config: invalid argument: sources 0: name is required"},
+		{"NoName", []*SourceConfig{{Path: "/a"}}, "config: invalid argument: sources 0: name is required"},
+		{"Name", []*SourceConfig{{Name: "a b", Path: "/a"}}, "config: invalid argument: sources 0: invalid name: a b"},
+		{"NoPath", []*SourceConfig{{Name: "a"}}, "config: invalid argument: sources: a: path is required"},
+		{
+			"Duplicate",
+			[]*SourceConfig{{Name: "a", Path: "/a"}, {Name: "a", Path: "/b"}},
+			"config: invalid argument: sources: duplicate name: a",
+		},
+		{
+			"SamePath",
+			[]*SourceConfig{{Name: "a", Path: "/a"}, {Name: "b", Path: "/a/"}},
+			"config: invalid argument: sources: b: path is already used by a",
+		},
+		{
+			"NestedFile",
+			[]*SourceConfig{{Name: "a", Path: "/a"}, {Name: "b", Path: "/a/b_targets.yml"}},
+			"config: invalid argument: sources: b: path is nested with the path of a",
+		},
+		{
+			"NestedDir",
+			[]*SourceConfig{{Name: "a", Path: "/a/b"}, {Name: "b", Path: "http://pim/targets.yml"}, {Name: "c", Path: "/a"}},
+			"config: invalid argument: sources: c: path is nested with the path of a",
+		},
+		{
+			"Format",
+			[]*SourceConfig{{Name: "a", Path: "/a", Format: "chef"}},
+			"config: invalid argument: sources: a: format: chef; must be one of: pim, ansible",
+		},
+		{
+			"Label",
+			[]*SourceConfig{{Name: "a", Path: "/a", Labels: map[string]string{"__meta": "x"}}},
+			"config: invalid argument: sources: a: label name is reserved: __meta",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{SourceList: tt.sources}
+			err := c.validateSources()
+			require.ErrorIs(err, os.ErrInvalid, "validateSources() did not return an ErrInvalid")
+			require.Equal(tt.want, err.Error(), "error did not match")
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Package metrics holds the registry pim's own metrics are registered with and serves it in the
+// Prometheus exposition format.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Default is the registry served by HandleMetrics. It only holds pim's own metrics, not the Go
+// runtime and process collectors of the client_golang default registry.
+var Default = prometheus.NewRegistry()
+
+// Handler serves the metrics in r.
+func Handler(r *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	require := require.New(t)
+	r := prometheus.NewRegistry()
+	a := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_a", Help: "A."}, []string{"code"})
+	b := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_b", Help: "B.\nOne line."})
+	r.MustRegister(b, a)
+
+	a.WithLabelValues(`"200"`).Inc()
+	b.Set(1)
+
+	w := httptest.NewRecorder()
+	Handler(r).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(http.StatusOK, w.Code, "status code did not match")
+	require.Contains(w.Header().Get("Content-Type"), "text/plain; version=0.0.4", "content type did not match")
+	require.Equal(`# HELP test_a A.
+# TYPE test_a counter
+test_a{code="\"200\""} 1
+# HELP test_b B.\nOne line.
+# TYPE test_b gauge
+test_b 1
+`, w.Body.String(), "registry output did not match")
+
+	// Reading the metrics must not reset them.
+	w = httptest.NewRecorder()
+	Handler(r).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Contains(w.Body.String(), `test_a{code="\"200\""} 1`, "counter was reset by a read")
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsInstrument(t *testing.T) {
+	require := require.New(t)
+	mux := http.NewServeMux()
+
+	root, err := NewRouterGroup(mux, "/metrics_test")
+	require.NoError(err, "NewRouterGroup() returned an error: %s", err)
+	root.GET("/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	for _, id := range []string{"1", "2"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics_test/"+id, nil))
+	}
+
+	w := httptest.NewRecorder()
+	HandleMetrics().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Contains(w.Header().Get("Content-Type"), "text/plain; version=0.0.4", "content type did not match")
+	require.Contains(
+		w.Body.String(),
+		`pim_http_requests_total{code="418",method="GET",route="/metrics_test/{id}"} 2`,
+		"request counter did not match",
+	)
+	require.Contains(
+		w.Body.String(),
+		`pim_http_request_duration_seconds_count{method="GET",route="/metrics_test/{id}"} 2`,
+		"request histogram did not match",
+	)
+}
+
+func TestMetricsRouteName(t *testing.T) {
+	require := require.New(t)
+	require.Equal("/v1/items/{id}", routeName("GET /v1/items/{id}"))
+	require.Equal("/targets/", routeName("/targets/"))
+}
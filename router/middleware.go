@@ -95,8 +95,6 @@ func LoggerMiddleware(logger *core.Logger) Middleware {
 				rm.ResponseSize = m.Written
 				rm.Duration = m.Duration
 
-				// Add request metrics to the global metrics.
-				RecordRequest(rm.ResponseCode, rm.Duration)
 				log, err := json.Marshal(rm)
 				if err != nil {
 					logger.Printf("LoggerMiddleware: failed to marshal request metrics: %v\n", err)
@@ -32,11 +32,14 @@ func cleanPath(path string) string {
 		path = "/" + path
 	}
 
+	// Collapse duplicate slashes first so joining a group path with "/" does not leave a
+	// trailing slash behind.
+	path = duplicateSlashes.ReplaceAllString(path, "/")
 	if len(path) > 1 && path[len(path)-1] == '/' {
 		path = path[:len(path)-1]
 	}
 
-	return duplicateSlashes.ReplaceAllString(path, "/")
+	return path
 }
 
 func cleanMiddleware(middleware []Middleware) []Middleware {
@@ -101,10 +104,33 @@ func (group *RouterGroup) ANY(path string, handler http.Handler, middleware ...M
 	if mux == nil {
 		mux = group.root.mux
 	}
-	mux.Handle(cleanPath(group.basePath+"/"+path), h)
+
+	pattern := cleanPath(group.basePath + "/" + path)
+	mux.Handle(pattern, Instrument(pattern, h))
 }
 
 func (group *RouterGroup) GET(path string, handler http.Handler, middleware ...Middleware) {
+	group.handle(http.MethodGet, path, handler, middleware)
+}
+
+func (group *RouterGroup) POST(path string, handler http.Handler, middleware ...Middleware) {
+	group.handle(http.MethodPost, path, handler, middleware)
+}
+
+func (group *RouterGroup) PUT(path string, handler http.Handler, middleware ...Middleware) {
+	group.handle(http.MethodPut, path, handler, middleware)
+}
+
+func (group *RouterGroup) PATCH(path string, handler http.Handler, middleware ...Middleware) {
+	group.handle(http.MethodPatch, path, handler, middleware)
+}
+
+func (group *RouterGroup) DELETE(path string, handler http.Handler, middleware ...Middleware) {
+	group.handle(http.MethodDelete, path, handler, middleware)
+}
+
+// handle registers handler for requests matching method and path.
+func (group *RouterGroup) handle(method, path string, handler http.Handler, middleware []Middleware) {
 	h := group.genHandler(handler, middleware)
 	path = cleanPath(path)
 
@@ -112,7 +138,9 @@ func (group *RouterGroup) GET(path string, handler http.Handler, middleware ...M
 	if mux == nil {
 		mux = group.root.mux
 	}
-	mux.Handle("GET "+cleanPath(group.basePath+"/"+path), h)
+
+	pattern := method + " " + cleanPath(group.basePath+"/"+path)
+	mux.Handle(pattern, Instrument(routeName(pattern), h))
 }
 
 func (group RouterGroup) genHandler(h http.Handler, middleware []Middleware) http.Handler {
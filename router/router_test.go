@@ -2,6 +2,7 @@ package router
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -126,6 +127,23 @@ func TestRouterGroup(t *testing.T) {
 	})
 }
 
+func TestRouterCleanPath(t *testing.T) {
+	require := require.New(t)
+
+	tests := map[string]string{
+		"":             "/",
+		"/":            "/",
+		"v1":           "/v1",
+		"/v1/":         "/v1",
+		"/v1//":        "/v1",
+		"//v1//items/": "/v1/items",
+	}
+
+	for path, want := range tests {
+		require.Equal(want, cleanPath(path), "cleanPath(%q) did not match", path)
+	}
+}
+
 func TestRouterGET(t *testing.T) {
 	require := require.New(t)
 	mux := &http.ServeMux{}
@@ -146,3 +164,41 @@ func TestRouterGET(t *testing.T) {
 		root.GET("/test2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), testMiddleware)
 	})
 }
+
+func TestRouterMethods(t *testing.T) {
+	require := require.New(t)
+	mux := http.NewServeMux()
+
+	root, err := NewRouterGroup(mux, "/v1")
+	require.NoError(err, "NewRouterGroup() returned an error: %s", err)
+
+	status := func(code int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) })
+	}
+
+	items := root.Group("/items")
+	items.POST("/", status(http.StatusCreated))
+	items.PUT("/{id}", status(http.StatusOK), testMiddleware)
+	items.PATCH("/{id}", status(http.StatusAccepted))
+	items.DELETE("/{id}", status(http.StatusNoContent))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/v1/items", http.StatusCreated},
+		{http.MethodPut, "/v1/items/1", http.StatusOK},
+		{http.MethodPatch, "/v1/items/1", http.StatusAccepted},
+		{http.MethodDelete, "/v1/items/1", http.StatusNoContent},
+		{http.MethodGet, "/v1/items/1", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			require.Equal(tt.want, w.Code, "status code did not match")
+		})
+	}
+}
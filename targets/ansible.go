@@ -0,0 +1,576 @@
+package targets
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	inventoryINI  = "ini"
+	inventoryYAML = "yaml"
+
+	// Ansible puts every group without a parent under all and every host without a group in
+	// ungrouped.
+	inventoryAll       = "all"
+	inventoryUngrouped = "ungrouped"
+)
+
+// inventoryFormat returns the inventory format of file, or an empty string if it is a pim source
+// file. .ini files are always Ansible inventories. Other files are only read as inventories when
+// source_format is ansible, in which case files other than YAML, JSON, CSV and list sources are
+// read as INI.
+func inventoryFormat(config *core.Config, file string) string {
+	ext := filepath.Ext(file)
+	if ext == ".ini" {
+		return inventoryINI
+	}
+
+	if config == nil || config.SourceFormat != core.SourceFormatAnsible {
+		return ""
+	}
+
+	switch {
+	case ext == core.DefaultYAMLFileExt || ext == ".yaml" || ext == core.DefaultJSONFileExt:
+		return inventoryYAML
+	case ext == csvFileExt || isListFile(file):
+		return ""
+	}
+
+	return inventoryINI
+}
+
+// IsInventory returns true if file is read as an Ansible inventory.
+func IsInventory(config *core.Config, file string) bool {
+	return inventoryFormat(config, file) != ""
+}
+
+// ReadInventoryFile reads an Ansible inventory and converts each host to a TargetGroup using the
+// config ansible settings.
+func ReadInventoryFile(config *core.Config, file string) (TargetGroups, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := parseInventory(inventoryFormat(config, file), data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ansible inventory %s: %w", os.ErrInvalid, file, err)
+	}
+
+	var ac core.AnsibleConfig
+	if config != nil {
+		ac = config.Ansible
+	}
+
+	return inv.targetGroups(ac, file), nil
+}
+
+// validateInventory reports the first problem found parsing an inventory file.
+func validateInventory(config *core.Config, file string, data []byte) Problems {
+	inv, err := parseInventory(inventoryFormat(config, file), data)
+	if err != nil {
+		return Problems{lineProblem(file, err)}
+	}
+
+	if len(inv.hosts) == 0 {
+		return Problems{{File: file, Message: "no hosts found in ansible inventory"}}
+	}
+
+	return Problems{}
+}
+
+// inventory holds the groups, hosts and vars of an Ansible inventory.
+type inventory struct {
+	groups map[string]*inventoryGroup
+	// hosts are in the order they are first seen.
+	hosts    []string
+	hostVars map[string]map[string]string
+}
+
+type inventoryGroup struct {
+	hosts    []string
+	children []string
+	vars     map[string]string
+}
+
+func newInventory() *inventory {
+	return &inventory{
+		groups:   make(map[string]*inventoryGroup),
+		hosts:    make([]string, 0),
+		hostVars: make(map[string]map[string]string),
+	}
+}
+
+func parseInventory(format string, data []byte) (*inventory, error) {
+	if format == inventoryYAML {
+		return parseYAMLInventory(data)
+	}
+
+	return parseINIInventory(data)
+}
+
+// group returns the group called name, adding it if needed.
+func (inv *inventory) group(name string) *inventoryGroup {
+	g, ok := inv.groups[name]
+	if !ok {
+		g = &inventoryGroup{vars: make(map[string]string)}
+		inv.groups[name] = g
+	}
+
+	return g
+}
+
+// addHost adds host to group. vars are merged over any vars already set for the host.
+func (inv *inventory) addHost(group, host string, vars map[string]string) {
+	g := inv.group(group)
+	if !slices.Contains(g.hosts, host) {
+		g.hosts = append(g.hosts, host)
+	}
+
+	hv, ok := inv.hostVars[host]
+	if !ok {
+		hv = make(map[string]string)
+		inv.hostVars[host] = hv
+		inv.hosts = append(inv.hosts, host)
+	}
+
+	maps.Copy(hv, vars)
+}
+
+// addChild makes child a child group of parent.
+func (inv *inventory) addChild(parent, child string) {
+	g := inv.group(parent)
+	inv.group(child)
+	if !slices.Contains(g.children, child) {
+		g.children = append(g.children, child)
+	}
+}
+
+// parseINIInventory parses an INI inventory with [group], [group:vars] and [group:children]
+// sections. Hosts before the first section are ungrouped.
+func parseINIInventory(data []byte) (*inventory, error) {
+	inv := newInventory()
+	group, kind := inventoryUngrouped, "hosts"
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, newLineError(n, "unclosed section %q", line)
+			}
+
+			var ok bool
+			group, kind, ok = strings.Cut(line[1:len(line)-1], ":")
+			switch {
+			case group == "":
+				return nil, newLineError(n, "section %q has no group name", line)
+			case !ok:
+				kind = "hosts"
+			case kind != "vars" && kind != "children":
+				return nil, newLineError(n, "unknown section type %q; must be one of: vars, children", kind)
+			}
+
+			inv.group(group)
+			continue
+		}
+
+		fields, err := splitINIFields(line)
+		if err != nil {
+			return nil, newLineError(n, "%s", err)
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch kind {
+		case "hosts":
+			vars := make(map[string]string)
+			for _, f := range fields[1:] {
+				k, v, ok := strings.Cut(f, "=")
+				if !ok || k == "" {
+					return nil, newLineError(n, "host %s: invalid var %q; must be key=value", fields[0], f)
+				}
+
+				vars[k] = v
+			}
+
+			inv.addHost(group, fields[0], vars)
+		case "vars":
+			k, v, ok := strings.Cut(line, "=")
+			k = strings.TrimSpace(k)
+			if !ok || k == "" {
+				return nil, newLineError(n, "group %s: invalid var %q; must be key=value", group, line)
+			}
+
+			values, err := splitINIFields(v)
+			if err != nil {
+				return nil, newLineError(n, "%s", err)
+			}
+
+			inv.group(group).vars[k] = strings.Join(values, " ")
+		case "children":
+			inv.addChild(group, fields[0])
+		}
+	}
+
+	return inv, nil
+}
+
+// splitINIFields splits line on spaces. Quoted values can contain spaces and a # outside of
+// quotes starts a comment.
+func splitINIFields(line string) ([]string, error) {
+	fields := make([]string, 0)
+	var b strings.Builder
+	var quote rune
+	inField := false
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+
+			b.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inField = r, true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, b.String())
+				b.Reset()
+				inField = false
+			}
+		case r == '#' && !inField:
+			return fields, nil
+		default:
+			b.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote in %q", line)
+	}
+
+	if inField {
+		fields = append(fields, b.String())
+	}
+
+	return fields, nil
+}
+
+// parseYAMLInventory parses a YAML inventory. The top level maps group names to groups with
+// hosts, vars and children keys.
+func parseYAMLInventory(data []byte) (*inventory, error) {
+	inv := newInventory()
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return inv, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, newLineError(root.Line, "inventory must be a mapping of groups")
+	}
+
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if err := inv.yamlGroup(root.Content[i].Value, root.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return inv, nil
+}
+
+func (inv *inventory) yamlGroup(name string, node *yaml.Node) error {
+	g := inv.group(name)
+	if isYAMLNull(node) {
+		return nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return newLineError(node.Line, "group %s must be a mapping", name)
+	}
+
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "hosts":
+			if isYAMLNull(value) {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode {
+				return newLineError(value.Line, "group %s: hosts must be a mapping", name)
+			}
+
+			for j := 0; j < len(value.Content)-1; j += 2 {
+				vars, err := yamlVars(value.Content[j+1])
+				if err != nil {
+					return err
+				}
+
+				inv.addHost(name, value.Content[j].Value, vars)
+			}
+		case "vars":
+			vars, err := yamlVars(value)
+			if err != nil {
+				return err
+			}
+
+			maps.Copy(g.vars, vars)
+		case "children":
+			if isYAMLNull(value) {
+				continue
+			}
+
+			if value.Kind != yaml.MappingNode {
+				return newLineError(value.Line, "group %s: children must be a mapping", name)
+			}
+
+			for j := 0; j < len(value.Content)-1; j += 2 {
+				child := value.Content[j].Value
+				inv.addChild(name, child)
+				if err := inv.yamlGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		default:
+			return newLineError(key.Line, "group %s: unknown key %q; must be one of: hosts, vars, children", name, key.Value)
+		}
+	}
+
+	return nil
+}
+
+// yamlVars returns the scalar vars in node. Lists and mappings can not be labels and are skipped.
+func yamlVars(node *yaml.Node) (map[string]string, error) {
+	vars := make(map[string]string)
+	if isYAMLNull(node) {
+		return vars, nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, newLineError(node.Line, "vars must be a mapping")
+	}
+
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if v := node.Content[i+1]; v.Kind == yaml.ScalarNode && !isYAMLNull(v) {
+			vars[node.Content[i].Value] = v.Value
+		}
+	}
+
+	return vars, nil
+}
+
+func isYAMLNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// parents returns the parent groups of every group. Groups without a parent are children of all.
+func (inv *inventory) parents() map[string][]string {
+	parents := make(map[string][]string)
+	for _, name := range sortedKeys(inv.groups) {
+		for _, child := range inv.groups[name].children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+
+	for name := range inv.groups {
+		if name != inventoryAll && len(parents[name]) == 0 {
+			parents[name] = []string{inventoryAll}
+		}
+	}
+
+	return parents
+}
+
+// depths returns how far below all each group is. A group with more than one parent is placed
+// below the deepest one.
+func (inv *inventory) depths(parents map[string][]string) map[string]int {
+	depths := map[string]int{inventoryAll: 0}
+	var depth func(name string, seen []string) int
+	depth = func(name string, seen []string) int {
+		if d, ok := depths[name]; ok {
+			return d
+		}
+
+		d := 0
+		for _, p := range parents[name] {
+			// Ignore a parent that is also a child so a cycle of groups does not loop forever.
+			if !slices.Contains(seen, p) {
+				d = max(d, depth(p, append(seen, name))+1)
+			}
+		}
+
+		depths[name] = d
+		return d
+	}
+
+	for name := range inv.groups {
+		depth(name, nil)
+	}
+
+	return depths
+}
+
+// hostGroups returns every group host is in, including the parents of its groups and all. Like
+// Ansible, groups are ordered by depth and then by name so vars of child groups win over their
+// parents.
+func (inv *inventory) hostGroups(host string, parents map[string][]string, depths map[string]int) []string {
+	groups := []string{inventoryAll}
+	var add func(name string)
+	add = func(name string) {
+		if slices.Contains(groups, name) {
+			return
+		}
+
+		groups = append(groups, name)
+		for _, p := range parents[name] {
+			add(p)
+		}
+	}
+
+	for _, name := range sortedKeys(inv.groups) {
+		if slices.Contains(inv.groups[name].hosts, host) {
+			add(name)
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b string) int {
+		if depths[a] != depths[b] {
+			return depths[a] - depths[b]
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	return groups
+}
+
+// ansibleRule is a core.AnsibleGroup with its regex compiled.
+type ansibleRule struct {
+	*core.AnsibleGroup
+	regex *regexp.Regexp
+}
+
+// newAnsibleRules compiles groups. Bad rules are skipped since the config is checked when it is
+// loaded.
+func newAnsibleRules(groups []*core.AnsibleGroup) []ansibleRule {
+	rules := make([]ansibleRule, 0, len(groups))
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+
+		re, err := g.Regexp()
+		if err != nil {
+			continue
+		}
+
+		rules = append(rules, ansibleRule{AnsibleGroup: g, regex: re})
+	}
+
+	return rules
+}
+
+// targetGroups converts every host to a TargetGroup with the jobs and labels of the rules that
+// match its groups and its host vars as target labels.
+func (inv *inventory) targetGroups(ac core.AnsibleConfig, file string) TargetGroups {
+	rules := newAnsibleRules(ac.Groups)
+	parents := inv.parents()
+	depths := inv.depths(parents)
+	tgs := make(TargetGroups, 0, len(inv.hosts))
+	for i, host := range inv.hosts {
+		groups := inv.hostGroups(host, parents, depths)
+		tg := &TargetGroup{source: file, index: i}
+		labels := make(map[string]string)
+		for _, r := range rules {
+			for _, g := range groups {
+				m := r.regex.FindStringSubmatchIndex(g)
+				if m == nil {
+					continue
+				}
+
+				for _, job := range r.Jobs {
+					if !slices.Contains(tg.Jobs, job) {
+						tg.Jobs = append(tg.Jobs, job)
+					}
+				}
+
+				for k, v := range r.Labels {
+					if v = string(r.regex.ExpandString(nil, v, g, m)); v != "" {
+						labels[k] = v
+					}
+				}
+			}
+		}
+
+		if len(labels) > 0 {
+			tg.Labels = labels
+		}
+
+		vars := make(map[string]string)
+		for _, g := range groups {
+			if ig, ok := inv.groups[g]; ok {
+				maps.Copy(vars, ig.vars)
+			}
+		}
+		maps.Copy(vars, inv.hostVars[host])
+
+		target := Target{Address: host, Labels: hostLabels(ac.HostVars, vars)}
+		if v := vars[ac.AddressVar]; ac.AddressVar != "" && v != "" {
+			target.Address = v
+		}
+
+		tg.Targets = []Target{target}
+		tgs = append(tgs, tg)
+	}
+
+	return tgs
+}
+
+// hostLabels returns the vars to add to a target as labels. If names is empty every var that is
+// a valid label name is used except the ansible_ vars.
+func hostLabels(names []string, vars map[string]string) map[string]string {
+	labels := make(map[string]string)
+	if len(names) > 0 {
+		for _, name := range names {
+			if v := vars[name]; v != "" {
+				labels[name] = v
+			}
+		}
+	} else {
+		for k, v := range vars {
+			if v == "" || !labelNameRE.MatchString(k) || k == "job" ||
+				strings.HasPrefix(k, "__") || strings.HasPrefix(k, "ansible_") {
+				continue
+			}
+
+			labels[k] = v
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil
+	}
+
+	return labels
+}
@@ -0,0 +1,280 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testINIInventory = `# Hosts before a section are ungrouped.
+bastion01 ansible_host=10.0.0.5
+
+[webservers]
+atlweb01 rack=r01
+atlweb02 rack=r12 owner="web team" # inline comment
+
+[dbservers]
+jfkdb01 ansible_host=10.1.0.10
+
+[dc_atl]
+atlweb01
+atlweb02
+
+[prod:children]
+webservers
+dbservers
+
+[prod:vars]
+environment=prod
+rack = r00
+`
+	testYAMLInventory = `all:
+  hosts:
+    bastion01:
+      ansible_host: 10.0.0.5
+  children:
+    prod:
+      vars:
+        environment: prod
+        rack: r00
+      children:
+        webservers:
+          hosts:
+            atlweb01:
+              rack: r01
+            atlweb02:
+              rack: r12
+              owner: web team
+              tags: [a, b]
+        dbservers:
+          hosts:
+            jfkdb01:
+              ansible_host: 10.1.0.10
+    dc_atl:
+      hosts:
+        atlweb01:
+        atlweb02:
+`
+)
+
+func newTestAnsibleConfig() *core.Config {
+	config := core.DefaultConfig()
+	config.Ansible = core.AnsibleConfig{
+		Groups: []*core.AnsibleGroup{
+			{Group: "webservers", Jobs: []string{"node", "blackbox_http"}},
+			{Group: "dbservers", Jobs: []string{"node", "mysql"}},
+			{Group: "dc_(.*)", Labels: map[string]string{"datacenter": "$1"}},
+		},
+		AddressVar: "ansible_host",
+	}
+
+	return config
+}
+
+func TestInventoryTargetGroups(t *testing.T) {
+	require := require.New(t)
+
+	// Both formats describe the same inventory.
+	want := TargetGroups{
+		&TargetGroup{
+			Targets: []Target{{Address: "10.0.0.5"}},
+		},
+		&TargetGroup{
+			Jobs:    []string{"node", "blackbox_http"},
+			Labels:  map[string]string{"datacenter": "atl"},
+			Targets: []Target{{Address: "atlweb01", Labels: map[string]string{"environment": "prod", "rack": "r01"}}},
+		},
+		&TargetGroup{
+			Jobs:   []string{"node", "blackbox_http"},
+			Labels: map[string]string{"datacenter": "atl"},
+			Targets: []Target{{
+				Address: "atlweb02",
+				Labels:  map[string]string{"environment": "prod", "rack": "r12", "owner": "web team"},
+			}},
+		},
+		&TargetGroup{
+			Jobs:    []string{"node", "mysql"},
+			Targets: []Target{{Address: "10.1.0.10", Labels: map[string]string{"environment": "prod", "rack": "r00"}}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"INI", inventoryINI, testINIInventory},
+		{"YAML", inventoryYAML, testYAMLInventory},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := parseInventory(tt.format, []byte(tt.data))
+			require.NoError(err, "parseInventory returned an unexpected error")
+
+			got := inv.targetGroups(newTestAnsibleConfig().Ansible, "")
+			require.Len(got, len(want), "wrong number of groups")
+			for i := range want {
+				require.Equal(want[i].Jobs, got[i].Jobs, "group %d jobs did not match", i)
+				require.Equal(want[i].Labels, got[i].Labels, "group %d labels did not match", i)
+				require.Equal(want[i].Targets, got[i].Targets, "group %d targets did not match", i)
+			}
+		})
+	}
+
+	t.Run("HostVars", func(t *testing.T) {
+		inv, err := parseInventory(inventoryINI, []byte(testINIInventory))
+		require.NoError(err, "parseInventory returned an unexpected error")
+
+		ac := core.AnsibleConfig{HostVars: []string{"rack"}}
+		got := inv.targetGroups(ac, "hosts.ini")
+		require.Equal(Target{Address: "bastion01"}, got[0].Targets[0], "ungrouped target did not match")
+		require.Equal(
+			Target{Address: "atlweb02", Labels: map[string]string{"rack": "r12"}},
+			got[2].Targets[0],
+			"target did not match",
+		)
+		require.Equal("hosts.ini", got[2].source, "source did not match")
+		require.Equal(2, got[2].index, "index did not match")
+	})
+}
+
+func TestInventoryHostGroups(t *testing.T) {
+	require := require.New(t)
+
+	inv, err := parseInventory(inventoryINI, []byte("[a]\nhost01\n[b]\nhost01\n[b:children]\na\n[c:children]\nb\n"))
+	require.NoError(err, "parseInventory returned an unexpected error")
+
+	parents := inv.parents()
+	got := inv.hostGroups("host01", parents, inv.depths(parents))
+	require.Equal([]string{"all", "c", "b", "a"}, got, "groups did not match")
+}
+
+func TestInventoryErrors(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   string
+	}{
+		{"UnclosedSection", inventoryINI, "[web\nhost01\n", `line 1: unclosed section "[web"`},
+		{"SectionType", inventoryINI, "[web:hosts]\n", `line 1: unknown section type "hosts"; must be one of: vars, children`},
+		{"HostVar", inventoryINI, "[web]\nhost01 rack\n", `line 2: host host01: invalid var "rack"; must be key=value`},
+		{"GroupVar", inventoryINI, "[web:vars]\nrack\n", `line 2: group web: invalid var "rack"; must be key=value`},
+		{"Quote", inventoryINI, "[web]\nhost01 owner=\"web\n", `line 2: unclosed quote in "host01 owner=\"web"`},
+		{"NotAMapping", inventoryYAML, "- host01\n", "line 1: inventory must be a mapping of groups"},
+		{"UnknownKey", inventoryYAML, "web:\n  targets: []\n", `line 2: group web: unknown key "targets"; must be one of: hosts, vars, children`},
+		{"Hosts", inventoryYAML, "web:\n  hosts: [host01]\n", "line 2: group web: hosts must be a mapping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseInventory(tt.format, []byte(tt.data))
+			require.EqualError(err, tt.want, "error did not match")
+		})
+	}
+}
+
+func TestReadSource(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "ansible_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"hosts.ini":       testINIInventory,
+		"inventory.yml":   testYAMLInventory,
+		"pim_targets.yml": "- jobs: [node]\n  targets: [host01]\n",
+		"hosts":           "[web]\nhost01\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644)
+		require.NoError(err, "failed to write %s", name)
+	}
+
+	config := newTestAnsibleConfig()
+
+	t.Run("Extension", func(t *testing.T) {
+		require.True(IsInventory(config, "hosts.ini"), ".ini file was not an inventory")
+		require.False(IsInventory(config, "inventory.yml"), ".yml file was an inventory")
+
+		got, err := ReadSource(config, filepath.Join(tempDir, "hosts.ini"))
+		require.NoError(err, "ReadSource returned an unexpected error")
+		require.Len(got, 4, "wrong number of groups")
+
+		got, err = ReadSource(config, filepath.Join(tempDir, "pim_targets.yml"))
+		require.NoError(err, "ReadSource returned an unexpected error")
+		require.Equal([]string{"node"}, got[0].Jobs, "pim source jobs did not match")
+	})
+
+	t.Run("SourceFormat", func(t *testing.T) {
+		config := newTestAnsibleConfig()
+		config.SourceFormat = core.SourceFormatAnsible
+
+		got, err := ReadSource(config, filepath.Join(tempDir, "inventory.yml"))
+		require.NoError(err, "ReadSource returned an unexpected error")
+		require.Len(got, 4, "wrong number of groups")
+
+		got, err = ReadSource(config, filepath.Join(tempDir, "hosts"))
+		require.NoError(err, "ReadSource returned an unexpected error")
+		require.Equal("host01", got[0].Targets[0].Address, "target did not match")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		file := filepath.Join(tempDir, "bad.ini")
+		err := os.WriteFile(file, []byte("[web\n"), 0o644)
+		require.NoError(err, "failed to write %s", file)
+
+		_, err = ReadSource(config, file)
+		require.ErrorIs(err, os.ErrInvalid, "ReadSource did not return the correct error")
+	})
+}
+
+func TestInventorySources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "ansible_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := newTestAnsibleConfig()
+	config.Sources = tempDir
+
+	file := filepath.Join(tempDir, "hosts_targets.ini")
+	err = os.WriteFile(file, []byte(testINIInventory), 0o644)
+	require.NoError(err, "failed to write %s", file)
+
+	t.Run("NewTargetGroups", func(t *testing.T) {
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 4, "wrong number of groups")
+
+		jobs := tgs.JobGroups(config)
+		require.Equal([]string{"10.1.0.10"}, jobs["mysql"][0].Targets, "mysql targets did not match")
+		require.Len(jobs["node"], 3, "wrong number of node groups")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		got, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Empty(got, "valid inventory had problems")
+
+		err = os.WriteFile(file, []byte("[web]\nhost01 rack\n"), 0o644)
+		require.NoError(err, "failed to write %s", file)
+
+		got, err = ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Equal(
+			Problems{{File: file, Line: 2, Message: `host host01: invalid var "rack"; must be key=value`}},
+			got,
+			"problems did not match",
+		)
+	})
+}
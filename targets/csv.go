@@ -0,0 +1,165 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+const (
+	csvFileExt = ".csv"
+	// csvJobsSeparator separates the jobs in the jobs column.
+	csvJobsSeparator = ";"
+)
+
+// csvAddressColumns are the header names allowed for the target address column.
+var csvAddressColumns = []string{"target", "address"}
+
+// ReadCSVFile reads a CSV source. The header row names the columns: target or address holds the
+// target address, jobs holds the jobs separated by ; and every other column is a label. Each row
+// becomes a TargetGroup and empty cells are skipped. An empty file returns no groups.
+//
+//	target,jobs,datacenter,rack
+//	atlwebapp01,node;blackbox_icmp,atl,r01
+func ReadCSVFile(file string) (TargetGroups, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tgs, _, err := parseCSV(file, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", os.ErrInvalid, file, err)
+	}
+
+	return tgs, nil
+}
+
+// parseCSV returns the groups in data and the line of each one.
+func parseCSV(file string, data []byte) (TargetGroups, []int, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return TargetGroups{}, []int{}, nil
+	}
+
+	if err != nil {
+		return nil, nil, csvError(err)
+	}
+
+	line, _ := r.FieldPos(0)
+	addrCol, jobsCol := -1, -1
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		header[i] = name
+		switch {
+		case slices.Contains(header[:i], name):
+			return nil, nil, newLineError(line, "duplicate column %q", name)
+		case slices.Contains(csvAddressColumns, name):
+			if addrCol >= 0 {
+				return nil, nil, newLineError(line, "more than one target column")
+			}
+
+			addrCol = i
+		case name == "jobs":
+			jobsCol = i
+		case !labelNameRE.MatchString(name):
+			return nil, nil, newLineError(line, "invalid label name %q", name)
+		case name == "job" || strings.HasPrefix(name, "__"):
+			return nil, nil, newLineError(line, "label name %q is reserved", name)
+		}
+	}
+
+	if addrCol < 0 {
+		return nil, nil, newLineError(line, "header must have a target column")
+	}
+
+	tgs := make(TargetGroups, 0)
+	lines := make([]int, 0)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, csvError(err)
+		}
+
+		line, _ := r.FieldPos(0)
+		tg := &TargetGroup{source: file, index: len(tgs)}
+		labels := make(map[string]string)
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch i {
+			case addrCol:
+				if value == "" {
+					return nil, nil, newLineError(line, "target is missing an address")
+				}
+
+				tg.Targets = []Target{{Address: value}}
+			case jobsCol:
+				for _, job := range strings.Split(value, csvJobsSeparator) {
+					if job = strings.TrimSpace(job); job != "" {
+						tg.Jobs = append(tg.Jobs, job)
+					}
+				}
+			default:
+				if value != "" {
+					labels[header[i]] = value
+				}
+			}
+		}
+
+		if len(labels) > 0 {
+			tg.Labels = labels
+		}
+
+		tgs = append(tgs, tg)
+		lines = append(lines, line)
+	}
+
+	return tgs, lines, nil
+}
+
+// csvError returns a csv.ParseError as a lineError.
+func csvError(err error) error {
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return newLineError(perr.Line, "%s", perr.Err)
+	}
+
+	return err
+}
+
+// validateCSV lints a CSV source. Each row is checked like a target group.
+func validateCSV(config *core.Config, file string, data []byte) Problems {
+	tgs, lines, err := parseCSV(file, data)
+	if err != nil {
+		return Problems{lineProblem(file, err)}
+	}
+
+	if len(tgs) == 0 {
+		return Problems{{File: file, Message: "no rows found in csv source file"}}
+	}
+
+	problems := make(Problems, 0)
+	for i, tg := range tgs {
+		for _, p := range tg.Validate(config) {
+			p.File, p.Line = file, lines[i]
+			problems = append(problems, p)
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,118 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+const testCSVSource = `# Exported from the CMDB.
+target,jobs,datacenter,rack
+atlwebapp01,node;blackbox_icmp,atl,r01
+"atlwebapp02", node ,atl,
+jfkdb01,,jfk,r12
+`
+
+func TestParseCSV(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		got, lines, err := parseCSV("hosts_targets.csv", []byte(testCSVSource))
+		require.NoError(err, "parseCSV returned an unexpected error")
+
+		want := TargetGroups{
+			&TargetGroup{
+				Jobs:    []string{"node", "blackbox_icmp"},
+				Labels:  map[string]string{"datacenter": "atl", "rack": "r01"},
+				Targets: []Target{{Address: "atlwebapp01"}},
+				source:  "hosts_targets.csv",
+			},
+			&TargetGroup{
+				Jobs:    []string{"node"},
+				Labels:  map[string]string{"datacenter": "atl"},
+				Targets: []Target{{Address: "atlwebapp02"}},
+				source:  "hosts_targets.csv",
+				index:   1,
+			},
+			&TargetGroup{
+				Labels:  map[string]string{"datacenter": "jfk", "rack": "r12"},
+				Targets: []Target{{Address: "jfkdb01"}},
+				source:  "hosts_targets.csv",
+				index:   2,
+			},
+		}
+		require.Equal(want, got, "groups did not match")
+		require.Equal([]int{3, 4, 5}, lines, "lines did not match")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		got, _, err := parseCSV("hosts_targets.csv", []byte(""))
+		require.NoError(err, "parseCSV returned an unexpected error")
+		require.Empty(got, "groups were not empty")
+	})
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"NoTarget", "jobs,rack\nnode,r01\n", "line 1: header must have a target column"},
+		{"TwoTargets", "target,address\nhost01,host01\n", "line 1: more than one target column"},
+		{"Duplicate", "target,rack,rack\nhost01,r01,r02\n", `line 1: duplicate column "rack"`},
+		{"LabelName", "target,bad-name\nhost01,x\n", `line 1: invalid label name "bad-name"`},
+		{"Reserved", "target,job\nhost01,node\n", `line 1: label name "job" is reserved`},
+		{"MissingAddress", "target,rack\nhost01,r01\n,r12\n", "line 3: target is missing an address"},
+		{"FieldCount", "target,rack\nhost01,r01,extra\n", "line 2: wrong number of fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseCSV("hosts_targets.csv", []byte(tt.data))
+			require.EqualError(err, tt.want, "error did not match")
+		})
+	}
+}
+
+func TestCSVSources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "csv_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+	file := filepath.Join(tempDir, "hosts_targets.csv")
+	err = os.WriteFile(file, []byte(testCSVSource), 0o644)
+	require.NoError(err, "failed to write %s", file)
+
+	t.Run("NewTargetGroups", func(t *testing.T) {
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+
+		jobs := tgs.JobGroups(config)
+		require.Len(jobs["node"], 2, "wrong number of node groups")
+		require.Equal([]string{"atlwebapp01"}, jobs["blackbox_icmp"][0].Targets, "blackbox_icmp targets did not match")
+		require.NotContains(jobs, "", "row without jobs was exported")
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		require.False(IsWritable(config, file), "csv source was writable")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		got, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Equal(Problems{{File: file, Line: 5, Message: "has no jobs"}}, got, "problems did not match")
+
+		err = os.WriteFile(file, []byte("target,bad-name\nhost01,x\n"), 0o644)
+		require.NoError(err, "failed to write %s", file)
+
+		got, err = ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Equal(Problems{{File: file, Line: 1, Message: `invalid label name "bad-name"`}}, got, "problems did not match")
+	})
+}
@@ -0,0 +1,172 @@
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+// TargetChange describes a single target that was added, removed or had its labels changed.
+// Added targets only have New labels and removed targets only have Old labels.
+type TargetChange struct {
+	Target string            `json:"target" yaml:"target"`
+	Old    map[string]string `json:"old,omitempty" yaml:"old,omitempty"`
+	New    map[string]string `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// FileDiff describes how an export would change a single targets file.
+type FileDiff struct {
+	File    string         `json:"file" yaml:"file"`
+	Status  FileStatus     `json:"status" yaml:"status"`
+	Added   []TargetChange `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []TargetChange `json:"removed,omitempty" yaml:"removed,omitempty"`
+	Changed []TargetChange `json:"changed,omitempty" yaml:"changed,omitempty"`
+}
+
+// Diffs lists the FileDiff for each targets file, sorted by file name.
+type Diffs []FileDiff
+
+// Changed returns true if an export would change any targets file.
+func (d Diffs) Changed() bool {
+	for _, fd := range d {
+		if fd.Status != FileUnchanged {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff compares the targets files an export would write with the files currently in the targets
+// dir without writing anything.
+func (t TargetGroups) Diff(config *core.Config) (Diffs, error) {
+	files, err := t.splitByJob(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := config.TargetsDir
+
+	old, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	current := newManifest(files)
+	diffs := make(Diffs, 0, len(current.Files))
+	for _, filename := range current.Files {
+		data, err := renderTargets(config, files[filename])
+		if err != nil {
+			return nil, err
+		}
+
+		f := filepath.Join(dir, filename)
+		status, err := fileStatus(f, data)
+		if err != nil {
+			return nil, err
+		}
+
+		existing := ExportGroups{}
+		if status != FileCreated {
+			existing, err = readExportGroups(f)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		diffs = append(diffs, newFileDiff(filename, status, existing, files[filename]))
+	}
+
+	for _, filename := range old.stale(current) {
+		if !isManagedName(filename) {
+			continue
+		}
+
+		existing, err := readExportGroups(filepath.Join(dir, filename))
+		if err != nil {
+			// The file is already gone so there is nothing to remove.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		diffs = append(diffs, newFileDiff(filename, FileRemoved, existing, nil))
+	}
+
+	slices.SortFunc(diffs, func(a, b FileDiff) int { return strings.Compare(a.File, b.File) })
+	return diffs, nil
+}
+
+// readExportGroups reads a targets file. The format is determined by the file extension so
+// files written with a different targets_file_ext can still be read.
+func readExportGroups(file string) (ExportGroups, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	egs := make(ExportGroups, 0)
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt:
+		err = json.Unmarshal(data, &egs)
+	case core.DefaultYAMLFileExt, ".yaml":
+		err = yaml.Unmarshal(data, &egs)
+	default:
+		return nil, fmt.Errorf("%w: unknown file extension for file: %s", os.ErrInvalid, file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return egs, nil
+}
+
+// newFileDiff compares the targets in the old and new ExportGroups.
+func newFileDiff(filename string, status FileStatus, old, new ExportGroups) FileDiff {
+	d := FileDiff{File: filename, Status: status}
+	oldTargets := targetLabels(old)
+	newTargets := targetLabels(new)
+
+	for _, addr := range sortedKeys(newTargets) {
+		oldLabels, ok := oldTargets[addr]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, TargetChange{Target: addr, New: newTargets[addr]})
+		case !maps.Equal(oldLabels, newTargets[addr]):
+			d.Changed = append(d.Changed, TargetChange{Target: addr, Old: oldLabels, New: newTargets[addr]})
+		}
+	}
+
+	for _, addr := range sortedKeys(oldTargets) {
+		if _, ok := newTargets[addr]; !ok {
+			d.Removed = append(d.Removed, TargetChange{Target: addr, Old: oldTargets[addr]})
+		}
+	}
+
+	return d
+}
+
+// targetLabels maps each target address to the labels of the first group it appears in.
+func targetLabels(egs ExportGroups) map[string]map[string]string {
+	targets := make(map[string]map[string]string)
+	for _, eg := range egs {
+		for _, addr := range eg.Targets {
+			if _, ok := targets[addr]; !ok {
+				targets[addr] = eg.Labels
+			}
+		}
+	}
+
+	return targets
+}
@@ -0,0 +1,139 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "diff_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.TargetsDir = tempDir
+
+	tgs := TargetGroups{
+		&TargetGroup{
+			Jobs:    []string{"node", "mysql"},
+			Labels:  map[string]string{"environment": "prod"},
+			Targets: []Target{{Address: "host01"}, {Address: "host02"}},
+		},
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		diffs, err := tgs.Diff(config)
+		require.NoError(err, "Diff returned an unexpected error")
+		require.True(diffs.Changed(), "diff did not report changes")
+		require.Equal(Diffs{
+			{
+				File:   "mysql_targets.json",
+				Status: FileCreated,
+				Added: []TargetChange{
+					{Target: "host01", New: map[string]string{"environment": "prod", "job": "mysql"}},
+					{Target: "host02", New: map[string]string{"environment": "prod", "job": "mysql"}},
+				},
+			},
+			{
+				File:   "node_targets.json",
+				Status: FileCreated,
+				Added: []TargetChange{
+					{Target: "host01", New: map[string]string{"environment": "prod", "job": "node"}},
+					{Target: "host02", New: map[string]string{"environment": "prod", "job": "node"}},
+				},
+			},
+		}, diffs, "diffs did not match")
+
+		entries, err := os.ReadDir(tempDir)
+		require.NoError(err, "failed to read targets dir")
+		require.Empty(entries, "Diff wrote to the targets dir")
+	})
+
+	_, err = tgs.ExportTargets(config)
+	require.NoError(err, "failed to export targets")
+
+	t.Run("Unchanged", func(t *testing.T) {
+		diffs, err := tgs.Diff(config)
+		require.NoError(err, "Diff returned an unexpected error")
+		require.False(diffs.Changed(), "diff reported changes")
+		require.Len(diffs, 2, "wrong number of diffs")
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		changed := TargetGroups{
+			&TargetGroup{
+				Jobs:   []string{"node"},
+				Labels: map[string]string{"environment": "prod"},
+				Targets: []Target{
+					{Address: "host02", Labels: map[string]string{"rack": "r12"}},
+					{Address: "host03"},
+				},
+			},
+		}
+
+		diffs, err := changed.Diff(config)
+		require.NoError(err, "Diff returned an unexpected error")
+		require.True(diffs.Changed(), "diff did not report changes")
+		require.Equal(Diffs{
+			{
+				File:   "mysql_targets.json",
+				Status: FileRemoved,
+				Removed: []TargetChange{
+					{Target: "host01", Old: map[string]string{"environment": "prod", "job": "mysql"}},
+					{Target: "host02", Old: map[string]string{"environment": "prod", "job": "mysql"}},
+				},
+			},
+			{
+				File:    "node_targets.json",
+				Status:  FileUpdated,
+				Added:   []TargetChange{{Target: "host03", New: map[string]string{"environment": "prod", "job": "node"}}},
+				Removed: []TargetChange{{Target: "host01", Old: map[string]string{"environment": "prod", "job": "node"}}},
+				Changed: []TargetChange{
+					{
+						Target: "host02",
+						Old:    map[string]string{"environment": "prod", "job": "node"},
+						New:    map[string]string{"environment": "prod", "job": "node", "rack": "r12"},
+					},
+				},
+			},
+		}, diffs, "diffs did not match")
+	})
+}
+
+func TestReadExportGroups(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "diff_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	want := ExportGroups{&ExportGroup{Labels: map[string]string{"job": "node"}, Targets: []string{"host01"}}}
+	files := map[string]string{
+		"node_targets.json": `[{"labels": {"job": "node"}, "targets": ["host01"]}]`,
+		"node_targets.yml":  "- labels:\n    job: node\n  targets:\n    - host01\n",
+		"node_targets.txt":  "host01",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(tempDir, name)
+			err := os.WriteFile(f, []byte(content), 0o644)
+			require.NoError(err, "failed to write %s", name)
+
+			got, err := readExportGroups(f)
+			if filepath.Ext(name) == ".txt" {
+				require.ErrorIs(err, os.ErrInvalid, "readExportGroups did not return the correct error")
+				return
+			}
+
+			require.NoError(err, "readExportGroups returned an unexpected error")
+			require.Equal(want, got, "export groups did not match")
+		})
+	}
+}
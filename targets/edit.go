@@ -0,0 +1,857 @@
+package targets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSourceFile is used for new groups when the sources dir has no source files yet.
+const DefaultSourceFile = "targets.yml"
+
+// TargetEdit is a target to add to or remove from the source files.
+type TargetEdit struct {
+	Address string
+	// Jobs and Labels pick the group a target is added to. Without Group the target is added to
+	// the first group with exactly these jobs and labels, or to a new group if there is none.
+	Jobs   []string
+	Labels map[string]string
+	// Group is the name of the group to add the target to or remove it from. Labels the group
+	// does not already have are added to the target only.
+	Group string
+	// File is the name of the source file to edit. Default every writable source file, and the
+	// first one for new groups.
+	File string
+}
+
+// EditResult is a group changed by AddTarget or RemoveTarget.
+type EditResult struct {
+	File string
+	// Group is the position of the group in the file before the edit.
+	Group int
+	// Created is true if a new group was added for the target. Removed is true if the group was
+	// removed because it had no targets left.
+	Created bool
+	Removed bool
+}
+
+// sourceEdit holds the yaml nodes of a source file while it is edited so comments and the order
+// of keys are kept. The groups and targets read from the file are kept so save only rewrites
+// what changed. Groups changed in other ways than adding or removing targets must be marked in
+// changed.
+type sourceEdit struct {
+	config  *core.Config
+	file    string
+	data    []byte
+	doc     *yaml.Node
+	groups  *yaml.Node
+	orig    []*yaml.Node
+	targets map[*yaml.Node]origTargets
+	changed map[*yaml.Node]bool
+}
+
+// origTargets is the targets list of a group as it was read.
+type origTargets struct {
+	node  *yaml.Node
+	items []*yaml.Node
+}
+
+// NewGroupFile returns the path of the source file a new group should be added to. files are the
+// current source files and name is the requested file name, which may be empty.
+func NewGroupFile(config *core.Config, files []string, name string) (string, error) {
+	// Remote sources are only read. Use the cached copy so writing it reports it as read only.
+	if IsRemote(config.Sources) && len(files) > 0 {
+		return files[0], nil
+	}
+
+	// pim can not tell which source a new file belongs to, so with a sources list groups can
+	// only be added to existing source files.
+	if len(config.SourceList) > 0 {
+		for _, f := range files {
+			if (name == "" || name == filepath.Base(f)) && IsWritable(config, f) {
+				return f, nil
+			}
+		}
+
+		if name == "" {
+			return "", fmt.Errorf("%w: file: no writable source file found", os.ErrInvalid)
+		}
+
+		return "", fmt.Errorf("%w: file: %s is not a writable source file", os.ErrInvalid, name)
+	}
+
+	info, err := os.Stat(config.Sources)
+	if err == nil && !info.IsDir() {
+		if name != "" && name != filepath.Base(config.Sources) {
+			return "", fmt.Errorf("%w: file: sources is a single file: %s", os.ErrInvalid, config.Sources)
+		}
+
+		return config.Sources, nil
+	}
+
+	if name == "" {
+		for _, f := range files {
+			if IsWritable(config, f) {
+				return f, nil
+			}
+		}
+
+		name = DefaultSourceFile
+	}
+
+	if !IsSourceFileName(name) {
+		return "", fmt.Errorf(
+			"%w: file: %s; must be named targets.{yml,yaml,json} or *_targets.{yml,yaml,json}",
+			os.ErrInvalid,
+			name,
+		)
+	}
+
+	file := filepath.Join(config.Sources, name)
+	if slices.Contains(files, file) || len(files) == 0 {
+		return file, nil
+	}
+
+	// A targets.{yml,yaml,json} file hides every other source file in the dir, so new files can
+	// only be added alongside other *_targets files.
+	if isExactName(name) || isExactName(filepath.Base(files[0])) {
+		return "", fmt.Errorf(
+			"%w: file: %s would not be read with the existing source files",
+			os.ErrInvalid,
+			name,
+		)
+	}
+
+	return file, nil
+}
+
+func isExactName(name string) bool {
+	return !strings.Contains(name, "_")
+}
+
+// AddTarget adds the target in edit to a source file. YAML files keep their comments and key
+// order. The file is only written if it is still valid afterwards.
+func AddTarget(config *core.Config, edit TargetEdit) (*EditResult, error) {
+	if edit.Address == "" {
+		return nil, fmt.Errorf("%w: target address is required", os.ErrInvalid)
+	}
+
+	files, err := editFiles(config, edit.File)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for a group to add the target to before adding a new one.
+	for _, f := range files {
+		se, err := loadSourceEdit(config, f)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, node := range se.groups.Content {
+			var tg TargetGroup
+			if err := node.Decode(&tg); err != nil {
+				return nil, fmt.Errorf("%s: group %d: %w", f, i, err)
+			}
+
+			if !edit.matches(&tg) {
+				continue
+			}
+
+			if edit.Group != "" && len(edit.Jobs) > 0 && !sameJobs(tg.Jobs, edit.Jobs) {
+				return nil, fmt.Errorf(
+					"%w: group %s has jobs %s",
+					os.ErrInvalid,
+					edit.Group,
+					strings.Join(tg.Jobs, ","),
+				)
+			}
+
+			if slices.ContainsFunc(tg.Targets, func(t Target) bool { return t.Address == edit.Address }) {
+				return nil, fmt.Errorf("%w: target %s is already in group %d in %s", os.ErrExist, edit.Address, i, f)
+			}
+
+			if err := addTarget(node, edit.Address, edit.targetLabels(tg.Labels)); err != nil {
+				return nil, fmt.Errorf("%s: group %d: %w", f, i, err)
+			}
+
+			if err := se.save(); err != nil {
+				return nil, err
+			}
+
+			return &EditResult{File: f, Group: i}, nil
+		}
+	}
+
+	if len(edit.Jobs) == 0 {
+		return nil, fmt.Errorf("%w: jobs are required to add a new group", os.ErrInvalid)
+	}
+
+	all, err := SourceFiles(config)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := NewGroupFile(config, all, edit.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsWritable(config, file) {
+		return nil, fmt.Errorf("%w: %s is read only and can not be changed", os.ErrInvalid, file)
+	}
+
+	se, err := loadSourceEdit(config, file)
+	if err != nil {
+		return nil, err
+	}
+
+	tg := &TargetGroup{
+		Name:    edit.Group,
+		Jobs:    edit.Jobs,
+		Labels:  edit.Labels,
+		Targets: []Target{{Address: edit.Address}},
+	}
+
+	var node yaml.Node
+	if err := node.Encode(tg); err != nil {
+		return nil, err
+	}
+
+	se.groups.Content = append(se.groups.Content, &node)
+	if err := se.save(); err != nil {
+		return nil, err
+	}
+
+	return &EditResult{File: file, Group: len(se.groups.Content) - 1, Created: true}, nil
+}
+
+// RemoveTarget removes the target in edit from every group it is listed in, or only from the
+// group named edit.Group. Groups with no targets left are removed. YAML files keep their comments
+// and key order. Files are only written if they are still valid afterwards.
+func RemoveTarget(config *core.Config, edit TargetEdit) ([]*EditResult, error) {
+	if edit.Address == "" {
+		return nil, fmt.Errorf("%w: target address is required", os.ErrInvalid)
+	}
+
+	files, err := editFiles(config, edit.File)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*EditResult, 0)
+	for _, f := range files {
+		se, err := loadSourceEdit(config, f)
+		if err != nil {
+			return results, err
+		}
+
+		changed := make([]*EditResult, 0)
+		for i, node := range se.groups.Content {
+			var tg TargetGroup
+			if err := node.Decode(&tg); err != nil {
+				return results, fmt.Errorf("%s: group %d: %w", f, i, err)
+			}
+
+			if edit.Group != "" && tg.Name != edit.Group {
+				continue
+			}
+
+			if removed, empty := removeTarget(node, edit.Address); removed {
+				changed = append(changed, &EditResult{File: f, Group: i, Removed: empty})
+			}
+		}
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		// Remove the empty groups last so the positions in changed stay correct.
+		for i := len(changed) - 1; i >= 0; i-- {
+			if changed[i].Removed {
+				g := changed[i].Group
+				se.groups.Content = slices.Delete(se.groups.Content, g, g+1)
+			}
+		}
+
+		if err := se.save(); err != nil {
+			return results, err
+		}
+
+		results = append(results, changed...)
+	}
+
+	if len(results) == 0 {
+		if edit.Group != "" {
+			return nil, fmt.Errorf("%w: target %s is not in group %s", os.ErrNotExist, edit.Address, edit.Group)
+		}
+
+		return nil, fmt.Errorf("%w: target %s is not in any writable source file", os.ErrNotExist, edit.Address)
+	}
+
+	return results, nil
+}
+
+// UpdateSourceFile writes tgs to file in place of the groups read from it. from[i] is the position
+// in the file tgs[i] was read from, or -1 for a new group; groups of the file missing from from
+// are removed. Groups are changed the same way as AddTarget and RemoveTarget change them, so YAML
+// comments, unknown keys and the lines of unchanged groups are kept. The file is only written if
+// it is still valid afterwards.
+func UpdateSourceFile(config *core.Config, file string, tgs TargetGroups, from []int) error {
+	if len(from) != len(tgs) {
+		return fmt.Errorf("%w: %d groups but %d positions", os.ErrInvalid, len(tgs), len(from))
+	}
+
+	se, err := loadSourceEdit(config, file)
+	if err != nil {
+		return err
+	}
+
+	content := make([]*yaml.Node, 0, len(tgs))
+	for i, tg := range tgs {
+		if from[i] < 0 || from[i] >= len(se.orig) {
+			var node yaml.Node
+			if err := node.Encode(tg); err != nil {
+				return err
+			}
+
+			content = append(content, &node)
+			continue
+		}
+
+		node := se.orig[from[i]]
+		if err := se.setGroup(node, tg); err != nil {
+			return fmt.Errorf("%s: group %d: %w", file, from[i], err)
+		}
+
+		content = append(content, node)
+	}
+
+	se.groups.Content = content
+	return se.save()
+}
+
+// setGroup changes the group node to hold tg. Keys that did not change are left alone so their
+// comments and styles are kept, and keys pim does not know are kept for validate to report.
+// Targets still in tg keep their nodes.
+func (se *sourceEdit) setGroup(node *yaml.Node, tg *TargetGroup) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: target group must be a map", os.ErrInvalid)
+	}
+
+	var old TargetGroup
+	if err := node.Decode(&old); err != nil {
+		return err
+	}
+
+	fields := []struct {
+		key   string
+		same  bool
+		empty bool
+		value any
+	}{
+		{"name", old.Name == tg.Name, tg.Name == "", tg.Name},
+		{"priority", old.Priority == tg.Priority, tg.Priority == 0, tg.Priority},
+		{"jobs", slices.Equal(old.Jobs, tg.Jobs), len(tg.Jobs) == 0, tg.Jobs},
+		{"labels", maps.Equal(old.Labels, tg.Labels), len(tg.Labels) == 0, tg.Labels},
+	}
+	for _, f := range fields {
+		if f.same {
+			continue
+		}
+
+		se.changed[node] = true
+		if f.empty {
+			deleteMappingKey(node, f.key)
+			continue
+		}
+
+		var value yaml.Node
+		if err := value.Encode(f.value); err != nil {
+			return err
+		}
+
+		setMappingValue(node, f.key, &value)
+	}
+
+	if slices.EqualFunc(old.Targets, tg.Targets, Target.equal) {
+		return nil
+	}
+
+	list := mappingValue(node, "targets")
+	if list == nil || list.Kind != yaml.SequenceNode {
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		setMappingValue(node, "targets", list)
+		se.changed[node] = true
+	}
+
+	// Reuse the node of every target that is kept so its comments stay with it.
+	old.Targets = slices.Clone(old.Targets)
+	items := make([]*yaml.Node, 0, len(tg.Targets))
+	for _, t := range tg.Targets {
+		if i := slices.IndexFunc(old.Targets, t.equal); i >= 0 && i < len(list.Content) {
+			items = append(items, list.Content[i])
+			old.Targets[i] = Target{}
+			continue
+		}
+
+		var item yaml.Node
+		if err := item.Encode(t); err != nil {
+			return err
+		}
+
+		items = append(items, &item)
+	}
+
+	list.Content = items
+	return nil
+}
+
+// setMappingValue sets key in the mapping node to value. The key node is kept if key is already
+// set so its comments stay.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// deleteMappingKey removes key and its value from the mapping node.
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return
+		}
+	}
+}
+
+// editFiles returns the writable source files, or only the one called name if it is set.
+func editFiles(config *core.Config, name string) ([]string, error) {
+	files, err := SourceFiles(config)
+	if err != nil {
+		return nil, err
+	}
+
+	writable := make([]string, 0, len(files))
+	for _, f := range files {
+		if IsWritable(config, f) && (name == "" || name == filepath.Base(f)) {
+			writable = append(writable, f)
+		}
+	}
+
+	return writable, nil
+}
+
+// matches returns true if the target should be added to tg.
+func (e TargetEdit) matches(tg *TargetGroup) bool {
+	if e.Group != "" {
+		return tg.Name == e.Group
+	}
+
+	return sameJobs(tg.Jobs, e.Jobs) && maps.Equal(tg.Labels, e.Labels)
+}
+
+// targetLabels returns the labels of the edit that group does not already have.
+func (e TargetEdit) targetLabels(group map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for k, v := range e.Labels {
+		if gv, ok := group[k]; !ok || gv != v {
+			labels[k] = v
+		}
+	}
+
+	return labels
+}
+
+// sameJobs returns true if a and b have the same jobs in any order.
+func sameJobs(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// loadSourceEdit reads file into yaml nodes. A missing or empty file has no groups. JSON files
+// are read with the YAML parser since JSON is valid YAML.
+func loadSourceEdit(config *core.Config, file string) (*sourceEdit, error) {
+	se := &sourceEdit{
+		config:  config,
+		file:    file,
+		doc:     &yaml.Node{Kind: yaml.DocumentNode},
+		groups:  &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"},
+		targets: make(map[*yaml.Node]origTargets),
+		changed: make(map[*yaml.Node]bool),
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	se.data = data
+	if err := yaml.Unmarshal(data, se.doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if len(se.doc.Content) == 0 {
+		se.doc.Kind = yaml.DocumentNode
+		se.doc.Content = []*yaml.Node{se.groups}
+		return se, nil
+	}
+
+	se.groups = se.doc.Content[0]
+	if se.groups.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%w: %s: sources must be a list of target groups", os.ErrInvalid, file)
+	}
+
+	se.orig = slices.Clone(se.groups.Content)
+	for _, g := range se.orig {
+		if t := mappingValue(g, "targets"); t != nil {
+			se.targets[g] = origTargets{node: t, items: slices.Clone(t.Content)}
+		}
+	}
+
+	return se, nil
+}
+
+// addTarget appends addr to the targets of group. The target is written as an object if it has
+// labels of its own.
+func addTarget(group *yaml.Node, addr string, labels map[string]string) error {
+	var node yaml.Node
+	if err := node.Encode(Target{Address: addr, Labels: labels}); err != nil {
+		return err
+	}
+
+	targets := mappingValue(group, "targets")
+	if targets == nil {
+		targets = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		group.Content = append(group.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "targets"}, targets)
+	}
+
+	if targets.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%w: targets must be a list", os.ErrInvalid)
+	}
+
+	targets.Content = append(targets.Content, &node)
+	return nil
+}
+
+// removeTarget removes every target with addr from group. removed is true if a target was
+// removed and empty if the group has no targets left.
+func removeTarget(group *yaml.Node, addr string) (removed, empty bool) {
+	targets := mappingValue(group, "targets")
+	if targets == nil || targets.Kind != yaml.SequenceNode {
+		return false, false
+	}
+
+	kept := make([]*yaml.Node, 0, len(targets.Content))
+	for _, n := range targets.Content {
+		var t Target
+		if err := n.Decode(&t); err == nil && t.Address == addr {
+			removed = true
+			continue
+		}
+
+		kept = append(kept, n)
+	}
+
+	targets.Content = kept
+	return removed, len(kept) == 0
+}
+
+// mappingValue returns the value of key in the mapping node, or nil if it is not set.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// save validates the edited file and writes it. A file left with no groups by RemoveTarget is
+// written as an empty list since there is nothing left to validate.
+func (se *sourceEdit) save() error {
+	data, err := se.encode()
+	if err != nil {
+		return err
+	}
+
+	if len(se.groups.Content) == 0 {
+		return core.WriteFileAtomic(se.file, data, 0o644)
+	}
+
+	problems := validateSource(fileConfig(se.config, se.file), se.file, data)
+	if problems.Errors() > 0 {
+		msgs := make([]string, 0, len(problems))
+		for _, p := range problems {
+			if !p.Warning {
+				msgs = append(msgs, p.String())
+			}
+		}
+
+		return fmt.Errorf(
+			"%w: not writing %s; the result would not be valid: %s",
+			os.ErrInvalid,
+			se.file,
+			strings.Join(msgs, "; "),
+		)
+	}
+
+	return core.WriteFileAtomic(se.file, data, 0o644)
+}
+
+// encode returns the edited file. In YAML files with a block list of groups, targets added to or
+// removed from a block list of targets are spliced into the original lines, and only groups that
+// can not be edited that way are written again. Every other line is kept byte for byte. JSON
+// files are encoded from the nodes.
+func (se *sourceEdit) encode() ([]byte, error) {
+	ext := filepath.Ext(se.file)
+	if ext == core.DefaultJSONFileExt || len(se.orig) == 0 || se.groups.Style&yaml.FlowStyle != 0 {
+		return encodeSource(se.file, se.doc)
+	}
+
+	lines := strings.SplitAfter(string(se.data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// Find the lines of every group: its head comments, the line with its dash and its last line.
+	type span struct{ head, dash, end int }
+	spans := make([]span, len(se.orig))
+	for i, g := range se.orig {
+		spans[i] = span{dash: dashLine(lines, g)}
+	}
+
+	col := strings.Index(lines[spans[0].dash], "-")
+	for i := range spans {
+		low := 0
+		if i > 0 {
+			low = spans[i-1].dash + 1
+		}
+
+		spans[i].head = headLine(lines, spans[i].dash, low, col)
+	}
+
+	for i := range spans {
+		end := len(lines) - 1
+		if i+1 < len(spans) {
+			end = spans[i+1].head - 1
+		}
+
+		// Blank lines and comments at the level of the groups belong between the groups.
+		for end > spans[i].dash && (strings.TrimSpace(lines[end]) == "" || isComment(lines[end], col)) {
+			end--
+		}
+
+		spans[i].end = end
+	}
+
+	prefix := strings.Repeat(" ", col)
+	skip := make(map[int]bool)
+	insert := make(map[int]string)
+	for i, g := range se.orig {
+		sp := spans[i]
+		if !slices.Contains(se.groups.Content, g) {
+			for l := sp.head; l <= sp.end; l++ {
+				skip[l] = true
+			}
+
+			continue
+		}
+
+		if !se.changed[g] && se.spliceTargets(lines, g, skip, insert) {
+			continue
+		}
+
+		out, err := encodeItem(g, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for l := sp.dash; l <= sp.end; l++ {
+			skip[l] = true
+		}
+
+		insert[sp.dash-1] += out
+	}
+
+	var b strings.Builder
+	b.WriteString(insert[-1])
+	for l, line := range lines {
+		if !skip[l] {
+			b.WriteString(line)
+		}
+
+		b.WriteString(insert[l])
+	}
+
+	for _, g := range se.groups.Content {
+		if slices.Contains(se.orig, g) {
+			continue
+		}
+
+		out, err := encodeItem(g, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(out)
+	}
+
+	if len(se.groups.Content) == 0 {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+
+		b.WriteString("[]\n")
+	}
+
+	return []byte(b.String()), nil
+}
+
+// spliceTargets records the lines to skip and insert for the targets added to and removed from
+// group. It returns false if the group has to be written again instead: its targets are not a
+// block list with a target from the file left, or new targets are not only after them.
+func (se *sourceEdit) spliceTargets(lines []string, group *yaml.Node, skip map[int]bool, insert map[int]string) bool {
+	orig, ok := se.targets[group]
+	cur := mappingValue(group, "targets")
+	if !ok || cur != orig.node {
+		return !ok && cur == nil
+	}
+
+	if slices.Equal(orig.items, cur.Content) {
+		return true
+	}
+
+	if cur.Style&yaml.FlowStyle != 0 {
+		return false
+	}
+
+	// New targets go after the last target kept from the file.
+	kept := 0
+	for kept < len(cur.Content) && slices.Contains(orig.items, cur.Content[kept]) {
+		kept++
+	}
+
+	isOrig := func(n *yaml.Node) bool { return slices.Contains(orig.items, n) }
+	if kept == 0 || slices.ContainsFunc(cur.Content[kept:], isOrig) {
+		return false
+	}
+
+	for _, n := range orig.items {
+		if slices.Contains(cur.Content, n) {
+			continue
+		}
+
+		dash := dashLine(lines, n)
+		col := strings.Index(lines[dash], "-")
+		for l := headLine(lines, dash, 0, col); l < lastLine(n); l++ {
+			skip[l] = true
+		}
+	}
+
+	last := cur.Content[kept-1]
+	prefix := strings.Repeat(" ", strings.Index(lines[dashLine(lines, last)], "-"))
+	for _, n := range cur.Content[kept:] {
+		out, err := encodeItem(n, prefix)
+		if err != nil {
+			return false
+		}
+
+		insert[lastLine(last)-1] += out
+	}
+
+	return true
+}
+
+// dashLine returns the index of the line with the dash of the list item node.
+func dashLine(lines []string, node *yaml.Node) int {
+	l := min(node.Line, len(lines)) - 1
+	for l > 0 && !strings.HasPrefix(strings.TrimSpace(lines[l]), "-") {
+		l--
+	}
+
+	return l
+}
+
+// headLine returns the index of the first of the comment lines in column col directly above the
+// list item with its dash on line dash. Lines before low are not checked.
+func headLine(lines []string, dash, low, col int) int {
+	for dash > low && isComment(lines[dash-1], col) && strings.Index(lines[dash-1], "#") == col {
+		dash--
+	}
+
+	return dash
+}
+
+// lastLine returns the number of the last line of node and its children.
+func lastLine(node *yaml.Node) int {
+	last := node.Line
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		last += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+	}
+
+	for _, c := range node.Content {
+		last = max(last, lastLine(c))
+	}
+
+	return last
+}
+
+// isComment returns true if line is a comment that starts at or before col.
+func isComment(line string, col int) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#") && strings.Index(line, "#") <= col
+}
+
+// encodeItem returns node as a YAML list item with every line starting with prefix so it lines up
+// with the items around it. The head comment is left out since the lines above the item are kept
+// as they are.
+func encodeItem(node *yaml.Node, prefix string) (string, error) {
+	n := *node
+	n.HeadComment = ""
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{&n}}); err != nil {
+		return "", err
+	}
+
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(b.String(), "\n") {
+		if line != "" && line != "\n" {
+			out.WriteString(prefix)
+		}
+
+		out.WriteString(line)
+	}
+
+	return out.String(), nil
+}
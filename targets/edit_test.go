@@ -0,0 +1,403 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+const editTestSource = `# web servers
+- name: web # managed by hand
+  jobs: [node]
+  labels:
+    rack: r01 # top of rack
+  targets:
+    - host01
+    # spare
+    - host02
+- jobs:
+    - node
+    - blackbox_icmp
+  targets:
+    - db01
+`
+
+func newEditTest(t *testing.T) (*core.Config, string, func()) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "edit_test")
+	require.NoError(err, "failed to create temp dir")
+
+	writeTestFiles(t, tempDir, map[string]string{"targets.yml": editTestSource})
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+	return config, filepath.Join(tempDir, "targets.yml"), func() { os.RemoveAll(tempDir) }
+}
+
+func readTestFile(t *testing.T, file string) string {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err, "failed to read %s", file)
+	return string(data)
+}
+
+func TestEditAddTarget(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("ExistingGroup", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		r, err := AddTarget(config, TargetEdit{
+			Address: "db02",
+			Jobs:    []string{"blackbox_icmp", "node"},
+		})
+		require.NoError(err, "AddTarget returned an unexpected error")
+		require.Equal(&EditResult{File: file, Group: 1}, r, "result did not match")
+		require.Equal(editTestSource+"    - db02\n", readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("NamedGroup", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		_, err := AddTarget(config, TargetEdit{
+			Address: "host03",
+			Labels:  map[string]string{"rack": "r01", "spare": "true"},
+			Group:   "web",
+		})
+		require.NoError(err, "AddTarget returned an unexpected error")
+		require.Contains(
+			readTestFile(t, file),
+			"    # spare\n    - host02\n    - address: host03\n      labels:\n        spare: \"true\"\n- jobs:",
+			"target labels did not match",
+		)
+	})
+
+	t.Run("NewGroup", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		r, err := AddTarget(config, TargetEdit{
+			Address: "mysql01",
+			Jobs:    []string{"mysql"},
+			Labels:  map[string]string{"rack": "r12"},
+		})
+		require.NoError(err, "AddTarget returned an unexpected error")
+		require.Equal(&EditResult{File: file, Group: 2, Created: true}, r, "result did not match")
+
+		want := editTestSource + "- jobs:\n    - mysql\n  labels:\n    rack: r12\n  targets:\n    - mysql01\n"
+		require.Equal(want, readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("NewFile", func(t *testing.T) {
+		tempDir, err := os.MkdirTemp("", "edit_test")
+		require.NoError(err, "failed to create temp dir")
+		defer os.RemoveAll(tempDir)
+
+		config := core.DefaultConfig()
+		config.Sources = tempDir
+		r, err := AddTarget(config, TargetEdit{Address: "host01", Jobs: []string{"node"}})
+		require.NoError(err, "AddTarget returned an unexpected error")
+		require.Equal(filepath.Join(tempDir, DefaultSourceFile), r.File, "file did not match")
+		require.Equal("- jobs:\n    - node\n  targets:\n    - host01\n", readTestFile(t, r.File), "file did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		tempDir, err := os.MkdirTemp("", "edit_test")
+		require.NoError(err, "failed to create temp dir")
+		defer os.RemoveAll(tempDir)
+
+		writeTestFiles(t, tempDir, map[string]string{"targets.json": `[{"jobs": ["node"], "targets": ["host01"]}]`})
+		config := core.DefaultConfig()
+		config.Sources = tempDir
+		_, err = AddTarget(config, TargetEdit{Address: "host02", Jobs: []string{"node"}})
+		require.NoError(err, "AddTarget returned an unexpected error")
+
+		tgs, err := ReadSourceFile(filepath.Join(tempDir, "targets.json"))
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+		require.Equal([]Target{{Address: "host01"}, {Address: "host02"}}, tgs[0].Targets, "targets did not match")
+	})
+
+	tests := []struct {
+		name string
+		edit TargetEdit
+		err  error
+		want string
+	}{
+		{
+			"Duplicate",
+			TargetEdit{Address: "host01", Group: "web"},
+			os.ErrExist,
+			"target host01 is already in group 0",
+		},
+		{
+			"NoJobs",
+			TargetEdit{Address: "host03", Labels: map[string]string{"rack": "r12"}},
+			os.ErrInvalid,
+			"jobs are required to add a new group",
+		},
+		{
+			"GroupJobs",
+			TargetEdit{Address: "host03", Jobs: []string{"mysql"}, Group: "web"},
+			os.ErrInvalid,
+			"group web has jobs node",
+		},
+		{
+			"Invalid",
+			TargetEdit{Address: "host03", Jobs: []string{"node"}, Labels: map[string]string{"9rack": "r12"}},
+			nil,
+			`the result would not be valid: `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, file, cleanup := newEditTest(t)
+			defer cleanup()
+
+			_, err := AddTarget(config, tt.edit)
+			require.Error(err, "AddTarget did not return an error")
+			if tt.err != nil {
+				require.ErrorIs(err, tt.err, "AddTarget did not return the correct error")
+			}
+
+			require.Contains(err.Error(), tt.want, "error did not match")
+			require.Equal(editTestSource, readTestFile(t, file), "file was changed")
+		})
+	}
+}
+
+// editTestSpaced is indented by 4 with blank lines and aligned comments, none of which yaml.v3
+// would write back.
+const editTestSpaced = `# Managed by the web team.
+
+-   name: web
+    jobs:
+        - node
+    targets:
+        - host01    # primary
+        - host02
+
+# Databases
+-   name: db
+    jobs: [node, mysql]
+    targets:
+        - db01      # primary
+
+-   name: cache
+    jobs:
+        - node
+    targets:
+        - cache01
+        - cache02
+# end
+`
+
+func TestEditKeepsUntouchedGroups(t *testing.T) {
+	require := require.New(t)
+	web := "-   name: web\n    jobs:\n        - node\n    targets:\n        - host01    # primary\n        - host02\n"
+	cache := "-   name: cache\n    jobs:\n        - node\n    targets:\n        - cache01\n        - cache02\n"
+
+	newTest := func(t *testing.T) (*core.Config, string, func()) {
+		tempDir, err := os.MkdirTemp("", "edit_test")
+		require.NoError(err, "failed to create temp dir")
+
+		writeTestFiles(t, tempDir, map[string]string{"targets.yml": editTestSpaced})
+		config := core.DefaultConfig()
+		config.Sources = tempDir
+		return config, filepath.Join(tempDir, "targets.yml"), func() { os.RemoveAll(tempDir) }
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		config, file, cleanup := newTest(t)
+		defer cleanup()
+
+		_, err := AddTarget(config, TargetEdit{Address: "db02", Group: "db"})
+		require.NoError(err, "AddTarget returned an unexpected error")
+
+		got := readTestFile(t, file)
+		require.True(strings.HasPrefix(got, "# Managed by the web team.\n\n"+web+"\n# Databases\n"), "groups before the edit did not match")
+		require.True(strings.HasSuffix(got, "\n"+cache+"# end\n"), "groups after the edit did not match")
+		want := strings.Replace(editTestSpaced, "# primary\n\n", "# primary\n        - db02\n\n", 1)
+		require.Equal(want, got, "only the new target should be added")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		config, file, cleanup := newTest(t)
+		defer cleanup()
+
+		_, err := RemoveTarget(config, TargetEdit{Address: "db01"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+		require.Equal("# Managed by the web team.\n\n"+web+"\n\n"+cache+"# end\n", readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("NewGroup", func(t *testing.T) {
+		config, file, cleanup := newTest(t)
+		defer cleanup()
+
+		_, err := AddTarget(config, TargetEdit{Address: "mysql01", Jobs: []string{"mysql"}})
+		require.NoError(err, "AddTarget returned an unexpected error")
+		require.Equal(editTestSpaced+"- jobs:\n    - mysql\n  targets:\n    - mysql01\n", readTestFile(t, file), "file did not match")
+	})
+}
+
+func TestEditRemoveTarget(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Remove", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		rs, err := RemoveTarget(config, TargetEdit{Address: "host02"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+		require.Equal([]*EditResult{{File: file, Group: 0}}, rs, "results did not match")
+
+		want := `# web servers
+- name: web # managed by hand
+  jobs: [node]
+  labels:
+    rack: r01 # top of rack
+  targets:
+    - host01
+- jobs:
+    - node
+    - blackbox_icmp
+  targets:
+    - db01
+`
+		require.Equal(want, readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("EmptyGroup", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		rs, err := RemoveTarget(config, TargetEdit{Address: "db01"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+		require.Equal([]*EditResult{{File: file, Group: 1, Removed: true}}, rs, "results did not match")
+
+		tgs, err := ReadSourceFile(file)
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+		require.Len(tgs, 1, "empty group was not removed")
+		require.Contains(readTestFile(t, file), "# managed by hand", "comments were not kept")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		config, _, cleanup := newEditTest(t)
+		defer cleanup()
+
+		_, err := RemoveTarget(config, TargetEdit{Address: "host99"})
+		require.ErrorIs(err, os.ErrNotExist, "RemoveTarget did not return an ErrNotExist")
+
+		_, err = RemoveTarget(config, TargetEdit{Address: "db01", Group: "web"})
+		require.EqualError(err, "file does not exist: target db01 is not in group web", "error did not match")
+	})
+
+	t.Run("LastGroup", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		_, err := RemoveTarget(config, TargetEdit{Address: "db01"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+		_, err = RemoveTarget(config, TargetEdit{Address: "host01"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+
+		r, err := RemoveTarget(config, TargetEdit{Address: "host02"})
+		require.NoError(err, "RemoveTarget returned an unexpected error")
+		require.Equal([]*EditResult{{File: file, Group: 0, Removed: true}}, r, "results did not match")
+		require.Equal("[]\n", readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("JSONUnknownKey", func(t *testing.T) {
+		tempDir, err := os.MkdirTemp("", "edit_test")
+		require.NoError(err, "failed to create temp dir")
+		defer os.RemoveAll(tempDir)
+
+		source := `[{"jobs":["node"],"lables":{"env":"prod"},"targets":["host01","host02"]}]`
+		writeTestFiles(t, tempDir, map[string]string{"targets.json": source})
+		config := core.DefaultConfig()
+		config.Sources = tempDir
+		file := filepath.Join(tempDir, "targets.json")
+
+		_, err = RemoveTarget(config, TargetEdit{Address: "host02"})
+		require.ErrorContains(err, `unknown key "lables"`, "RemoveTarget did not refuse the result")
+		require.Equal(source, readTestFile(t, file), "file was changed")
+	})
+}
+
+func TestEditUpdateSourceFile(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Groups", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		tgs, err := ReadSourceFile(file)
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+
+		// Drop the first group, add a target to the second and add a new group.
+		db := *tgs[1]
+		db.Targets = append(slices.Clone(db.Targets), Target{Address: "db02"})
+		mysql := &TargetGroup{Name: "mysql", Jobs: []string{"mysql"}, Targets: []Target{{Address: "mysql01"}}}
+		err = UpdateSourceFile(config, file, TargetGroups{&db, mysql}, []int{1, -1})
+		require.NoError(err, "UpdateSourceFile returned an unexpected error")
+
+		want := `- jobs:
+    - node
+    - blackbox_icmp
+  targets:
+    - db01
+    - db02
+- name: mysql
+  jobs:
+    - mysql
+  targets:
+    - mysql01
+`
+		require.Equal(want, readTestFile(t, file), "file did not match")
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		config, file, cleanup := newEditTest(t)
+		defer cleanup()
+
+		tgs, err := ReadSourceFile(file)
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+
+		web := *tgs[0]
+		web.Labels = map[string]string{"rack": "r02"}
+		err = UpdateSourceFile(config, file, TargetGroups{&web, tgs[1]}, []int{0, 1})
+		require.NoError(err, "UpdateSourceFile returned an unexpected error")
+
+		got := readTestFile(t, file)
+		require.Contains(got, "- name: web # managed by hand\n", "group comment was not kept")
+		require.Contains(got, "    # spare\n    - host02\n", "target comment was not kept")
+		require.Contains(got, "    rack: r02\n", "labels did not match")
+		require.True(strings.HasSuffix(got, "- jobs:\n    - node\n    - blackbox_icmp\n  targets:\n    - db01\n"), "untouched group was changed")
+	})
+
+	t.Run("JSONUnknownKey", func(t *testing.T) {
+		tempDir, err := os.MkdirTemp("", "edit_test")
+		require.NoError(err, "failed to create temp dir")
+		defer os.RemoveAll(tempDir)
+
+		source := `[{"jobs":["node"],"lables":{"env":"prod"},"targets":["host01"]}]`
+		writeTestFiles(t, tempDir, map[string]string{"targets.json": source})
+		config := core.DefaultConfig()
+		config.Sources = tempDir
+		file := filepath.Join(tempDir, "targets.json")
+
+		tgs, err := ReadSourceFile(file)
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+		tgs[0].Targets = append(tgs[0].Targets, Target{Address: "host02"})
+		err = UpdateSourceFile(config, file, tgs, []int{0})
+		require.ErrorIs(err, os.ErrInvalid, "UpdateSourceFile did not return the correct error")
+		require.ErrorContains(err, `unknown key "lables"`, "UpdateSourceFile did not keep the unknown key")
+		require.Equal(source, readTestFile(t, file), "file was changed")
+	})
+}
@@ -0,0 +1,310 @@
+package targets
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxExpansion is the most addresses a single target entry may expand to. It keeps a typo like
+// 10.0.0.0/8 from producing millions of targets.
+const MaxExpansion = 4096
+
+// rangeRE matches the contents of a range expression such as 01:40, 1:40:2 or a:f.
+var rangeRE = regexp.MustCompile(`^(?:([0-9]+):([0-9]+)|([a-z]):([a-z])|([A-Z]):([A-Z]))(?::([0-9]+))?$`)
+
+// ExpandAddress expands the patterns in a target address into the list of addresses it stands
+// for. An address without patterns is returned as is.
+//
+//	atlwebapp[01:03]    atlwebapp01, atlwebapp02, atlwebapp03
+//	web[1:5:2]          web1, web3, web5
+//	{atl,jfk}db01       atldb01, jfkdb01
+//	10.1.2.0/30         10.1.2.1, 10.1.2.2
+//	10.1.2.0/30:9100    10.1.2.1:9100, 10.1.2.2:9100
+//
+// Ranges and brace sets can be combined and are expanded left to right. A CIDR block must be the
+// whole address with an optional port. The network and broadcast addresses of IPv4 blocks larger
+// than /31 are skipped.
+func ExpandAddress(addr string) ([]string, error) {
+	addrs, err := expandAddress(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", os.ErrInvalid, err)
+	}
+
+	return addrs, nil
+}
+
+// expandAddress does the work for ExpandAddress. Errors are not wrapped so the validator can use
+// them as problem messages.
+func expandAddress(addr string) ([]string, error) {
+	addrs, ok, err := expandCIDR(addr)
+	if err != nil || ok {
+		return addrs, err
+	}
+
+	addrs = []string{addr}
+	for {
+		next := make([]string, 0, len(addrs))
+		changed := false
+		for _, a := range addrs {
+			parts, ok, err := expandFirst(a)
+			if err != nil {
+				return nil, fmt.Errorf("target %q: %w", addr, err)
+			}
+
+			if !ok {
+				next = append(next, a)
+				continue
+			}
+
+			changed = true
+			next = append(next, parts...)
+			if len(next) > MaxExpansion {
+				return nil, fmt.Errorf("target %q expands to more than %d addresses", addr, MaxExpansion)
+			}
+		}
+
+		if !changed {
+			return next, nil
+		}
+
+		addrs = next
+	}
+}
+
+// expandFirst expands the first range or brace set in s. ok is false if s has no patterns left.
+func expandFirst(s string) (out []string, ok bool, err error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, false, fmt.Errorf("unclosed brace set")
+			}
+
+			values := strings.Split(s[i+1:i+end], ",")
+			if len(values) < 2 {
+				return nil, false, fmt.Errorf("brace set %q must list at least two values", s[i:i+end+1])
+			}
+
+			return combine(s[:i], values, s[i+end+1:]), true, nil
+		case '}':
+			return nil, false, fmt.Errorf("unexpected }")
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, false, fmt.Errorf("unclosed range")
+			}
+
+			expr := s[i+1 : i+end]
+			// An IPv6 literal such as [::1]:9100 or http://[::1]:8080/health is left alone.
+			if !rangeRE.MatchString(expr) && isIPv6(expr) {
+				i += end
+				continue
+			}
+
+			values, err := expandRange(expr)
+			if err != nil {
+				return nil, false, err
+			}
+
+			return combine(s[:i], values, s[i+end+1:]), true, nil
+		case ']':
+			return nil, false, fmt.Errorf("unexpected ]")
+		}
+	}
+
+	return nil, false, nil
+}
+
+func combine(prefix string, values []string, suffix string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, prefix+v+suffix)
+	}
+
+	return out
+}
+
+func isIPv6(s string) bool {
+	a, err := netip.ParseAddr(s)
+	return err == nil && a.Is6()
+}
+
+// expandRange expands a numeric or letter range. Numbers keep the width of the start value when it
+// is zero padded, so 01:10 gives 01 through 10.
+func expandRange(expr string) ([]string, error) {
+	m := rangeRE.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid range [%s]; must be [start:end] or [start:end:step]", expr)
+	}
+
+	step := 1
+	if m[7] != "" {
+		var err error
+		step, err = strconv.Atoi(m[7])
+		if err != nil || step < 1 {
+			return nil, fmt.Errorf("invalid range [%s]; step must be a positive number", expr)
+		}
+	}
+
+	if m[1] == "" {
+		start, end := m[3]+m[5], m[4]+m[6]
+		if start[0] > end[0] {
+			return nil, fmt.Errorf("invalid range [%s]; start is after end", expr)
+		}
+
+		out := make([]string, 0)
+		for c := int(start[0]); c <= int(end[0]); c += step {
+			out = append(out, string(rune(c)))
+		}
+
+		return out, nil
+	}
+
+	start, err := strconv.Atoi(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range [%s]: %w", expr, err)
+	}
+
+	end, err := strconv.Atoi(m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range [%s]: %w", expr, err)
+	}
+
+	if start > end {
+		return nil, fmt.Errorf("invalid range [%s]; start is after end", expr)
+	}
+
+	if (end-start)/step+1 > MaxExpansion {
+		return nil, fmt.Errorf("range [%s] expands to more than %d addresses", expr, MaxExpansion)
+	}
+
+	width := 0
+	if len(m[1]) > 1 && m[1][0] == '0' {
+		width = len(m[1])
+	}
+
+	out := make([]string, 0, (end-start)/step+1)
+	for n := start; n <= end; n += step {
+		out = append(out, fmt.Sprintf("%0*d", width, n))
+	}
+
+	return out, nil
+}
+
+// expandCIDR expands addr if it is a CIDR block with an optional port. ok is false if addr is not
+// a CIDR block. IPv6 blocks with a port must be bracketed, as in [2001:db8::/126]:9100.
+func expandCIDR(addr string) (out []string, ok bool, err error) {
+	if !strings.Contains(addr, "/") || strings.Contains(addr, "://") {
+		return nil, false, nil
+	}
+
+	block, port := addr, ""
+	if strings.HasPrefix(addr, "[") {
+		end := strings.Index(addr, "]")
+		if end < 0 {
+			return nil, false, nil
+		}
+
+		block, port = addr[1:end], strings.TrimPrefix(addr[end+1:], ":")
+	} else if i := strings.LastIndex(addr, ":"); i > strings.Index(addr, "/") {
+		block, port = addr[:i], addr[i+1:]
+	}
+
+	prefix, perr := netip.ParsePrefix(block)
+	if perr != nil {
+		// Only report an error for things that look like they were meant to be a CIDR block.
+		if looksLikeCIDR(block) {
+			return nil, false, fmt.Errorf("target %q: %w", addr, perr)
+		}
+
+		return nil, false, nil
+	}
+
+	var portNum uint16
+	if port != "" {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || n == 0 {
+			return nil, false, fmt.Errorf("target %q: invalid port %q", addr, port)
+		}
+
+		portNum = uint16(n)
+	}
+
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 30 || 1<<hostBits > MaxExpansion+2 {
+		return nil, false, fmt.Errorf("target %q expands to more than %d addresses", addr, MaxExpansion)
+	}
+
+	first, last := prefix.Addr(), lastAddr(prefix)
+	// Skip the network and broadcast addresses of IPv4 networks.
+	if first.Is4() && hostBits > 1 {
+		first, last = first.Next(), last.Prev()
+	}
+
+	out = make([]string, 0, 1<<hostBits)
+	for a := first; a.IsValid() && a.Compare(last) <= 0; a = a.Next() {
+		if port == "" {
+			out = append(out, a.String())
+			continue
+		}
+
+		out = append(out, netip.AddrPortFrom(a, portNum).String())
+	}
+
+	return out, true, nil
+}
+
+// lastAddr returns the last address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// looksLikeCIDR returns true if s is made of only the characters used in IP addresses and has a
+// prefix length, such as 10.1.2.300/28.
+func looksLikeCIDR(s string) bool {
+	addr, bits, ok := strings.Cut(s, "/")
+	if !ok || addr == "" || bits == "" {
+		return false
+	}
+
+	return strings.Trim(addr, "0123456789abcdefABCDEF.:") == "" &&
+		strings.Trim(bits, "0123456789") == "" &&
+		strings.ContainsAny(addr, ".:")
+}
+
+// Expand returns a copy of t with every target address pattern expanded. Each expanded target
+// keeps the labels of the entry it came from. The groups in t are not changed so they can still be
+// written back to their source file.
+func (t TargetGroups) Expand() (TargetGroups, error) {
+	expanded := make(TargetGroups, 0, len(t))
+	for _, tg := range t {
+		g := *tg
+		g.Targets = make([]Target, 0, len(tg.Targets))
+		for _, target := range tg.Targets {
+			addrs, err := ExpandAddress(target.Address)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, a := range addrs {
+				g.Targets = append(g.Targets, Target{Address: a, Labels: target.Labels})
+			}
+		}
+
+		expanded = append(expanded, &g)
+	}
+
+	return expanded, nil
+}
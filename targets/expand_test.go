@@ -0,0 +1,108 @@
+package targets
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandAddress(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		name string
+		addr string
+		want []string
+	}{
+		{"Plain", "atlwebapp01", []string{"atlwebapp01"}},
+		{"URL", "https://webapp.example.com:443/health", []string{"https://webapp.example.com:443/health"}},
+		{"IPv6", "[2001:db8::1]:9100", []string{"[2001:db8::1]:9100"}},
+		{"IPv6Scheme", "tcp://[::1]:22", []string{"tcp://[::1]:22"}},
+		{"IPv6SchemePath", "http://[2001:db8::1]:8080/health", []string{"http://[2001:db8::1]:8080/health"}},
+		{"IPv6NoPort", "https://[2001:db8::1]/metrics", []string{"https://[2001:db8::1]/metrics"}},
+		{"IPv6Range", "http://[2001:db8::1]:8080/app[1:2]", []string{
+			"http://[2001:db8::1]:8080/app1",
+			"http://[2001:db8::1]:8080/app2",
+		}},
+		{"Range", "atlwebapp[08:11]", []string{"atlwebapp08", "atlwebapp09", "atlwebapp10", "atlwebapp11"}},
+		{"RangeUnpadded", "web[9:11]", []string{"web9", "web10", "web11"}},
+		{"RangeStep", "web[1:5:2]:9100", []string{"web1:9100", "web3:9100", "web5:9100"}},
+		{"RangeLetters", "rack[a:c]", []string{"racka", "rackb", "rackc"}},
+		{"Brace", "{atl,jfk}db01", []string{"atldb01", "jfkdb01"}},
+		{"Combined", "{atl,jfk}db[1:2]", []string{"atldb1", "atldb2", "jfkdb1", "jfkdb2"}},
+		{"URLRange", "http://atlwebapp[01:02].internal.com:8080", []string{
+			"http://atlwebapp01.internal.com:8080",
+			"http://atlwebapp02.internal.com:8080",
+		}},
+		{"CIDR", "10.1.2.0/30", []string{"10.1.2.1", "10.1.2.2"}},
+		{"CIDRPort", "10.1.2.5/30:9100", []string{"10.1.2.5:9100", "10.1.2.6:9100"}},
+		{"CIDR31", "10.1.2.0/31", []string{"10.1.2.0", "10.1.2.1"}},
+		{"CIDR32", "10.1.2.7/32:9100", []string{"10.1.2.7:9100"}},
+		{"CIDRv6Port", "[2001:db8::/127]:9100", []string{"[2001:db8::]:9100", "[2001:db8::1]:9100"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandAddress(tt.addr)
+			require.NoError(err, "ExpandAddress() returned an error: %s", err)
+			require.Equal(tt.want, got, "expanded addresses did not match")
+		})
+	}
+
+	t.Run("CIDRSize", func(t *testing.T) {
+		got, err := ExpandAddress("10.1.0.0/20")
+		require.NoError(err, "ExpandAddress() returned an error: %s", err)
+		require.Len(got, 4094, "expanded address count did not match")
+	})
+
+	errTests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		{"UnclosedRange", "web[01:10", `target "web[01:10": unclosed range`},
+		{"BadRange", "web[01-10]", `target "web[01-10]": invalid range [01-10]; must be [start:end] or [start:end:step]`},
+		{"Backwards", "web[10:01]", `target "web[10:01]": invalid range [10:01]; start is after end`},
+		{"ZeroStep", "web[1:10:0]", `target "web[1:10:0]": invalid range [1:10:0]; step must be a positive number`},
+		{"UnclosedBrace", "{atl,jfk", `target "{atl,jfk": unclosed brace set`},
+		{"SingleBrace", "{atl}db01", `target "{atl}db01": brace set "{atl}" must list at least two values`},
+		{"Stray", "web]01", `target "web]01": unexpected ]`},
+		{"BadCIDR", "10.1.2.300/28", `target "10.1.2.300/28": netip.ParsePrefix("10.1.2.300/28"): ParseAddr("10.1.2.300"): IPv4 field has value >255`},
+		{"BadPort", "10.1.2.0/28:http", `target "10.1.2.0/28:http": invalid port "http"`},
+		{"HugeCIDR", "10.0.0.0/8", `target "10.0.0.0/8" expands to more than 4096 addresses`},
+		{"HugeRange", "web[1:5000]", `target "web[1:5000]": range [1:5000] expands to more than 4096 addresses`},
+		{"HugeCombined", "web[1:100]-[1:100]", `target "web[1:100]-[1:100]" expands to more than 4096 addresses`},
+	}
+
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExpandAddress(tt.addr)
+			require.ErrorIs(err, os.ErrInvalid, "ExpandAddress() did not return an ErrInvalid")
+			require.Equal("invalid argument: "+tt.want, err.Error(), "error did not match")
+		})
+	}
+}
+
+func TestTargetGroupsExpand(t *testing.T) {
+	require := require.New(t)
+	tgs := TargetGroups{{
+		Jobs:    []string{"node"},
+		Labels:  map[string]string{"env": "prod"},
+		Targets: []Target{{Address: "web[1:2]"}, {Address: "db01", Labels: map[string]string{"rack": "r1"}}},
+	}}
+
+	got, err := tgs.Expand()
+	require.NoError(err, "Expand() returned an error: %s", err)
+	require.Equal(
+		[]Target{{Address: "web1"}, {Address: "web2"}, {Address: "db01", Labels: map[string]string{"rack": "r1"}}},
+		got[0].Targets,
+		"expanded targets did not match",
+	)
+	require.Equal(tgs[0].Labels, got[0].Labels, "labels did not match")
+	require.Equal("web[1:2]", tgs[0].Targets[0].Address, "source groups were changed")
+
+	tgs[0].Targets[0].Address = "web[2:1]"
+	_, err = tgs.Expand()
+	require.ErrorIs(err, os.ErrInvalid, "Expand() did not return an ErrInvalid")
+}
@@ -0,0 +1,230 @@
+package targets
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// Explanation traces how a target is exported for a single job.
+type Explanation struct {
+	Job string `json:"job" yaml:"job"`
+	// Address is the exported target address.
+	Address string `json:"address" yaml:"address"`
+	// Groups are the groups the target is in, in source order.
+	Groups []*ExplainedGroup `json:"groups" yaml:"groups"`
+	// Conflicts are the names of the labels the groups disagree on. See merge_precedence.
+	Conflicts []string `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+	// Labels are the labels the target is exported with. Empty if every group dropped it.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// File is the file_sd targets file the target is written to.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Dropped is true if every group dropped the target while relabeling.
+	Dropped bool `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+}
+
+// ExplainedGroup is a group a target is in and what each step of the export did to it.
+type ExplainedGroup struct {
+	// Source is the name of the source in the config sources list the group was read from.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	File   string `json:"file,omitempty" yaml:"file,omitempty"`
+	Group  int    `json:"group" yaml:"group"`
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Priority is the group priority used by merge_precedence priority.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// Selected is true if the job picked up the target with its selector instead of the group
+	// listing the job.
+	Selected bool `json:"selected,omitempty" yaml:"selected,omitempty"`
+	// SourceAddress is the target address in the group after patterns were expanded.
+	SourceAddress string `json:"source_address" yaml:"source_address"`
+	// SourceLabels are the labels of the source in the config sources list. They are included in
+	// GroupLabels unless the group set the same label.
+	SourceLabels map[string]string `json:"source_labels,omitempty" yaml:"source_labels,omitempty"`
+	// JobLabels, GroupLabels and TargetLabels are merged in that order, so later ones win.
+	JobLabels    map[string]string `json:"job_labels,omitempty" yaml:"job_labels,omitempty"`
+	GroupLabels  map[string]string `json:"group_labels,omitempty" yaml:"group_labels,omitempty"`
+	TargetLabels map[string]string `json:"target_labels,omitempty" yaml:"target_labels,omitempty"`
+	// Address is the target address after the job port, scheme or address template.
+	Address string `json:"address" yaml:"address"`
+	// Relabel lists the relabel rules that changed or dropped the target.
+	Relabel []RelabelStep `json:"relabel,omitempty" yaml:"relabel,omitempty"`
+	// Labels are the labels the group contributed after relabeling.
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Dropped bool              `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+}
+
+// RelabelStep is a relabel rule that changed or dropped a target. Labels are the labels after the
+// rule with the address in __address__.
+type RelabelStep struct {
+	Rule    string            `json:"rule" yaml:"rule"`
+	Action  string            `json:"action" yaml:"action"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Dropped bool              `json:"dropped,omitempty" yaml:"dropped,omitempty"`
+}
+
+// Explanations lists the Explanation of a target for every job it is exported for.
+type Explanations []*Explanation
+
+// namedRule is a relabel rule with where it was set in the config.
+type namedRule struct {
+	relabelRule
+	name string
+}
+
+// Explain traces the target with address addr through the export for job, or every job if job is
+// empty. addr can be the address in the sources or the exported address. The groups are expected
+// to be expanded.
+func (t TargetGroups) Explain(config *core.Config, addr, job string) Explanations {
+	explanations := make(Explanations, 0)
+	byKey := make(map[string]*Explanation)
+	defaults := make(jobDefaultsCache)
+	rules := make(map[string][]namedRule)
+	for _, tg := range t {
+		for _, j := range tg.jobs(config, defaults) {
+			if job != "" && j != job {
+				continue
+			}
+
+			jd := defaults.get(config, j)
+			if _, ok := rules[j]; !ok {
+				rules[j] = explainRules(config, j)
+			}
+
+			for _, target := range tg.Targets {
+				eg := tg.explainTarget(config, jd, rules[j], target)
+				if eg == nil || !slices.Contains([]string{target.Address, eg.Address, eg.exported}, addr) {
+					continue
+				}
+
+				key := j + "\n" + eg.exported
+				e, ok := byKey[key]
+				if !ok {
+					e = &Explanation{Job: j, Address: eg.exported, Dropped: true}
+					byKey[key] = e
+					explanations = append(explanations, e)
+				}
+
+				e.Groups = append(e.Groups, eg.ExplainedGroup)
+				e.Dropped = e.Dropped && eg.Dropped
+			}
+		}
+	}
+
+	if len(explanations) == 0 {
+		return explanations
+	}
+
+	// Use the merged targets so the final labels match the export.
+	merged := make(map[string]*mergedJob)
+	for _, mj := range t.mergeJobs(config) {
+		merged[mj.job] = mj
+	}
+
+	fileSD := len(config.ExportTypes) == 0 || config.ExportTypes[core.ExportTypeFileSD]
+	for _, e := range explanations {
+		mj, ok := merged[e.Job]
+		if !ok || e.Dropped {
+			continue
+		}
+
+		mt, ok := mj.byAddr[e.Address]
+		if !ok {
+			continue
+		}
+
+		e.Labels = mt.labels(config.MergePrecedence)
+		e.Conflicts = mt.conflicts()
+		if fileSD {
+			e.File = targetsFileName(config, e.Labels)
+		}
+	}
+
+	return explanations
+}
+
+// explainedTarget is an ExplainedGroup with the address the target is exported with, which is
+// the address before relabeling if it was dropped.
+type explainedTarget struct {
+	*ExplainedGroup
+	exported string
+}
+
+// explainTarget runs target through the steps exportGroups and relabelGroups apply for the job of
+// jd. nil is returned if the job does not export target.
+func (tg *TargetGroup) explainTarget(
+	config *core.Config,
+	jd *jobDefaults,
+	rules []namedRule,
+	target Target,
+) *explainedTarget {
+	listed := slices.Contains(tg.Jobs, jd.job)
+	if !listed && !jd.selects(tg, target) {
+		return nil
+	}
+
+	labels := jobLabels(jd.job, jd.labels, tg.Labels, target.Labels)
+	eg := &ExplainedGroup{
+		Source:        tg.origin,
+		File:          tg.source,
+		Group:         tg.index,
+		Name:          tg.Name,
+		Priority:      tg.Priority,
+		Selected:      !listed,
+		SourceAddress: target.Address,
+		JobLabels:     jd.labels,
+		GroupLabels:   tg.Labels,
+		TargetLabels:  target.Labels,
+		Address:       jd.rewrite(target.Address, labels),
+	}
+
+	if src := sourceOf(config, tg.source); src != nil {
+		eg.SourceLabels = src.Labels
+	}
+
+	ls := maps.Clone(labels)
+	ls[addressLabel] = eg.Address
+	for _, r := range rules {
+		before := maps.Clone(ls)
+		kept := r.apply(ls)
+		if kept && maps.Equal(before, ls) {
+			continue
+		}
+
+		eg.Relabel = append(eg.Relabel, RelabelStep{Rule: r.name, Action: r.Action, Labels: maps.Clone(ls), Dropped: !kept})
+		if !kept {
+			eg.Dropped = true
+			return &explainedTarget{ExplainedGroup: eg, exported: eg.Address}
+		}
+	}
+
+	ls, exported, ok := relabeled(ls, jd.job)
+	if !ok {
+		eg.Dropped = true
+		return &explainedTarget{ExplainedGroup: eg, exported: eg.Address}
+	}
+
+	eg.Labels = ls
+	return &explainedTarget{ExplainedGroup: eg, exported: exported}
+}
+
+// explainRules returns the global and job relabel rules for job in the order they are applied,
+// named after where they are set in the config.
+func explainRules(config *core.Config, job string) []namedRule {
+	rules := make([]namedRule, 0)
+	add := func(prefix string, configs []*core.RelabelConfig) {
+		for i, rc := range configs {
+			for _, r := range newRelabelRules([]*core.RelabelConfig{rc}) {
+				rules = append(rules, namedRule{relabelRule: r, name: fmt.Sprintf("%s %d", prefix, i)})
+			}
+		}
+	}
+
+	add("relabel_configs", config.RelabelConfigs)
+	if jc := config.Jobs[job]; jc != nil {
+		add("jobs "+job+" relabel_configs", jc.RelabelConfigs)
+	}
+
+	return rules
+}
@@ -0,0 +1,146 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "explain_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	for _, dir := range []string{"local", "cmdb"} {
+		require.NoError(os.Mkdir(filepath.Join(tempDir, dir), 0o755), "failed to create %s", dir)
+	}
+
+	writeTestFiles(t, filepath.Join(tempDir, "local"), map[string]string{
+		"targets.yml": `- name: web
+  jobs: [node]
+  labels: {rack: r01}
+  targets:
+    - atlweb01
+    - address: atlweb02
+      labels: {rack: r02}
+- jobs: [node]
+  labels: {env: test}
+  targets: [atltest01]
+`,
+	})
+	writeTestFiles(t, filepath.Join(tempDir, "cmdb"), map[string]string{
+		"a_targets.yml": "- jobs: [node]\n  labels: {rack: r12, env: prod}\n  targets: [atlweb01]\n",
+	})
+
+	config := core.DefaultConfig()
+	config.TargetsFileExt = ".yml"
+	config.MergePrecedence = core.MergePriority
+	config.SourceList = []*core.SourceConfig{
+		{Name: "local", Path: filepath.Join(tempDir, "local"), Priority: 10},
+		{Name: "cmdb", Path: filepath.Join(tempDir, "cmdb"), Labels: map[string]string{"source": "cmdb"}},
+	}
+	config.Jobs = map[string]*core.JobConfig{
+		"node": {
+			Port:   9100,
+			Labels: map[string]string{"team": "ops"},
+			RelabelConfigs: []*core.RelabelConfig{
+				newTestRule(func(r *core.RelabelConfig) {
+					r.Action = core.RelabelDrop
+					r.SourceLabels = []string{"env"}
+					r.Regex = "test"
+				}),
+			},
+		},
+	}
+	config.RelabelConfigs = []*core.RelabelConfig{
+		newTestRule(func(r *core.RelabelConfig) {
+			r.SourceLabels = []string{"__address__"}
+			r.Regex = "([a-z]{3}).*"
+			r.TargetLabel = "datacenter"
+		}),
+	}
+
+	tgs, err := NewTargetGroups(config)
+	require.NoError(err, "NewTargetGroups returned an unexpected error")
+
+	t.Run("Merged", func(t *testing.T) {
+		es := tgs.Explain(config, "atlweb01", "")
+		require.Len(es, 1, "wrong number of explanations")
+
+		e := es[0]
+		require.Equal("node", e.Job, "job did not match")
+		require.Equal("atlweb01:9100", e.Address, "address did not match")
+		require.Len(e.Groups, 2, "wrong number of groups")
+
+		local := e.Groups[0]
+		require.Equal("local", local.Source, "source did not match")
+		require.Equal(filepath.Join(tempDir, "local", "targets.yml"), local.File, "file did not match")
+		require.Equal("web", local.Name, "name did not match")
+		require.Equal(10, local.Priority, "priority did not match")
+		require.Equal("atlweb01", local.SourceAddress, "source address did not match")
+		require.Equal(map[string]string{"team": "ops"}, local.JobLabels, "job labels did not match")
+		require.Len(local.Relabel, 1, "wrong number of relabel steps")
+		require.Equal("relabel_configs 0", local.Relabel[0].Rule, "rule did not match")
+		require.Equal("atl", local.Relabel[0].Labels["datacenter"], "relabel step labels did not match")
+		require.Equal(
+			map[string]string{"datacenter": "atl", "job": "node", "rack": "r01", "team": "ops"},
+			local.Labels,
+			"local labels did not match",
+		)
+
+		cmdb := e.Groups[1]
+		require.Equal("cmdb", cmdb.Source, "source did not match")
+		require.Equal(map[string]string{"source": "cmdb"}, cmdb.SourceLabels, "source labels did not match")
+		require.Equal(
+			map[string]string{"env": "prod", "rack": "r12", "source": "cmdb"},
+			cmdb.GroupLabels,
+			"group labels did not match",
+		)
+
+		// local has the higher priority so its rack wins.
+		require.Equal([]string{"rack"}, e.Conflicts, "conflicts did not match")
+		require.Equal(
+			map[string]string{
+				"datacenter": "atl",
+				"env":        "prod",
+				"job":        "node",
+				"rack":       "r01",
+				"source":     "cmdb",
+				"team":       "ops",
+			},
+			e.Labels,
+			"final labels did not match",
+		)
+		require.Equal("node_targets.yml", e.File, "file did not match")
+	})
+
+	t.Run("ExportedAddress", func(t *testing.T) {
+		es := tgs.Explain(config, "atlweb02:9100", "node")
+		require.Len(es, 1, "wrong number of explanations")
+		require.Equal(map[string]string{"rack": "r02"}, es[0].Groups[0].TargetLabels, "target labels did not match")
+		require.Equal("r02", es[0].Labels["rack"], "target labels did not win")
+	})
+
+	t.Run("Dropped", func(t *testing.T) {
+		es := tgs.Explain(config, "atltest01", "")
+		require.Len(es, 1, "wrong number of explanations")
+		require.True(es[0].Dropped, "target was not dropped")
+		require.Nil(es[0].Labels, "dropped target has labels")
+		require.Empty(es[0].File, "dropped target has a file")
+
+		steps := es[0].Groups[0].Relabel
+		require.Len(steps, 2, "wrong number of relabel steps")
+		require.Equal("jobs node relabel_configs 0", steps[1].Rule, "rule did not match")
+		require.True(steps[1].Dropped, "drop step was not recorded")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		require.Empty(tgs.Explain(config, "host99", ""), "unknown target was explained")
+		require.Empty(tgs.Explain(config, "atlweb01", "mysql"), "target was explained for another job")
+	})
+}
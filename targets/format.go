@@ -0,0 +1,463 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+// Format returns copies of the groups in canonical form. Jobs are sorted with duplicates removed,
+// target addresses are normalized and repeated targets with the same labels are removed. Labels
+// are always written in name order.
+func (t TargetGroups) Format() TargetGroups {
+	tgs := make(TargetGroups, 0, len(t))
+	for _, tg := range t {
+		c := *tg
+		c.Jobs = slices.Clone(tg.Jobs)
+		slices.Sort(c.Jobs)
+		c.Jobs = slices.Compact(c.Jobs)
+		c.Targets = make([]Target, 0, len(tg.Targets))
+		for _, target := range tg.Targets {
+			target.Address = normalizeAddress(target.Address)
+			if len(target.Labels) == 0 {
+				target.Labels = nil
+			}
+
+			if !slices.ContainsFunc(c.Targets, target.equal) {
+				c.Targets = append(c.Targets, target)
+			}
+		}
+
+		tgs = append(tgs, &c)
+	}
+
+	return tgs
+}
+
+func (t Target) equal(other Target) bool {
+	return t.Address == other.Address && maps.Equal(t.Labels, other.Labels)
+}
+
+// normalizeAddress trims addr, lowercases its scheme and host name, removes the trailing dot of
+// the host name and writes IP addresses in their shortest form. The path is kept as is. Addresses
+// with patterns or user info are only trimmed.
+func normalizeAddress(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if strings.Contains(addr, "@") {
+		return addr
+	}
+
+	if _, ok, err := expandFirst(addr); ok || err != nil {
+		return addr
+	}
+
+	if _, ok, err := expandCIDR(addr); ok || err != nil {
+		return addr
+	}
+
+	vars := splitAddress(addr)
+	if vars.Host == "" {
+		return addr
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(vars.Host), ".")
+	if ip, err := netip.ParseAddr(host); err == nil {
+		host = ip.String()
+	}
+
+	out := host
+	if vars.Port != "" {
+		out = net.JoinHostPort(host, vars.Port)
+	} else if strings.Contains(host, ":") {
+		out = "[" + host + "]"
+	}
+
+	if vars.Scheme != "" {
+		out = strings.ToLower(vars.Scheme) + "://" + out
+	}
+
+	return out + vars.Path
+}
+
+// FormatSource returns the contents of a source file in canonical form. It applies the rules of
+// Format and writes the keys of every group in the same order with the same YAML styles. YAML
+// comments are kept. The file name is used to determine the format. Repeated targets that can
+// not be removed because their labels differ, or their patterns overlap, are an error since
+// validate rejects them too.
+func FormatSource(file string, data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return data, nil
+	}
+
+	groups := doc.Content[0]
+	if groups.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%w: sources must be a list of target groups", os.ErrInvalid)
+	}
+
+	for i, g := range groups.Content {
+		if err := formatGroupNode(i, g); err != nil {
+			return nil, err
+		}
+	}
+
+	resetStyle(&doc)
+	return encodeSource(file, &doc)
+}
+
+// FormatSources formats every writable source file. Files are only written if write is true.
+// The result of each file is FileUpdated if it was not in canonical form.
+func FormatSources(config *core.Config, write bool) (ExportResults, error) {
+	files, err := findFiles(config)
+	if err != nil {
+		return nil, fmt.Errorf("error finding sources file: %w", err)
+	}
+
+	results := make(ExportResults, 0, len(files))
+	for _, f := range files {
+		if !IsWritable(config, f) {
+			continue
+		}
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return results, err
+		}
+
+		out, err := FormatSource(f, data)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", f, err)
+		}
+
+		if bytes.Equal(data, out) {
+			results = append(results, FileResult{File: f, Status: FileUnchanged})
+			continue
+		}
+
+		if write {
+			if err := core.WriteFileAtomic(f, out, 0o644); err != nil {
+				return results, err
+			}
+		}
+
+		results = append(results, FileResult{File: f, Status: FileUpdated})
+	}
+
+	return results, nil
+}
+
+// formatGroupNode applies the rules of Format to the group node at index. An error is returned
+// for a duplicate target that is not an exact repeat.
+func formatGroupNode(index int, node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	sortKeys(node, groupKeys)
+	if jobs := mappingValue(node, "jobs"); jobs != nil && jobs.Kind == yaml.SequenceNode {
+		slices.SortStableFunc(jobs.Content, func(a, b *yaml.Node) int { return strings.Compare(a.Value, b.Value) })
+		jobs.Content = slices.CompactFunc(jobs.Content, func(a, b *yaml.Node) bool {
+			return a.Kind == yaml.ScalarNode && b.Kind == yaml.ScalarNode && a.Value == b.Value
+		})
+	}
+
+	if labels := mappingValue(node, "labels"); labels != nil {
+		sortKeys(labels, nil)
+	}
+
+	targets := mappingValue(node, "targets")
+	if targets == nil || targets.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	kept := make([]*yaml.Node, 0, len(targets.Content))
+	seen := make(map[*yaml.Node]Target, len(targets.Content))
+	addrs := make(map[string]bool)
+	for _, n := range targets.Content {
+		formatTargetNode(n)
+
+		var t Target
+		if err := n.Decode(&t); err == nil {
+			i := slices.IndexFunc(kept, func(k *yaml.Node) bool {
+				s, ok := seen[k]
+				return ok && s.equal(t)
+			})
+			if i >= 0 {
+				// Keep the comments of the repeated target on the one that is kept.
+				mergeComments(kept[i], n)
+				continue
+			}
+
+			seen[n] = t
+			if err := checkDuplicate(addrs, t.Address); err != nil {
+				return fmt.Errorf("line %d: group %d: %w", n.Line, index, err)
+			}
+		}
+
+		kept = append(kept, n)
+	}
+
+	targets.Content = kept
+	return nil
+}
+
+// checkDuplicate returns an error if an address addr expands to is in seen, the same way validate
+// finds duplicate targets, and adds the addresses to seen.
+func checkDuplicate(seen map[string]bool, addr string) error {
+	addrs, err := expandAddress(addr)
+	if err != nil {
+		// validate reports the bad pattern.
+		return nil
+	}
+
+	for _, a := range addrs {
+		if seen[a] {
+			return fmt.Errorf("%w: duplicate target %q", os.ErrInvalid, a)
+		}
+	}
+
+	for _, a := range addrs {
+		seen[a] = true
+	}
+
+	return nil
+}
+
+// formatTargetNode normalizes the address of a target node. Targets without labels are written
+// as a plain address.
+func formatTargetNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		node.Value = normalizeAddress(node.Value)
+		return
+	case yaml.MappingNode:
+	default:
+		return
+	}
+
+	sortKeys(node, targetKeys)
+	addr := mappingValue(node, "address")
+	if addr == nil || addr.Kind != yaml.ScalarNode {
+		return
+	}
+
+	addr.Value = normalizeAddress(addr.Value)
+	labels := mappingValue(node, "labels")
+	if labels != nil && len(labels.Content) > 0 {
+		sortKeys(labels, nil)
+		return
+	}
+
+	if len(node.Content) > 4 || (labels == nil && len(node.Content) > 2) {
+		// Keep unknown keys so validate can report them.
+		return
+	}
+
+	*node = yaml.Node{
+		Kind:        yaml.ScalarNode,
+		Tag:         "!!str",
+		Value:       addr.Value,
+		HeadComment: node.HeadComment,
+		LineComment: strings.TrimSpace(node.LineComment + " " + addr.LineComment),
+		FootComment: node.FootComment,
+	}
+}
+
+// mergeComments adds the comments of from to the comments of to.
+func mergeComments(to, from *yaml.Node) {
+	join := func(a, b string) string {
+		if a == "" || b == "" {
+			return a + b
+		}
+
+		return a + "\n" + b
+	}
+
+	to.HeadComment = join(to.HeadComment, from.HeadComment)
+	to.LineComment = strings.TrimSpace(to.LineComment + " " + from.LineComment)
+	to.FootComment = join(to.FootComment, from.FootComment)
+}
+
+// sortKeys sorts the keys of a mapping node in the order of keys, with any other keys after them
+// in name order. Every key is sorted by name if keys is nil.
+func sortKeys(node *yaml.Node, keys []string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	type pair struct{ key, value *yaml.Node }
+	pairs := make([]pair, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, pair{node.Content[i], node.Content[i+1]})
+	}
+
+	rank := func(k string) int {
+		if i := slices.Index(keys, k); i >= 0 {
+			return i
+		}
+
+		return len(keys)
+	}
+
+	slices.SortStableFunc(pairs, func(a, b pair) int {
+		if c := rank(a.key.Value) - rank(b.key.Value); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.key.Value, b.key.Value)
+	})
+
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+	}
+}
+
+// yaml11Plain matches the plain scalars that YAML 1.1 reads as booleans, sexagesimal numbers or
+// merge and value keys. yaml.v3 follows YAML 1.2 and writes them without quotes, so older parsers
+// would not read them back as strings. The other non-string forms are quoted by yaml.v3.
+var yaml11Plain = regexp.MustCompile(
+	`^(y|Y|yes|Yes|YES|n|N|no|No|NO|on|On|ON|off|Off|OFF|<<|=|` +
+		`[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?)$`,
+)
+
+// resetStyle removes the quoting and flow styles of node and its children so every file is
+// written the same way. Values that need quotes are still quoted, including strings that YAML 1.1
+// would read as another type.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!str" && yaml11Plain.MatchString(node.Value) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if v.LineComment == "" || (v.Kind != yaml.MappingNode && v.Kind != yaml.SequenceNode) {
+				continue
+			}
+
+			// yaml.v3 writes the line comment of a block value after the next key, so keep it
+			// on the key instead.
+			k.LineComment = strings.TrimSpace(k.LineComment + " " + v.LineComment)
+			v.LineComment = ""
+		}
+	}
+
+	for _, c := range node.Content {
+		resetStyle(c)
+	}
+}
+
+// encodeSource returns doc in the format set by the file extension. Both formats are written from
+// the nodes so unknown keys are kept for validate to report. YAML keeps its comments. JSON is
+// indented the same way WriteSourceFile writes it.
+func encodeSource(file string, doc *yaml.Node) ([]byte, error) {
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt:
+		var b bytes.Buffer
+		if err := encodeJSONNode(&b, doc); err != nil {
+			return nil, err
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, b.Bytes(), "", "  "); err != nil {
+			return nil, err
+		}
+
+		return out.Bytes(), nil
+	case core.DefaultYAMLFileExt, ".yaml":
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+
+		return b.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown file extension for file: %s", os.ErrInvalid, file)
+}
+
+// encodeJSONNode writes node to b as compact JSON with its keys in the same order.
+func encodeJSONNode(b *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			b.WriteString("null")
+			return nil
+		}
+
+		return encodeJSONNode(b, node.Content[0])
+	case yaml.AliasNode:
+		return encodeJSONNode(b, node.Alias)
+	case yaml.SequenceNode:
+		b.WriteByte('[')
+		for i, c := range node.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			if err := encodeJSONNode(b, c); err != nil {
+				return err
+			}
+		}
+
+		b.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		b.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+
+			b.Write(key)
+			b.WriteByte(':')
+			if err := encodeJSONNode(b, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+
+		b.WriteByte('}')
+		return nil
+	}
+
+	// Decode the scalar so numbers, booleans and null keep their JSON type.
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	b.Write(data)
+	return nil
+}
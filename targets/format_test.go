@@ -0,0 +1,235 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+const formatTestSource = `# web servers
+- targets: [HOST01.Example.com., "host01.example.com", 'http://Web01:8080/metrics']
+  labels: {rack: r01, environment: prod} # top of rack
+  jobs: [node, blackbox, node]
+  name: web # managed by hand
+- jobs:
+    - node
+  targets:
+    - address: db01 # primary
+    - DB01 # moved from the old group
+    - address: db02
+      labels:
+        role: replica
+        az: b
+`
+
+const formatTestWant = `# web servers
+- name: web # managed by hand
+  jobs:
+    - blackbox
+    - node
+  labels: # top of rack
+    environment: prod
+    rack: r01
+  targets:
+    - host01.example.com
+    - http://web01:8080/metrics
+- jobs:
+    - node
+  targets:
+    - db01 # primary # moved from the old group
+    - address: db02
+      labels:
+        az: b
+        role: replica
+`
+
+func TestFormatNormalizeAddress(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{" host01 ", "host01"},
+		{"HOST01.Example.COM.", "host01.example.com"},
+		{"Host01:9100", "host01:9100"},
+		{"HTTPS://Host01:9100/Metrics", "https://host01:9100/Metrics"},
+		{"10.0.0.1:9100", "10.0.0.1:9100"},
+		{"2001:DB8:0:0::1", "[2001:db8::1]"},
+		{"[2001:DB8::1]:9100", "[2001:db8::1]:9100"},
+		{"HTTP://[2001:DB8:0::1]:8080/Health", "http://[2001:db8::1]:8080/Health"},
+		{"host[01:03].Example.com", "host[01:03].Example.com"},
+		{"10.0.0.0/30", "10.0.0.0/30"},
+		{"User@Host01", "User@Host01"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(tt.want, normalizeAddress(tt.addr), "address %q did not match", tt.addr)
+	}
+}
+
+func TestFormatTargetGroups(t *testing.T) {
+	require := require.New(t)
+
+	tgs := TargetGroups{
+		{
+			Jobs:    []string{"node", "blackbox", "node"},
+			Targets: []Target{{Address: "Host01."}, {Address: "host01", Labels: map[string]string{}}, {Address: "host02"}},
+		},
+	}
+	want := TargetGroups{
+		{
+			Jobs:    []string{"blackbox", "node"},
+			Targets: []Target{{Address: "host01"}, {Address: "host02"}},
+		},
+	}
+
+	got := tgs.Format()
+	require.Equal(want, got, "groups did not match")
+	require.Equal([]string{"node", "blackbox", "node"}, tgs[0].Jobs, "original groups were changed")
+}
+
+func TestFormatFormatSource(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("YAML", func(t *testing.T) {
+		got, err := FormatSource("targets.yml", []byte(formatTestSource))
+		require.NoError(err, "FormatSource returned an unexpected error")
+		require.Equal(formatTestWant, string(got), "source did not match")
+
+		again, err := FormatSource("targets.yml", got)
+		require.NoError(err, "FormatSource returned an unexpected error")
+		require.Equal(string(got), string(again), "formatted source changed")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data := `[{"targets":["Host02","host01","host02"],"jobs":["node","blackbox"]}]`
+		got, err := FormatSource("targets.json", []byte(data))
+		require.NoError(err, "FormatSource returned an unexpected error")
+
+		want := TargetGroups{{Jobs: []string{"blackbox", "node"}, Targets: []Target{{Address: "host02"}, {Address: "host01"}}}}
+		wantData, err := core.MarshalJSON(&want)
+		require.NoError(err, "failed to marshal want groups")
+		require.Equal(string(wantData), string(got), "source did not match")
+	})
+
+	t.Run("JSONUnknownKey", func(t *testing.T) {
+		data := `[{"targets":["host01"],"lables":{"env":"prod"},"jobs":["node"],"priority":2}]`
+		got, err := FormatSource("targets.json", []byte(data))
+		require.NoError(err, "FormatSource returned an unexpected error")
+
+		want := `[
+  {
+    "priority": 2,
+    "jobs": [
+      "node"
+    ],
+    "targets": [
+      "host01"
+    ],
+    "lables": {
+      "env": "prod"
+    }
+  }
+]`
+		require.Equal(want, string(got), "source did not match")
+		require.Equal(1, validateSource(nil, "targets.json", got).Errors(), "unknown key was not reported")
+	})
+
+	t.Run("YAML11Strings", func(t *testing.T) {
+		data := "- jobs: [node]\n  labels: {backup: 'yes', 'on': \"off\", window: \"1:30\", env: prod, tls: \"true\"}\n  targets: [host01]\n"
+		got, err := FormatSource("targets.yml", []byte(data))
+		require.NoError(err, "FormatSource returned an unexpected error")
+
+		want := `- jobs:
+    - node
+  labels:
+    backup: "yes"
+    env: prod
+    "on": "off"
+    tls: "true"
+    window: "1:30"
+  targets:
+    - host01
+`
+		require.Equal(want, string(got), "source did not match")
+
+		tgs, err := decodeSource("targets.yml", got)
+		require.NoError(err, "failed to decode formatted source")
+		require.Equal(map[string]string{"backup": "yes", "env": "prod", "on": "off", "tls": "true", "window": "1:30"}, tgs[0].Labels, "labels did not match")
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		for _, data := range []string{
+			"- jobs: [node]\n  targets: [host01, {address: host01, labels: {env: prod}}]\n",
+			"- jobs: [node]\n  targets: [web2, \"web[1:3]\"]\n",
+		} {
+			_, err := FormatSource("targets.yml", []byte(data))
+			require.ErrorIs(err, os.ErrInvalid, "FormatSource did not return the correct error")
+			require.ErrorContains(err, "duplicate target", "FormatSource error did not match")
+			require.Positive(validateSource(nil, "targets.yml", []byte(data)).Errors(), "validate did not report the duplicate")
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		got, err := FormatSource("targets.yml", []byte("# nothing yet\n"))
+		require.NoError(err, "FormatSource returned an unexpected error")
+		require.Equal("# nothing yet\n", string(got), "source did not match")
+	})
+
+	t.Run("NotList", func(t *testing.T) {
+		_, err := FormatSource("targets.yml", []byte("targets: [host01]\n"))
+		require.ErrorIs(err, os.ErrInvalid)
+	})
+
+	t.Run("UnknownExt", func(t *testing.T) {
+		_, err := FormatSource("targets.txt", []byte("- targets: [host01]\n"))
+		require.ErrorIs(err, os.ErrInvalid)
+	})
+}
+
+func TestFormatFormatSources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "format_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	writeTestFiles(t, tempDir, map[string]string{
+		"web_targets.yml": formatTestSource,
+		"db_targets.yml":  formatTestWant,
+	})
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+	file := filepath.Join(tempDir, "web_targets.yml")
+	done := filepath.Join(tempDir, "db_targets.yml")
+
+	t.Run("Check", func(t *testing.T) {
+		results, err := FormatSources(config, false)
+		require.NoError(err, "FormatSources returned an unexpected error")
+		require.ElementsMatch(ExportResults{
+			{File: done, Status: FileUnchanged},
+			{File: file, Status: FileUpdated},
+		}, results, "results did not match")
+		require.Equal(formatTestSource, readTestFile(t, file), "file was written")
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		results, err := FormatSources(config, true)
+		require.NoError(err, "FormatSources returned an unexpected error")
+		require.ElementsMatch(ExportResults{
+			{File: done, Status: FileUnchanged},
+			{File: file, Status: FileUpdated},
+		}, results, "results did not match")
+		require.Equal(formatTestWant, readTestFile(t, file), "file did not match")
+
+		results, err = FormatSources(config, false)
+		require.NoError(err, "FormatSources returned an unexpected error")
+		for _, r := range results {
+			require.Equal(FileUnchanged, r.Status, "%s was not formatted", r.File)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package targets
+
+import (
+	"sync"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// JobMap holds the ExportGroups for each job.
+type JobMap map[string]ExportGroups
+
+// JobGroups arranges the ExportGroups by job for http_sd. Unlike file_sd the groups for a job are
+// never split by target_split since Prometheus requests them one job at a time. Targets are merged
+// the same way as for file_sd.
+func (t TargetGroups) JobGroups(config *core.Config) JobMap {
+	jobs := make(JobMap)
+	for _, mj := range t.mergeJobs(config) {
+		jobs[mj.job] = mj.exportGroups(config.MergePrecedence)
+	}
+
+	return jobs
+}
+
+// Store holds the latest ExportGroups for each job in memory so the http_sd endpoint can serve
+// them. It is safe to use from multiple goroutines.
+type Store struct {
+	mu   sync.RWMutex
+	jobs JobMap
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	return &Store{jobs: make(JobMap)}
+}
+
+// Set replaces every job in the store with jobs.
+func (s *Store) Set(jobs JobMap) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = jobs
+}
+
+// Get returns the ExportGroups for job. An empty list is returned for unknown jobs so Prometheus
+// drops the targets of a job that was removed from the sources.
+func (s *Store) Get(job string) ExportGroups {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	egs, ok := s.jobs[job]
+	if !ok {
+		return ExportGroups{}
+	}
+
+	return egs
+}
+
+// Jobs returns the names of every job in the store in sorted order.
+func (s *Store) Jobs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedKeys(s.jobs)
+}
@@ -0,0 +1,45 @@
+package targets
+
+import (
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobGroups(t *testing.T) {
+	require := require.New(t)
+
+	want := JobMap{
+		"blackbox_icmp":  splitTargetGroups["blackbox_icmp_targets.yml"],
+		"node-exporter":  splitTargetGroups["node-exporter_targets.yml"],
+		"mysql-exporter": splitTargetGroups["mysql-exporter_targets.yml"],
+	}
+	require.Equal(want, expectedTargetGroups.JobGroups(core.DefaultConfig()), "job groups did not match")
+}
+
+func TestStore(t *testing.T) {
+	require := require.New(t)
+	store := NewStore()
+
+	t.Run("Empty", func(t *testing.T) {
+		require.Empty(store.Jobs(), "jobs were not empty")
+		require.Equal(ExportGroups{}, store.Get("node-exporter"), "unknown job was not empty")
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		store.Set(expectedTargetGroups.JobGroups(core.DefaultConfig()))
+		require.Equal([]string{"blackbox_icmp", "mysql-exporter", "node-exporter"}, store.Jobs())
+		require.Equal(
+			splitTargetGroups["node-exporter_targets.yml"],
+			store.Get("node-exporter"),
+			"job groups did not match",
+		)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		store.Set(TargetGroups{expectedTargetGroups[0]}.JobGroups(core.DefaultConfig()))
+		require.Equal([]string{"blackbox_icmp"}, store.Jobs(), "jobs did not match")
+		require.Equal(ExportGroups{}, store.Get("node-exporter"), "removed job was not empty")
+	})
+}
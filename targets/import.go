@@ -0,0 +1,205 @@
+package targets
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// importExts are the extensions of the file_sd files read by ImportTargets.
+var importExts = []string{core.DefaultJSONFileExt, core.DefaultYAMLFileExt, ".yaml"}
+
+// ImportTargets reads every Prometheus file_sd file in dir and returns them as source target
+// groups. The job label of each group becomes its job. Groups without a job label use the file
+// name without the extension and the config targets_file_suffix. Groups with the same labels and
+// targets in more than one job are merged into a single group with every job, and groups with the
+// same jobs and labels are merged into a single group with every target.
+//
+// file_sd scrapes a target once for each label set it has in a job but pim exports it once, so a
+// target with different labels in the same job returns an error. Labels starting with __ can not
+// be set in sources and targets that look like address patterns would be expanded, so they also
+// return an error.
+func ImportTargets(config *core.Config, dir string) (TargetGroups, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := newImporter()
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(importExts, filepath.Ext(entry.Name())) {
+			continue
+		}
+
+		file := filepath.Join(dir, entry.Name())
+		egs, err := readExportGroups(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", os.ErrInvalid, err)
+		}
+
+		if err := imp.add(file, fileJob(config, entry.Name()), egs); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", os.ErrInvalid, file, err)
+		}
+	}
+
+	if len(imp.groups) == 0 {
+		return nil, fmt.Errorf("%w: no file_sd targets found in %s", os.ErrNotExist, dir)
+	}
+
+	return imp.compact(), nil
+}
+
+// fileJob returns the job for groups without a job label in the file name.
+//
+//	node_targets.json  =>  node
+func fileJob(config *core.Config, name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if config != nil && config.TargetsFileSuffix != "" {
+		name = strings.TrimSuffix(name, config.TargetsFileSuffix)
+	}
+
+	return name
+}
+
+// importer collects the file_sd groups read by ImportTargets.
+type importer struct {
+	groups TargetGroups
+	// byKey finds the group with the same labels and targets.
+	byKey map[string]*TargetGroup
+	// seen holds every target address in each job.
+	seen map[string]map[string]seenTarget
+}
+
+// seenTarget is the labels key of a target and the file it was first seen in.
+type seenTarget struct {
+	key  string
+	file string
+}
+
+func newImporter() *importer {
+	return &importer{
+		groups: make(TargetGroups, 0),
+		byKey:  make(map[string]*TargetGroup),
+		seen:   make(map[string]map[string]seenTarget),
+	}
+}
+
+// add adds the groups of a file_sd file. job is used for groups without a job label.
+func (imp *importer) add(file, job string, egs ExportGroups) error {
+	for i, eg := range egs {
+		labels := maps.Clone(eg.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+
+		j := job
+		if v := labels["job"]; v != "" {
+			j = v
+		}
+		delete(labels, "job")
+
+		for _, k := range sortedKeys(labels) {
+			if strings.HasPrefix(k, "__") {
+				return fmt.Errorf("group %d: label %q is reserved and can not be imported", i, k)
+			}
+		}
+
+		key := labelsKey(labels)
+		addrs := make([]string, 0, len(eg.Targets))
+		for _, addr := range eg.Targets {
+			if got, err := expandAddress(addr); err != nil || len(got) != 1 || got[0] != addr {
+				return fmt.Errorf("group %d: target %q would be expanded as an address pattern", i, addr)
+			}
+
+			dup, err := imp.see(file, j, addr, key)
+			if err != nil {
+				return err
+			}
+
+			if !dup && !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+
+		if len(addrs) == 0 {
+			continue
+		}
+
+		sorted := slices.Clone(addrs)
+		slices.Sort(sorted)
+		groupKey := key + "\n" + strings.Join(sorted, "\n")
+		if tg, ok := imp.byKey[groupKey]; ok {
+			if !slices.Contains(tg.Jobs, j) {
+				tg.Jobs = append(tg.Jobs, j)
+			}
+
+			continue
+		}
+
+		tg := &TargetGroup{Jobs: []string{j}, Targets: make([]Target, 0, len(addrs))}
+		if len(labels) > 0 {
+			tg.Labels = labels
+		}
+
+		for _, addr := range addrs {
+			tg.Targets = append(tg.Targets, Target{Address: addr})
+		}
+
+		imp.byKey[groupKey] = tg
+		imp.groups = append(imp.groups, tg)
+	}
+
+	return nil
+}
+
+// see records addr in job and returns true if it was already seen with the same labels.
+func (imp *importer) see(file, job, addr, key string) (bool, error) {
+	targets, ok := imp.seen[job]
+	if !ok {
+		targets = make(map[string]seenTarget)
+		imp.seen[job] = targets
+	}
+
+	prev, ok := targets[addr]
+	if !ok {
+		targets[addr] = seenTarget{key: key, file: file}
+		return false, nil
+	}
+
+	if prev.key != key {
+		return false, fmt.Errorf(
+			"job %s target %q has different labels in %s and %s",
+			job,
+			addr,
+			filepath.Base(prev.file),
+			filepath.Base(file),
+		)
+	}
+
+	return true, nil
+}
+
+// compact merges the groups with the same jobs and labels into the first one.
+func (imp *importer) compact() TargetGroups {
+	tgs := make(TargetGroups, 0, len(imp.groups))
+	byKey := make(map[string]*TargetGroup)
+	for _, tg := range imp.groups {
+		jobs := slices.Clone(tg.Jobs)
+		slices.Sort(jobs)
+		key := strings.Join(jobs, ",") + "\n" + labelsKey(tg.Labels)
+		if first, ok := byKey[key]; ok {
+			first.Targets = append(first.Targets, tg.Targets...)
+			continue
+		}
+
+		byKey[key] = tg
+		tgs = append(tgs, tg)
+	}
+
+	return tgs
+}
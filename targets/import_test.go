@@ -0,0 +1,152 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+var testFileSD = map[string]string{
+	"node_targets.json": `[
+  {"labels": {"job": "node", "datacenter": "atl"}, "targets": ["atlweb01:9100", "atlweb02:9100"]},
+  {"labels": {"job": "node", "datacenter": "jfk"}, "targets": ["jfkdb01:9100"]},
+  {"labels": {"job": "node", "datacenter": "jfk", "rack": "r12"}, "targets": ["jfkdb02:9100"]}
+]`,
+	"mysql_targets.json": `[
+  {"labels": {"job": "mysql", "datacenter": "jfk"}, "targets": ["jfkdb01:9100"]},
+  {"labels": {"job": "mysql", "datacenter": "jfk"}, "targets": ["jfkdb03:9100"]}
+]`,
+	"blackbox_targets.yml": `- labels:
+    datacenter: atl
+  targets:
+    - atlweb02:9100
+    - atlweb01:9100
+`,
+	"README.md": "not a targets file",
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		require.NoError(t, err, "failed to write %s", name)
+	}
+}
+
+func TestImportTargets(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "import_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	importDir := filepath.Join(tempDir, "import")
+	require.NoError(os.Mkdir(importDir, 0o755), "failed to create import dir")
+	writeTestFiles(t, importDir, testFileSD)
+
+	config := core.DefaultConfig()
+
+	t.Run("Merge", func(t *testing.T) {
+		got, err := ImportTargets(config, importDir)
+		require.NoError(err, "ImportTargets returned an unexpected error")
+
+		want := TargetGroups{
+			{
+				Jobs:    []string{"blackbox", "node"},
+				Labels:  map[string]string{"datacenter": "atl"},
+				Targets: []Target{{Address: "atlweb02:9100"}, {Address: "atlweb01:9100"}},
+			},
+			{
+				Jobs:    []string{"mysql", "node"},
+				Labels:  map[string]string{"datacenter": "jfk"},
+				Targets: []Target{{Address: "jfkdb01:9100"}},
+			},
+			{
+				Jobs:    []string{"mysql"},
+				Labels:  map[string]string{"datacenter": "jfk"},
+				Targets: []Target{{Address: "jfkdb03:9100"}},
+			},
+			{
+				Jobs:    []string{"node"},
+				Labels:  map[string]string{"datacenter": "jfk", "rack": "r12"},
+				Targets: []Target{{Address: "jfkdb02:9100"}},
+			},
+		}
+		require.Equal(want, got, "imported groups did not match")
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		tgs, err := ImportTargets(config, importDir)
+		require.NoError(err, "ImportTargets returned an unexpected error")
+
+		config := core.DefaultConfig()
+		config.TargetsDir = filepath.Join(tempDir, "targets")
+		require.NoError(os.Mkdir(config.TargetsDir, 0o755), "failed to create targets dir")
+
+		_, err = tgs.ExportTargets(config)
+		require.NoError(err, "ExportTargets returned an unexpected error")
+
+		// blackbox had no job label so it was named after the file.
+		for _, name := range []string{"node_targets.json", "mysql_targets.json", "blackbox_targets.yml"} {
+			old, err := readExportGroups(filepath.Join(importDir, name))
+			require.NoError(err, "failed to read %s", name)
+			for _, eg := range old {
+				eg.Labels = jobLabels(fileJob(config, name), eg.Labels)
+			}
+
+			exported := filepath.Join(config.TargetsDir, fileJob(config, name)+"_targets.json")
+			new, err := readExportGroups(exported)
+			require.NoError(err, "failed to read %s", exported)
+			require.Equal(targetLabels(old), targetLabels(new), "%s targets did not match", name)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			files map[string]string
+			want  string
+		}{
+			{
+				"Conflict",
+				map[string]string{
+					"a.yml": "- labels: {job: node, rack: r01}\n  targets: [host01]\n",
+					"b.yml": "- labels: {job: node, rack: r12}\n  targets: [host01]\n",
+				},
+				`job node target "host01" has different labels in a.yml and b.yml`,
+			},
+			{
+				"Reserved",
+				map[string]string{"a.yml": "- labels: {__scheme__: https}\n  targets: [host01]\n"},
+				`group 0: label "__scheme__" is reserved and can not be imported`,
+			},
+			{
+				"Pattern",
+				map[string]string{"a.yml": "- targets: [\"web[1:3]\"]\n"},
+				`group 0: target "web[1:3]" would be expanded as an address pattern`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				dir := filepath.Join(tempDir, tt.name)
+				require.NoError(os.Mkdir(dir, 0o755), "failed to create %s", dir)
+				writeTestFiles(t, dir, tt.files)
+
+				_, err := ImportTargets(config, dir)
+				require.ErrorIs(err, os.ErrInvalid, "ImportTargets did not return the correct error")
+				require.ErrorContains(err, tt.want, "error did not match")
+			})
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		dir := filepath.Join(tempDir, "empty")
+		require.NoError(os.Mkdir(dir, 0o755), "failed to create %s", dir)
+
+		_, err := ImportTargets(config, dir)
+		require.ErrorIs(err, os.ErrNotExist, "ImportTargets did not return the correct error")
+	})
+}
@@ -0,0 +1,173 @@
+package targets
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// jobDefaults applies the config jobs settings for a single job to its targets.
+type jobDefaults struct {
+	job     string
+	port    string
+	scheme  string
+	address *template.Template
+	labels  map[string]string
+	relabel []relabelRule
+	// selector is set if the job picks up targets by their labels.
+	selector *core.JobConfig
+}
+
+// newJobDefaults returns the defaults and relabel rules for job. Jobs without settings get only
+// the global relabel rules.
+func newJobDefaults(config *core.Config, job string) *jobDefaults {
+	jd := &jobDefaults{job: job}
+	jc, ok := config.Jobs[job]
+	if !ok || jc == nil {
+		jd.relabel = newRelabelRules(config.RelabelConfigs)
+		return jd
+	}
+
+	jd.relabel = newRelabelRules(config.RelabelConfigs, jc.RelabelConfigs)
+
+	if jc.Port > 0 {
+		jd.port = strconv.Itoa(jc.Port)
+	}
+
+	jd.scheme = jc.Scheme
+	jd.labels = jc.Labels
+	if jc.HasSelector() {
+		jd.selector = jc
+	}
+
+	// The template is checked when the config is loaded. A config built in code with a bad
+	// template leaves the addresses as they are.
+	jd.address, _ = jc.AddressTemplate()
+	return jd
+}
+
+// jobDefaultsCache builds jobDefaults once per job for a single export.
+type jobDefaultsCache map[string]*jobDefaults
+
+func (c jobDefaultsCache) get(config *core.Config, job string) *jobDefaults {
+	jd, ok := c[job]
+	if !ok {
+		jd = newJobDefaults(config, job)
+		c[job] = jd
+	}
+
+	return jd
+}
+
+// selects returns true if the job selector matches target in tg.
+func (jd *jobDefaults) selects(tg *TargetGroup, target Target) bool {
+	if jd.selector == nil {
+		return false
+	}
+
+	return jd.selector.Selects(jobLabels(jd.job, tg.Labels, target.Labels))
+}
+
+// jobs returns the jobs tg is exported for. These are the jobs the group lists followed by every
+// job whose selector matches at least one of its targets, in name order.
+func (tg *TargetGroup) jobs(config *core.Config, defaults jobDefaultsCache) []string {
+	jobs := slices.Clone(tg.Jobs)
+	for _, job := range sortedKeys(config.Jobs) {
+		if slices.Contains(jobs, job) {
+			continue
+		}
+
+		jd := defaults.get(config, job)
+		if slices.ContainsFunc(tg.Targets, func(t Target) bool { return jd.selects(tg, t) }) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// TargetJobs returns the jobs target in tg is exported for: the jobs the group lists followed by
+// every job whose selector matches the target, in name order. tg must have the labels of its
+// source, as WithSources returns it.
+func (tg *TargetGroup) TargetJobs(config *core.Config, target Target) []string {
+	jobs := slices.Clone(tg.Jobs)
+	for _, job := range sortedKeys(config.Jobs) {
+		jc := config.Jobs[job]
+		if slices.Contains(jobs, job) || jc == nil {
+			continue
+		}
+
+		if jc.Selects(jobLabels(job, tg.Labels, target.Labels)) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// rewrite returns addr with the job port and scheme added, or the job address template applied.
+// labels are the labels the target will be exported with.
+func (jd *jobDefaults) rewrite(addr string, labels map[string]string) string {
+	if jd.port == "" && jd.scheme == "" && jd.address == nil {
+		return addr
+	}
+
+	vars := splitAddress(addr)
+	if vars.Scheme == "" {
+		vars.Scheme = jd.scheme
+	}
+
+	if vars.Port == "" {
+		vars.Port = jd.port
+	}
+
+	if jd.address != nil {
+		vars.Job = jd.job
+		vars.Labels = labels
+
+		var b strings.Builder
+		if err := jd.address.Execute(&b, vars); err != nil {
+			return addr
+		}
+
+		return b.String()
+	}
+
+	out := vars.Host
+	if vars.Port != "" {
+		out = net.JoinHostPort(vars.Host, vars.Port)
+	} else if strings.Contains(vars.Host, ":") {
+		out = "[" + vars.Host + "]"
+	}
+
+	if vars.Scheme != "" {
+		out = vars.Scheme + "://" + out
+	}
+
+	return out + vars.Path
+}
+
+// splitAddress splits a target address into its scheme, host, port and path.
+func splitAddress(addr string) core.AddressVars {
+	vars := core.AddressVars{Address: addr}
+	rest := addr
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		vars.Scheme, rest = scheme, after
+	}
+
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest, vars.Path = rest[:i], rest[i:]
+	}
+
+	if host, port, err := net.SplitHostPort(rest); err == nil {
+		vars.Host, vars.Port = host, port
+		return vars
+	}
+
+	vars.Host = strings.TrimSuffix(strings.TrimPrefix(rest, "["), "]")
+	return vars
+}
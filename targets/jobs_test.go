@@ -0,0 +1,188 @@
+package targets
+
+import (
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsSplitAddress(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		addr string
+		want core.AddressVars
+	}{
+		{"host01", core.AddressVars{Host: "host01"}},
+		{"host01:9100", core.AddressVars{Host: "host01", Port: "9100"}},
+		{"https://host01/health", core.AddressVars{Scheme: "https", Host: "host01", Path: "/health"}},
+		{"http://host01:8080/", core.AddressVars{Scheme: "http", Host: "host01", Port: "8080", Path: "/"}},
+		{"[2001:db8::1]:9100", core.AddressVars{Host: "2001:db8::1", Port: "9100"}},
+		{"2001:db8::1", core.AddressVars{Host: "2001:db8::1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			tt.want.Address = tt.addr
+			require.Equal(tt.want, splitAddress(tt.addr), "address parts did not match")
+		})
+	}
+}
+
+func TestJobsRewrite(t *testing.T) {
+	require := require.New(t)
+	config := core.DefaultConfig()
+	config.Jobs = map[string]*core.JobConfig{
+		"node_exporter":  {Port: 9100},
+		"blackbox_http":  {Scheme: "https", Port: 443},
+		"mysqld":         {Address: "{{.Host}}:9104"},
+		"blackbox_label": {Scheme: "http", Address: "{{.Scheme}}://{{.Host}}.{{.Labels.datacenter}}.example.com{{.Path}}"},
+	}
+
+	tests := []struct {
+		job  string
+		addr string
+		want string
+	}{
+		{"node_exporter", "host01", "host01:9100"},
+		{"node_exporter", "host01:9200", "host01:9200"},
+		{"node_exporter", "2001:db8::1", "[2001:db8::1]:9100"},
+		{"blackbox_http", "host01", "https://host01:443"},
+		{"blackbox_http", "http://host01/health", "http://host01:443/health"},
+		{"mysqld", "host01:22", "host01:9104"},
+		{"blackbox_label", "host01/health", "http://host01.atl.example.com/health"},
+		{"unknown", "host01", "host01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.job+"/"+tt.addr, func(t *testing.T) {
+			jd := newJobDefaults(config, tt.job)
+			got := jd.rewrite(tt.addr, map[string]string{"datacenter": "atl"})
+			require.Equal(tt.want, got, "address did not match")
+		})
+	}
+}
+
+func TestJobsExportGroups(t *testing.T) {
+	require := require.New(t)
+	config := core.DefaultConfig()
+	config.Jobs = map[string]*core.JobConfig{
+		"node_exporter": {Port: 9100, Labels: map[string]string{"team": "ops", "environment": "dev"}},
+		"blackbox_http": {Scheme: "https"},
+	}
+
+	tgs := TargetGroups{{
+		Jobs:   []string{"node_exporter", "blackbox_http", "blackbox_icmp"},
+		Labels: map[string]string{"environment": "prod"},
+		Targets: []Target{
+			{Address: "host01"},
+			{Address: "host02", Labels: map[string]string{"team": "dba"}},
+		},
+	}}
+
+	want := JobMap{
+		"node_exporter": {
+			{
+				Labels:  map[string]string{"job": "node_exporter", "environment": "prod", "team": "ops"},
+				Targets: []string{"host01:9100"},
+			},
+			{
+				Labels:  map[string]string{"job": "node_exporter", "environment": "prod", "team": "dba"},
+				Targets: []string{"host02:9100"},
+			},
+		},
+		"blackbox_http": {
+			{
+				Labels:  map[string]string{"job": "blackbox_http", "environment": "prod"},
+				Targets: []string{"https://host01"},
+			},
+			{
+				Labels:  map[string]string{"job": "blackbox_http", "environment": "prod", "team": "dba"},
+				Targets: []string{"https://host02"},
+			},
+		},
+		"blackbox_icmp": {
+			{
+				Labels:  map[string]string{"job": "blackbox_icmp", "environment": "prod"},
+				Targets: []string{"host01"},
+			},
+			{
+				Labels:  map[string]string{"job": "blackbox_icmp", "environment": "prod", "team": "dba"},
+				Targets: []string{"host02"},
+			},
+		},
+	}
+	require.Equal(want, tgs.JobGroups(config), "job groups did not match")
+
+	files, err := tgs.splitByJob(config)
+	require.NoError(err, "splitByJob returned an unexpected error")
+	require.Equal(want["node_exporter"], files["node_exporter_targets.json"], "file groups did not match")
+}
+
+func TestJobsSelectors(t *testing.T) {
+	require := require.New(t)
+	config := core.DefaultConfig()
+	config.Jobs = map[string]*core.JobConfig{
+		"blackbox_ssh": {
+			Match:   map[string]string{"environment": "prod"},
+			Exclude: map[string]string{"os": "windows"},
+		},
+		"node_exporter": {Match: map[string]string{"os": "linux"}},
+	}
+
+	tgs := TargetGroups{
+		{
+			Jobs:   []string{"node_exporter"},
+			Labels: map[string]string{"environment": "prod", "os": "linux"},
+			Targets: []Target{
+				{Address: "web01"},
+				{Address: "win01", Labels: map[string]string{"os": "windows"}},
+			},
+		},
+		{
+			Labels:  map[string]string{"environment": "dev"},
+			Targets: []Target{{Address: "dev01"}},
+		},
+	}
+
+	want := JobMap{
+		"node_exporter": {
+			{
+				Labels:  map[string]string{"job": "node_exporter", "environment": "prod", "os": "linux"},
+				Targets: []string{"web01"},
+			},
+			{
+				Labels:  map[string]string{"job": "node_exporter", "environment": "prod", "os": "windows"},
+				Targets: []string{"win01"},
+			},
+		},
+		"blackbox_ssh": {{
+			Labels:  map[string]string{"job": "blackbox_ssh", "environment": "prod", "os": "linux"},
+			Targets: []string{"web01"},
+		}},
+	}
+	require.Equal(want, tgs.JobGroups(config), "job groups did not match")
+	require.Equal([]string{"node_exporter", "blackbox_ssh"}, tgs[0].jobs(config, make(jobDefaultsCache)))
+	require.Empty(tgs[1].jobs(config, make(jobDefaultsCache)), "unmatched group had jobs")
+
+	t.Run("TargetJobs", func(t *testing.T) {
+		require.Equal([]string{"node_exporter", "blackbox_ssh"}, tgs[0].TargetJobs(config, tgs[0].Targets[0]), "web01 jobs did not match")
+		require.Equal([]string{"node_exporter"}, tgs[0].TargetJobs(config, tgs[0].Targets[1]), "excluded target jobs did not match")
+		require.Empty(tgs[1].TargetJobs(config, tgs[1].Targets[0]), "unmatched target had jobs")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		content := `- labels:
+    environment: prod
+  targets:
+    - web01
+- labels:
+    environment: dev
+  targets:
+    - dev01
+`
+		want := Problems{{File: "targets.yml", Line: 5, Column: 3, Message: "group 1: has no jobs"}}
+		require.Equal(want, validateSource(config, "targets.yml", []byte(content)), "problems did not match")
+	})
+}
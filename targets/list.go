@@ -0,0 +1,162 @@
+package targets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+// listSidecarExt is added to the name of a list source to find its sidecar file, such as
+// webapp_targets.txt.yml.
+const listSidecarExt = ".yml"
+
+// listExts are the extensions of list sources.
+var listExts = []string{".txt", ".list"}
+
+// listSidecar holds the group settings of a list source.
+//
+//	priority: 10
+//	jobs:
+//	  - node
+//	labels:
+//	  application: webapp
+type listSidecar struct {
+	Priority int               `yaml:"priority"`
+	Jobs     []string          `yaml:"jobs"`
+	Labels   map[string]string `yaml:"labels"`
+}
+
+// isListFile returns true if file is a list source.
+func isListFile(file string) bool {
+	return slices.Contains(listExts, filepath.Ext(file))
+}
+
+// sidecarFile returns the sidecar file of a list source, or an empty string if file is not a list
+// source.
+func sidecarFile(file string) string {
+	if !isListFile(file) {
+		return ""
+	}
+
+	return file + listSidecarExt
+}
+
+// ReadListFile reads a list source with one target address per line. Blank lines and everything
+// after a # are skipped. The jobs and labels of the group come from the config list_files entry
+// for the file name and from its sidecar file, whose jobs replace the config jobs and whose labels
+// are merged over the config labels. A file without targets returns no groups.
+func ReadListFile(config *core.Config, file string) (TargetGroups, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tg, err := listGroup(config, file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", os.ErrInvalid, sidecarFile(file), err)
+	}
+
+	tg.Targets, err = parseList(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", os.ErrInvalid, file, err)
+	}
+
+	if len(tg.Targets) == 0 {
+		return TargetGroups{}, nil
+	}
+
+	return TargetGroups{tg}, nil
+}
+
+// listGroup returns the group for a list source without its targets.
+func listGroup(config *core.Config, file string) (*TargetGroup, error) {
+	tg := &TargetGroup{source: file}
+	labels := make(map[string]string)
+	if config != nil {
+		if lc := config.ListFiles[filepath.Base(file)]; lc != nil {
+			tg.Jobs = slices.Clone(lc.Jobs)
+			maps.Copy(labels, lc.Labels)
+		}
+	}
+
+	data, err := os.ReadFile(sidecarFile(file))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		var sc listSidecar
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&sc); err != nil {
+			return nil, err
+		}
+
+		tg.Priority = sc.Priority
+		if len(sc.Jobs) > 0 {
+			tg.Jobs = sc.Jobs
+		}
+
+		maps.Copy(labels, sc.Labels)
+	}
+
+	if len(labels) > 0 {
+		tg.Labels = labels
+	}
+
+	return tg, nil
+}
+
+// parseList returns the targets in data.
+func parseList(data []byte) ([]Target, error) {
+	targets := make([]Target, 0)
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.ContainsAny(line, " \t") {
+			return nil, newLineError(i+1, "%q must be a single target address", line)
+		}
+
+		targets = append(targets, Target{Address: line})
+	}
+
+	return targets, nil
+}
+
+// validateList lints a list source and its sidecar file. The group is checked like a target group.
+func validateList(config *core.Config, file string, data []byte) Problems {
+	tg, err := listGroup(config, file)
+	if err != nil {
+		var yerr *yaml.TypeError
+		if errors.As(err, &yerr) {
+			err = errors.New(strings.Join(yerr.Errors, "; "))
+		}
+
+		return Problems{{File: sidecarFile(file), Message: err.Error()}}
+	}
+
+	tg.Targets, err = parseList(data)
+	if err != nil {
+		return Problems{lineProblem(file, err)}
+	}
+
+	problems := make(Problems, 0)
+	for _, p := range tg.Validate(config) {
+		p.File = file
+		problems = append(problems, p)
+	}
+
+	return problems
+}
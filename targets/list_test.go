@@ -0,0 +1,127 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseList(t *testing.T) {
+	require := require.New(t)
+
+	got, err := parseList([]byte("# webapp hosts\natlwebapp01\n\n  atlwebapp02  # rack r12\nweb[03:04]\n"))
+	require.NoError(err, "parseList returned an unexpected error")
+	require.Equal(
+		[]Target{{Address: "atlwebapp01"}, {Address: "atlwebapp02"}, {Address: "web[03:04]"}},
+		got,
+		"targets did not match",
+	)
+
+	_, err = parseList([]byte("atlwebapp01\natlwebapp02 rack=r12\n"))
+	require.EqualError(err, `line 2: "atlwebapp02 rack=r12" must be a single target address`, "error did not match")
+}
+
+func TestReadListFile(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "list_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	file := filepath.Join(tempDir, "webapp_targets.txt")
+	err = os.WriteFile(file, []byte("atlwebapp01\natlwebapp02\n"), 0o644)
+	require.NoError(err, "failed to write %s", file)
+
+	config := core.DefaultConfig()
+	config.ListFiles = map[string]*core.ListFileConfig{
+		"webapp_targets.txt": {
+			Jobs:   []string{"node"},
+			Labels: map[string]string{"application": "webapp", "datacenter": "atl"},
+		},
+	}
+
+	t.Run("Config", func(t *testing.T) {
+		got, err := ReadListFile(config, file)
+		require.NoError(err, "ReadListFile returned an unexpected error")
+		require.Equal(
+			TargetGroups{&TargetGroup{
+				Jobs:    []string{"node"},
+				Labels:  map[string]string{"application": "webapp", "datacenter": "atl"},
+				Targets: []Target{{Address: "atlwebapp01"}, {Address: "atlwebapp02"}},
+				source:  file,
+			}},
+			got,
+			"groups did not match",
+		)
+	})
+
+	t.Run("Sidecar", func(t *testing.T) {
+		sidecar := file + listSidecarExt
+		err := os.WriteFile(sidecar, []byte("priority: 5\njobs: [blackbox_icmp]\nlabels:\n  datacenter: jfk\n"), 0o644)
+		require.NoError(err, "failed to write %s", sidecar)
+		defer os.Remove(sidecar)
+
+		got, err := ReadListFile(config, file)
+		require.NoError(err, "ReadListFile returned an unexpected error")
+		require.Equal(5, got[0].Priority, "priority did not match")
+		require.Equal([]string{"blackbox_icmp"}, got[0].Jobs, "jobs did not match")
+		require.Equal(map[string]string{"application": "webapp", "datacenter": "jfk"}, got[0].Labels, "labels did not match")
+
+		err = os.WriteFile(sidecar, []byte("targets: [host01]\n"), 0o644)
+		require.NoError(err, "failed to write %s", sidecar)
+
+		_, err = ReadListFile(config, file)
+		require.ErrorIs(err, os.ErrInvalid, "ReadListFile did not return the correct error")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		empty := filepath.Join(tempDir, "empty_targets.list")
+		err := os.WriteFile(empty, []byte("# nothing yet\n"), 0o644)
+		require.NoError(err, "failed to write %s", empty)
+
+		got, err := ReadListFile(config, empty)
+		require.NoError(err, "ReadListFile returned an unexpected error")
+		require.Empty(got, "groups were not empty")
+	})
+}
+
+func TestListSources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "list_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+
+	file := filepath.Join(tempDir, "webapp_targets.list")
+	err = os.WriteFile(file, []byte("atlwebapp01\natlwebapp01\n"), 0o644)
+	require.NoError(err, "failed to write %s", file)
+
+	sidecar := file + listSidecarExt
+	err = os.WriteFile(sidecar, []byte("jobs: [node]\n"), 0o644)
+	require.NoError(err, "failed to write %s", sidecar)
+
+	t.Run("Validate", func(t *testing.T) {
+		got, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Equal(Problems{{File: file, Message: `duplicate target "atlwebapp01"`}}, got, "problems did not match")
+
+		err = os.WriteFile(sidecar, []byte("jobs: node\n"), 0o644)
+		require.NoError(err, "failed to write %s", sidecar)
+
+		got, err = ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Len(got, 1, "wrong number of problems")
+		require.Equal(sidecar, got[0].File, "problem file did not match")
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		w := NewWatcher(config)
+		require.Contains(w.scan(), sidecar, "sidecar was not watched")
+	})
+}
@@ -0,0 +1,113 @@
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// ManifestFile is written to the targets dir and lists every targets file pim created there. It
+// does not use a .json or .yml extension so file_sd globs like *.json will not pick it up.
+const ManifestFile = ".pim_manifest"
+
+// Manifest records the targets files pim owns in the targets dir. Only files listed in the
+// manifest will ever be removed by pim.
+type Manifest struct {
+	Files []string `json:"files"`
+}
+
+// newManifest creates a Manifest from the file names in files.
+func newManifest(files TargetMap) *Manifest {
+	m := &Manifest{Files: make([]string, 0, len(files))}
+	for filename := range files {
+		m.Files = append(m.Files, filename)
+	}
+
+	slices.Sort(m.Files)
+	return m
+}
+
+// readManifest reads the manifest from dir. If no manifest exists an empty Manifest is returned.
+func readManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Files: make([]string, 0)}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+
+	return m, nil
+}
+
+// write saves the manifest to dir.
+func (m *Manifest) write(dir string) error {
+	return core.WriteJSON(filepath.Join(dir, ManifestFile), m, core.PermStdRead)
+}
+
+// union returns a new Manifest containing the files from both manifests.
+func (m *Manifest) union(other *Manifest) *Manifest {
+	u := &Manifest{Files: slices.Clone(m.Files)}
+	for _, f := range other.Files {
+		if !slices.Contains(u.Files, f) {
+			u.Files = append(u.Files, f)
+		}
+	}
+
+	slices.Sort(u.Files)
+	return u
+}
+
+// stale returns the files in m that are not in current.
+func (m *Manifest) stale(current *Manifest) []string {
+	files := make([]string, 0)
+	for _, f := range m.Files {
+		if !slices.Contains(current.Files, f) {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+// isManagedName returns true if f is a plain file name that pim could have written.
+func isManagedName(f string) bool {
+	return f == filepath.Base(f) && f != "." && f != ".." && f != ManifestFile
+}
+
+// removeStaleTargets removes the files listed in the old manifest that are no longer generated.
+// Names that are not plain file names are skipped so a modified manifest can not be used to
+// remove files outside of dir. Returns the names of the removed files. Files that are already gone
+// are not included.
+func removeStaleTargets(dir string, old, current *Manifest) ([]string, error) {
+	removed := make([]string, 0)
+	for _, f := range old.stale(current) {
+		if !isManagedName(f) {
+			continue
+		}
+
+		err := core.RemoveFile(filepath.Join(dir, f))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, f)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,125 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestRead(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "manifest_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	t.Run("Missing", func(t *testing.T) {
+		m, err := readManifest(tempDir)
+		require.NoError(err, "readManifest returned an unexpected error")
+		require.Empty(m.Files, "manifest was not empty")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := os.WriteFile(filepath.Join(tempDir, ManifestFile), []byte("not json"), 0o644)
+		require.NoError(err, "failed to write manifest")
+
+		m, err := readManifest(tempDir)
+		require.Error(err, "readManifest did not return an error")
+		require.Nil(m, "manifest was not nil")
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		want := &Manifest{Files: []string{"a_targets.json", "b_targets.json"}}
+		err := want.write(tempDir)
+		require.NoError(err, "failed to write manifest")
+
+		m, err := readManifest(tempDir)
+		require.NoError(err, "readManifest returned an unexpected error")
+		require.Equal(want, m, "manifest did not match")
+	})
+}
+
+func TestManifestStale(t *testing.T) {
+	require := require.New(t)
+	old := &Manifest{Files: []string{"a_targets.json", "b_targets.json"}}
+	current := &Manifest{Files: []string{"b_targets.json", "c_targets.json"}}
+
+	require.Equal([]string{"a_targets.json"}, old.stale(current), "stale files did not match")
+	require.Equal(
+		&Manifest{Files: []string{"a_targets.json", "b_targets.json", "c_targets.json"}},
+		old.union(current),
+		"union did not match",
+	)
+}
+
+func TestRemoveStaleTargets(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "manifest_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	for _, f := range []string{"a_targets.json", "b_targets.json", "mine.json"} {
+		err := os.WriteFile(filepath.Join(tempDir, f), []byte("[]"), 0o644)
+		require.NoError(err, "failed to write %s", f)
+	}
+
+	old := &Manifest{Files: []string{"a_targets.json", "b_targets.json", "../mine.json", "gone.json"}}
+	current := &Manifest{Files: []string{"b_targets.json"}}
+
+	removed, err := removeStaleTargets(tempDir, old, current)
+	require.NoError(err, "removeStaleTargets returned an unexpected error")
+	require.Equal([]string{"a_targets.json"}, removed, "removed files did not match")
+	require.NoFileExists(filepath.Join(tempDir, "a_targets.json"), "stale file was not removed")
+	require.FileExists(filepath.Join(tempDir, "b_targets.json"), "current file was removed")
+	require.FileExists(filepath.Join(tempDir, "mine.json"), "unmanaged file was removed")
+}
+
+func TestExportTargetsCleanup(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "manifest_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	// A file pim did not create should never be touched.
+	unmanaged := filepath.Join(tempDir, "static_targets.json")
+	err = os.WriteFile(unmanaged, []byte("[]"), 0o644)
+	require.NoError(err, "failed to write unmanaged file")
+
+	config := core.DefaultConfig()
+	config.TargetsDir = tempDir
+
+	_, err = expectedTargetGroups.ExportTargets(config)
+	require.NoError(err, "failed to export targets")
+	for _, f := range []string{"blackbox_icmp_targets.json", "node-exporter_targets.json", "mysql-exporter_targets.json"} {
+		require.FileExists(filepath.Join(tempDir, f), "targets file was not created")
+	}
+
+	// Remove the mysql-exporter job from the sources and export again.
+	tgs := TargetGroups{
+		expectedTargetGroups[0],
+		&TargetGroup{
+			Jobs:    []string{"node-exporter"},
+			Labels:  expectedTargetGroups[1].Labels,
+			Targets: expectedTargetGroups[1].Targets,
+		},
+	}
+	results, err := tgs.ExportTargets(config)
+	require.NoError(err, "failed to export targets")
+	require.Equal(ExportResults{
+		{File: "blackbox_icmp_targets.json", Status: FileUnchanged, Targets: 2, Groups: 1},
+		{File: "mysql-exporter_targets.json", Status: FileRemoved},
+		{File: "node-exporter_targets.json", Status: FileUnchanged, Targets: 2, Groups: 1},
+	}, results, "results did not match")
+	require.NoFileExists(filepath.Join(tempDir, "mysql-exporter_targets.json"), "stale file was not removed")
+	require.FileExists(filepath.Join(tempDir, "node-exporter_targets.json"), "targets file was removed")
+	require.FileExists(unmanaged, "unmanaged file was removed")
+
+	m, err := readManifest(tempDir)
+	require.NoError(err, "failed to read manifest")
+	require.Equal([]string{"blackbox_icmp_targets.json", "node-exporter_targets.json"}, m.Files)
+}
@@ -0,0 +1,230 @@
+package targets
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// mergeEntry is a single occurrence of a target in a group for a job.
+type mergeEntry struct {
+	group  *TargetGroup
+	labels map[string]string
+}
+
+// mergedTarget is every occurrence of an address for a job.
+type mergedTarget struct {
+	address string
+	entries []mergeEntry
+}
+
+// mergedJob holds the targets of a job in the order they are first seen.
+type mergedJob struct {
+	job     string
+	targets []*mergedTarget
+	byAddr  map[string]*mergedTarget
+}
+
+// mergeJobs builds the targets of every job from every group and consolidates them by address.
+// Jobs are returned in the order they are first seen.
+func (t TargetGroups) mergeJobs(config *core.Config) []*mergedJob {
+	jobs := make([]*mergedJob, 0)
+	byJob := make(map[string]*mergedJob)
+	defaults := make(jobDefaultsCache)
+	for _, tg := range t {
+		for _, job := range tg.jobs(config, defaults) {
+			mj, ok := byJob[job]
+			if !ok {
+				mj = &mergedJob{job: job, byAddr: make(map[string]*mergedTarget)}
+				byJob[job] = mj
+				jobs = append(jobs, mj)
+			}
+
+			for _, eg := range tg.exportGroups(defaults.get(config, job)) {
+				for _, addr := range eg.Targets {
+					mt, ok := mj.byAddr[addr]
+					if !ok {
+						mt = &mergedTarget{address: addr}
+						mj.byAddr[addr] = mt
+						mj.targets = append(mj.targets, mt)
+					}
+
+					mt.entries = append(mt.entries, mergeEntry{group: tg, labels: eg.Labels})
+				}
+			}
+		}
+	}
+
+	return jobs
+}
+
+// labels merges the labels of every entry. Entries with a higher precedence are merged last so
+// their values win.
+func (mt *mergedTarget) labels(precedence string) map[string]string {
+	entries := mt.entries
+	if precedence == core.MergePriority {
+		entries = slices.Clone(entries)
+		slices.SortStableFunc(entries, func(a, b mergeEntry) int {
+			return a.group.Priority - b.group.Priority
+		})
+	}
+
+	merged := make(map[string]string)
+	for _, e := range entries {
+		maps.Copy(merged, e.labels)
+	}
+
+	return merged
+}
+
+// conflicts returns the names of the labels the entries set to different values.
+func (mt *mergedTarget) conflicts() []string {
+	values := make(map[string]string)
+	names := make([]string, 0)
+	for _, e := range mt.entries {
+		for k, v := range e.labels {
+			if old, ok := values[k]; ok && old != v && !slices.Contains(names, k) {
+				names = append(names, k)
+			}
+
+			values[k] = v
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// conflict returns the Conflict for the target in job, or nil if its entries agree.
+func (mt *mergedTarget) conflict(job string) *Conflict {
+	names := mt.conflicts()
+	if len(names) == 0 {
+		return nil
+	}
+
+	c := &Conflict{Job: job, Address: mt.address, Labels: names, Groups: make([]ConflictGroup, 0)}
+	for _, e := range mt.entries {
+		labels := make(map[string]string)
+		for _, name := range names {
+			if v, ok := e.labels[name]; ok {
+				labels[name] = v
+			}
+		}
+
+		if len(labels) > 0 {
+			c.Groups = append(c.Groups, ConflictGroup{
+				Source: e.group.origin,
+				File:   e.group.source,
+				Group:  e.group.index,
+				Labels: labels,
+			})
+		}
+	}
+
+	return c
+}
+
+// exportGroups collapses the merged targets into one ExportGroup per label set, in the order the
+// label sets are first seen.
+func (mj *mergedJob) exportGroups(precedence string) ExportGroups {
+	egs := make(ExportGroups, 0)
+	groups := make(map[string]*ExportGroup)
+	for _, mt := range mj.targets {
+		labels := mt.labels(precedence)
+		key := labelsKey(labels)
+		eg, ok := groups[key]
+		if !ok {
+			eg = &ExportGroup{Labels: labels, Targets: make([]string, 0)}
+			groups[key] = eg
+			egs = append(egs, eg)
+		}
+
+		eg.Targets = append(eg.Targets, mt.address)
+	}
+
+	return egs
+}
+
+// Conflict is a target exported for a job by more than one group with different values for the
+// same labels. Prometheus would scrape it once per label set.
+type Conflict struct {
+	Job     string `json:"job" yaml:"job"`
+	Address string `json:"address" yaml:"address"`
+	// Labels are the names of the labels the groups disagree on.
+	Labels []string `json:"labels" yaml:"labels"`
+	// Groups are the groups that set the labels, in source order.
+	Groups []ConflictGroup `json:"groups" yaml:"groups"`
+}
+
+// ConflictGroup is a group a conflicting target is in and the values it sets for the conflicting
+// labels. File is empty for groups that were not read from a source file and Source is the name of
+// the source in the config sources list the group was read from.
+type ConflictGroup struct {
+	Source string            `json:"source,omitempty" yaml:"source,omitempty"`
+	File   string            `json:"file,omitempty" yaml:"file,omitempty"`
+	Group  int               `json:"group" yaml:"group"`
+	Labels map[string]string `json:"labels" yaml:"labels"`
+}
+
+// Conflicts lists every Conflict found in the sources.
+type Conflicts []*Conflict
+
+func (c *Conflict) String() string {
+	groups := make([]string, len(c.Groups))
+	for i, g := range c.Groups {
+		groups[i] = fmt.Sprintf("group %d {%s}", g.Group, labelsKey(g.Labels))
+		if g.File != "" {
+			groups[i] = g.File + " " + groups[i]
+		}
+
+		if g.Source != "" {
+			groups[i] = "(" + g.Source + ") " + groups[i]
+		}
+	}
+
+	return fmt.Sprintf(
+		"job %s target %q has conflicting labels %s: %s",
+		c.Job,
+		c.Address,
+		strings.Join(c.Labels, ", "),
+		strings.Join(groups, ", "),
+	)
+}
+
+// Conflicts returns every target that is in more than one group for a job with different label
+// values, in the order the jobs and targets are first seen.
+func (t TargetGroups) Conflicts(config *core.Config) Conflicts {
+	conflicts := make(Conflicts, 0)
+	for _, mj := range t.mergeJobs(config) {
+		for _, mt := range mj.targets {
+			if c := mt.conflict(mj.job); c != nil {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// checkMerge returns an error listing every Conflict when merge_precedence is error.
+func (t TargetGroups) checkMerge(config *core.Config) error {
+	if config.MergePrecedence != core.MergeError {
+		return nil
+	}
+
+	conflicts := t.Conflicts(config)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		msgs[i] = c.String()
+	}
+
+	return fmt.Errorf("%w: %d targets have conflicting labels: %s", os.ErrInvalid, len(conflicts), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,154 @@
+package targets
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func newMergeTargetGroups() TargetGroups {
+	return TargetGroups{
+		&TargetGroup{
+			Priority: 10,
+			Jobs:     []string{"node"},
+			Labels:   map[string]string{"environment": "prod", "owner": "web"},
+			Targets:  []Target{{Address: "host01"}, {Address: "host02"}},
+		},
+		&TargetGroup{
+			Jobs:    []string{"node"},
+			Labels:  map[string]string{"environment": "stg", "rack": "r12"},
+			Targets: []Target{{Address: "host02"}, {Address: "host03"}},
+		},
+	}
+}
+
+func TestMergeJobs(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Order", func(t *testing.T) {
+		config := core.DefaultConfig()
+		got := newMergeTargetGroups().JobGroups(config)
+		want := ExportGroups{
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "prod", "owner": "web"},
+				Targets: []string{"host01"},
+			},
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "stg", "owner": "web", "rack": "r12"},
+				Targets: []string{"host02"},
+			},
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "stg", "rack": "r12"},
+				Targets: []string{"host03"},
+			},
+		}
+		require.Equal(want, got["node"], "merged groups did not match")
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		config := core.DefaultConfig()
+		config.MergePrecedence = core.MergePriority
+		got := newMergeTargetGroups().JobGroups(config)
+		require.Len(got["node"], 3, "wrong number of groups")
+		require.Equal(
+			map[string]string{"job": "node", "environment": "prod", "owner": "web", "rack": "r12"},
+			got["node"][1].Labels,
+			"merged labels did not match",
+		)
+		require.Equal([]string{"host02"}, got["node"][1].Targets, "merged targets did not match")
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		tgs := TargetGroups{
+			&TargetGroup{Jobs: []string{"node"}, Labels: map[string]string{"environment": "prod"}, Targets: []Target{{Address: "host01"}}},
+			&TargetGroup{Jobs: []string{"node"}, Labels: map[string]string{"environment": "prod"}, Targets: []Target{{Address: "host01"}, {Address: "host02"}}},
+		}
+
+		got, err := tgs.splitByJob(core.DefaultConfig())
+		require.NoError(err, "splitByJob returned an unexpected error")
+		want := TargetMap{
+			"node_targets.json": {
+				&ExportGroup{
+					Labels:  map[string]string{"job": "node", "environment": "prod"},
+					Targets: []string{"host01", "host02"},
+				},
+			},
+		}
+		require.Equal(want, got, "merged files did not match")
+	})
+
+	t.Run("SeparateJobs", func(t *testing.T) {
+		tgs := TargetGroups{
+			&TargetGroup{Jobs: []string{"node"}, Labels: map[string]string{"environment": "prod"}, Targets: []Target{{Address: "host01"}}},
+			&TargetGroup{Jobs: []string{"mysql"}, Labels: map[string]string{"environment": "stg"}, Targets: []Target{{Address: "host01"}}},
+		}
+
+		got := tgs.JobGroups(core.DefaultConfig())
+		require.Equal("prod", got["node"][0].Labels["environment"], "node labels did not match")
+		require.Equal("stg", got["mysql"][0].Labels["environment"], "mysql labels did not match")
+	})
+}
+
+func TestMergedTargetConflicts(t *testing.T) {
+	require := require.New(t)
+
+	mt := &mergedTarget{
+		address: "host01",
+		entries: []mergeEntry{
+			{labels: map[string]string{"job": "node", "environment": "prod", "rack": "r01"}},
+			{labels: map[string]string{"job": "node", "environment": "prod", "rack": "r12"}},
+			{labels: map[string]string{"job": "node", "environment": "stg", "rack": "r12"}},
+		},
+	}
+	require.Equal([]string{"environment", "rack"}, mt.conflicts(), "conflicts did not match")
+}
+
+func TestTargetGroupsConflicts(t *testing.T) {
+	require := require.New(t)
+
+	tgs := newMergeTargetGroups()
+	tgs[0].source, tgs[0].index = "a_targets.yml", 0
+	tgs[1].source, tgs[1].index = "b_targets.yml", 3
+
+	want := Conflicts{
+		&Conflict{
+			Job:     "node",
+			Address: "host02",
+			Labels:  []string{"environment"},
+			Groups: []ConflictGroup{
+				{File: "a_targets.yml", Group: 0, Labels: map[string]string{"environment": "prod"}},
+				{File: "b_targets.yml", Group: 3, Labels: map[string]string{"environment": "stg"}},
+			},
+		},
+	}
+	got := tgs.Conflicts(core.DefaultConfig())
+	require.Equal(want, got, "conflicts did not match")
+	require.Equal(
+		`job node target "host02" has conflicting labels environment: `+
+			`a_targets.yml group 0 {environment="prod"}, b_targets.yml group 3 {environment="stg"}`,
+		got[0].String(),
+		"conflict string did not match",
+	)
+
+	require.Empty(expectedTargetGroups.Conflicts(core.DefaultConfig()), "conflicts were found")
+}
+
+func TestCheckMerge(t *testing.T) {
+	require := require.New(t)
+
+	config := core.DefaultConfig()
+	require.NoError(newMergeTargetGroups().checkMerge(config), "checkMerge returned an error for order")
+
+	config.MergePrecedence = core.MergeError
+	err := newMergeTargetGroups().checkMerge(config)
+	require.ErrorIs(err, os.ErrInvalid, "checkMerge did not return the correct error")
+	require.ErrorContains(err, `1 targets have conflicting labels: job node target "host02"`, "checkMerge error did not match")
+
+	tgs := TargetGroups{
+		&TargetGroup{Jobs: []string{"node"}, Labels: map[string]string{"environment": "prod"}, Targets: []Target{{Address: "host01"}}},
+		&TargetGroup{Jobs: []string{"node"}, Labels: map[string]string{"rack": "r12"}, Targets: []Target{{Address: "host01"}}},
+	}
+	require.NoError(tgs.checkMerge(config), "checkMerge returned an error for labels that do not conflict")
+}
@@ -0,0 +1,167 @@
+package targets
+
+import (
+	"time"
+
+	"github.com/chadeldridge/prometheus-import-manager/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exportsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "pim_exports_total", Help: "Total number of exports by result."},
+		[]string{"result"},
+	)
+	exportDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pim_export_duration_seconds",
+		Help:    "Export duration in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+	exportLastTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pim_export_last_timestamp_seconds",
+		Help: "Unix time the last export finished.",
+	})
+	exportLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pim_export_last_success",
+		Help: "1 if the last export succeeded, 0 if it failed.",
+	})
+	jobTargets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "pim_job_targets", Help: "Number of targets per job in the last loaded sources."},
+		[]string{"job"},
+	)
+	jobGroups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "pim_job_groups", Help: "Number of target groups per job in the last loaded sources."},
+		[]string{"job"},
+	)
+	jobConflicts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pim_job_conflicts",
+			Help: "Number of targets with conflicting labels per job in the last loaded sources.",
+		},
+		[]string{"job"},
+	)
+	fileTargets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pim_file_targets",
+			Help: "Number of targets per file_sd targets file in the last loaded sources.",
+		},
+		[]string{"file"},
+	)
+	fileGroups = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pim_file_groups",
+			Help: "Number of target groups per file_sd targets file in the last loaded sources.",
+		},
+		[]string{"file"},
+	)
+	remoteFetches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "pim_remote_source_fetches_total",
+			Help: "Total number of remote sources fetches by result.",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	metrics.Default.MustRegister(
+		exportsTotal,
+		exportDuration,
+		exportLastTimestamp,
+		exportLastSuccess,
+		jobTargets,
+		jobGroups,
+		jobConflicts,
+		fileTargets,
+		fileGroups,
+		remoteFetches,
+	)
+}
+
+// GroupCount is the number of targets and export groups of a job or targets file.
+type GroupCount struct {
+	Targets int
+	Groups  int
+}
+
+// ExportStats are the counts of the loaded sources recorded by RecordExport. They are taken from
+// what the export already built so recording them does not merge the sources again.
+type ExportStats struct {
+	Jobs      map[string]GroupCount
+	Files     map[string]GroupCount
+	Conflicts map[string]int
+}
+
+// NewExportStats counts the http_sd jobs, the conflicts and the targets files written by an
+// export. results may be nil if file_sd is not enabled.
+func NewExportStats(jobs JobMap, conflicts Conflicts, results ExportResults) *ExportStats {
+	stats := &ExportStats{
+		Jobs:      make(map[string]GroupCount, len(jobs)),
+		Files:     make(map[string]GroupCount, len(results)),
+		Conflicts: make(map[string]int),
+	}
+
+	for job, egs := range jobs {
+		stats.Jobs[job] = GroupCount{Targets: countTargets(egs), Groups: len(egs)}
+	}
+
+	for _, c := range conflicts {
+		stats.Conflicts[c.Job]++
+	}
+
+	for _, r := range results {
+		if r.Status != FileRemoved {
+			stats.Files[r.File] = GroupCount{Targets: r.Targets, Groups: r.Groups}
+		}
+	}
+
+	return stats
+}
+
+// RecordExport records the metrics for an export that took d. stats are nil if the sources failed
+// to load, in which case the per job and per file metrics keep the values from the last load.
+func RecordExport(stats *ExportStats, d time.Duration, err error) {
+	result := "success"
+	success := 1.0
+	if err != nil {
+		result = "failure"
+		success = 0
+	}
+
+	exportsTotal.WithLabelValues(result).Inc()
+	exportDuration.Observe(d.Seconds())
+	exportLastTimestamp.Set(float64(time.Now().UnixNano()) / float64(time.Second))
+	exportLastSuccess.Set(success)
+
+	if stats == nil {
+		return
+	}
+
+	jobTargets.Reset()
+	jobGroups.Reset()
+	for job, c := range stats.Jobs {
+		jobTargets.WithLabelValues(job).Set(float64(c.Targets))
+		jobGroups.WithLabelValues(job).Set(float64(c.Groups))
+	}
+
+	jobConflicts.Reset()
+	for job, count := range stats.Conflicts {
+		jobConflicts.WithLabelValues(job).Set(float64(count))
+	}
+
+	fileTargets.Reset()
+	fileGroups.Reset()
+	for file, c := range stats.Files {
+		fileTargets.WithLabelValues(file).Set(float64(c.Targets))
+		fileGroups.WithLabelValues(file).Set(float64(c.Groups))
+	}
+}
+
+func countTargets(egs ExportGroups) int {
+	count := 0
+	for _, eg := range egs {
+		count += len(eg.Targets)
+	}
+
+	return count
+}
@@ -0,0 +1,74 @@
+package targets
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/chadeldridge/prometheus-import-manager/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordExport(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "metrics_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.ExportTypes[core.ExportTypeFileSD] = true
+	config.TargetsDir = tempDir
+
+	// stats exports tgs the way pim export does and returns its counts.
+	stats := func(tgs TargetGroups) *ExportStats {
+		results, err := tgs.ExportTargets(config)
+		require.NoError(err, "ExportTargets returned an unexpected error")
+		return NewExportStats(tgs.JobGroups(config), tgs.Conflicts(config), results)
+	}
+
+	write := func() string {
+		w := httptest.NewRecorder()
+		metrics.Handler(metrics.Default).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return w.Body.String()
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		RecordExport(stats(expectedTargetGroups), 10*time.Millisecond, nil)
+		out := write()
+		require.Contains(out, "pim_export_last_success 1")
+		require.Contains(out, `pim_job_targets{job="node-exporter"} 2`)
+		require.Contains(out, `pim_job_groups{job="blackbox_icmp"} 1`)
+		require.Contains(out, `pim_file_targets{file="mysql-exporter_targets.json"} 2`)
+		require.Contains(out, `pim_file_groups{file="node-exporter_targets.json"} 1`)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		RecordExport(nil, time.Millisecond, errors.New("bad sources"))
+		out := write()
+		require.Contains(out, "pim_export_last_success 0")
+		require.Contains(out, `pim_exports_total{result="failure"}`)
+		// The last loaded counts are kept when the sources fail to load.
+		require.Contains(out, `pim_job_targets{job="node-exporter"} 2`)
+	})
+
+	t.Run("RemovedJob", func(t *testing.T) {
+		RecordExport(stats(TargetGroups{expectedTargetGroups[0]}), time.Millisecond, nil)
+		out := write()
+		require.NotContains(out, `pim_job_targets{job="node-exporter"}`)
+		require.NotContains(out, `pim_file_targets{file="node-exporter_targets.json"}`)
+		require.Contains(out, `pim_job_targets{job="blackbox_icmp"} 2`)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		RecordExport(stats(newMergeTargetGroups()), time.Millisecond, nil)
+		require.Contains(write(), `pim_job_conflicts{job="node"} 1`)
+
+		RecordExport(stats(expectedTargetGroups), time.Millisecond, nil)
+		require.NotContains(write(), `pim_job_conflicts{job="node"}`)
+	})
+}
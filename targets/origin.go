@@ -0,0 +1,135 @@
+package targets
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// Origin returns the name of the source in the config sources list the group was read from. It
+// is empty if the config has no sources list.
+func (tg *TargetGroup) Origin() string {
+	return tg.origin
+}
+
+// SourceName returns the name of the source in the config sources list that file was found in.
+// It is empty if the config has no sources list or file is not in any of the sources.
+func SourceName(config *core.Config, file string) string {
+	if src := sourceOf(config, file); src != nil {
+		return src.Name
+	}
+
+	return ""
+}
+
+// sourceConfig returns the config used to find and read the files of src.
+func sourceConfig(config *core.Config, src *core.SourceConfig) *core.Config {
+	sc := *config
+	sc.Sources = src.Path
+	sc.SourceList = nil
+	if src.Format != "" {
+		sc.SourceFormat = src.Format
+	}
+
+	return &sc
+}
+
+// sourceOf returns the enabled source in the config sources list that file was found in. nil is
+// returned if there is no sources list or file is not in any of the sources.
+func sourceOf(config *core.Config, file string) *core.SourceConfig {
+	if config == nil || file == "" {
+		return nil
+	}
+
+	file = filepath.Clean(file)
+	for _, src := range config.EnabledSources() {
+		if IsRemote(src.Path) {
+			base := filepath.Join(config.SourcesCacheDir, remoteCacheName(src.Path))
+			if strings.TrimSuffix(file, filepath.Ext(file)) == filepath.Clean(base) {
+				return src
+			}
+
+			continue
+		}
+
+		path := filepath.Clean(src.Path)
+		if file == path || filepath.Dir(file) == path {
+			return src
+		}
+	}
+
+	return nil
+}
+
+// fileConfig returns the config used to read file. Files from a source in the config sources
+// list use the source format.
+func fileConfig(config *core.Config, file string) *core.Config {
+	if src := sourceOf(config, file); src != nil {
+		return sourceConfig(config, src)
+	}
+
+	return config
+}
+
+// findFiles returns the source files of every enabled source in the config sources list, in list
+// order, or the files in config.Sources if there is no list.
+func findFiles(config *core.Config) ([]string, error) {
+	return findFilesWith(config, findSourceFiles)
+}
+
+// findFilesWith is findFiles using find to look up the files of each source.
+func findFilesWith(config *core.Config, find func(*core.Config) ([]string, error)) ([]string, error) {
+	if len(config.SourceList) == 0 {
+		return find(config)
+	}
+
+	files := make([]string, 0)
+	for _, src := range config.EnabledSources() {
+		f, err := find(sourceConfig(config, src))
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", src.Name, err)
+		}
+
+		files = append(files, f...)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: every source is disabled", os.ErrNotExist)
+	}
+
+	return files, nil
+}
+
+// applySource sets the origin of tg to the source it was read from, adds the source priority and
+// merges the group labels over the source labels.
+func (tg *TargetGroup) applySource(config *core.Config) {
+	src := sourceOf(config, tg.source)
+	if src == nil {
+		return
+	}
+
+	tg.origin = src.Name
+	tg.Priority += src.Priority
+	if len(src.Labels) > 0 {
+		labels := maps.Clone(src.Labels)
+		maps.Copy(labels, tg.Labels)
+		tg.Labels = labels
+	}
+}
+
+// WithSources returns copies of the groups with the origin, priority and labels of the source in
+// the config sources list each one was read from, as they are exported.
+func (t TargetGroups) WithSources(config *core.Config) TargetGroups {
+	tgs := make(TargetGroups, 0, len(t))
+	for _, tg := range t {
+		c := *tg
+		c.applySource(config)
+		tgs = append(tgs, &c)
+	}
+
+	return tgs
+}
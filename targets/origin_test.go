@@ -0,0 +1,113 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceList(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "origin_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	for _, dir := range []string{"local", "cmdb", "old"} {
+		require.NoError(os.Mkdir(filepath.Join(tempDir, dir), 0o755), "failed to create %s", dir)
+	}
+
+	writeTestFiles(t, filepath.Join(tempDir, "local"), map[string]string{
+		"targets.yml": "- jobs: [node]\n  labels: {rack: r01}\n  targets: [host01, host02]\n",
+	})
+	writeTestFiles(t, filepath.Join(tempDir, "cmdb"), map[string]string{
+		"a_targets.yml": "- jobs: [node]\n  labels: {rack: r12}\n  targets: [host01]\n",
+	})
+	writeTestFiles(t, filepath.Join(tempDir, "old"), map[string]string{
+		"targets.yml": "- jobs: [node]\n  targets: [host99]\n",
+	})
+	inventory := filepath.Join(tempDir, "hosts.yml")
+	writeTestFiles(t, tempDir, map[string]string{"hosts.yml": "webservers:\n  hosts:\n    web01:\n"})
+
+	disabled := false
+	config := core.DefaultConfig()
+	config.MergePrecedence = core.MergePriority
+	config.Ansible = core.AnsibleConfig{Groups: []*core.AnsibleGroup{{Group: "webservers", Jobs: []string{"node"}}}}
+	config.SourceList = []*core.SourceConfig{
+		{Name: "local", Path: filepath.Join(tempDir, "local"), Priority: 10},
+		{Name: "cmdb", Path: filepath.Join(tempDir, "cmdb"), Labels: map[string]string{"source": "cmdb", "rack": "r00"}},
+		{Name: "ansible", Path: inventory, Format: core.SourceFormatAnsible},
+		{Name: "old", Path: filepath.Join(tempDir, "old"), Enabled: &disabled},
+	}
+
+	t.Run("NewTargetGroups", func(t *testing.T) {
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 3, "wrong number of groups")
+
+		require.Equal("local", tgs[0].Origin(), "origin did not match")
+		require.Equal(10, tgs[0].Priority, "source priority was not added")
+		require.Equal("cmdb", tgs[1].Origin(), "origin did not match")
+		require.Equal(map[string]string{"source": "cmdb", "rack": "r12"}, tgs[1].Labels, "labels did not match")
+		require.Equal("ansible", tgs[2].Origin(), "origin did not match")
+		require.Equal("web01", tgs[2].Targets[0].Address, "ansible format was not used")
+
+		// local has the higher priority so its rack wins over cmdb. The cmdb source label is kept.
+		jobs := tgs.JobGroups(config)
+		require.Len(jobs["node"], 3, "wrong number of node groups")
+		require.Equal([]string{"host01"}, jobs["node"][0].Targets, "targets did not match")
+		require.Equal(
+			map[string]string{"job": "node", "rack": "r01", "source": "cmdb"},
+			jobs["node"][0].Labels,
+			"labels did not match",
+		)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+
+		conflicts := tgs.Conflicts(config)
+		require.Len(conflicts, 1, "wrong number of conflicts")
+		require.Equal("local", conflicts[0].Groups[0].Source, "conflict source did not match")
+		require.Contains(conflicts[0].String(), "(cmdb) "+filepath.Join(tempDir, "cmdb", "a_targets.yml"))
+	})
+
+	t.Run("Files", func(t *testing.T) {
+		files, err := SourceFiles(config)
+		require.NoError(err, "SourceFiles returned an unexpected error")
+		require.Equal([]string{
+			filepath.Join(tempDir, "local", "targets.yml"),
+			filepath.Join(tempDir, "cmdb", "a_targets.yml"),
+			inventory,
+		}, files, "files did not match")
+
+		require.True(IsWritable(config, files[0]), "pim source was not writable")
+		require.False(IsWritable(config, inventory), "ansible source was writable")
+
+		got, err := ReadSource(config, files[1])
+		require.NoError(err, "ReadSource returned an unexpected error")
+		require.Equal(map[string]string{"rack": "r12"}, got[0].Labels, "ReadSource applied the source labels")
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		problems, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Equal(0, problems.Errors(), "valid sources had errors")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		config := core.DefaultConfig()
+		config.SourceList = []*core.SourceConfig{{Name: "gone", Path: filepath.Join(tempDir, "gone")}}
+		_, err := NewTargetGroups(config)
+		require.ErrorIs(err, os.ErrNotExist, "NewTargetGroups did not return the correct error")
+		require.ErrorContains(err, "source gone:", "error did not name the source")
+
+		config.SourceList[0].Enabled = &disabled
+		_, err = SourceFiles(config)
+		require.NoError(err, "SourceFiles returned an unexpected error")
+	})
+}
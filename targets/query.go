@@ -0,0 +1,258 @@
+package targets
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// Matcher types. They work the same way as Prometheus label matchers.
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// matcherRE splits a matcher into its label name, type and value.
+var matcherRE = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// Matcher selects targets by the value of a label. A missing label has the value "". The target
+// address can be matched with __address__.
+type Matcher struct {
+	Name  string
+	Type  string
+	Value string
+	re    *regexp.Regexp
+}
+
+// ParseMatchers reads the matchers in each of args. An arg can be a single matcher such as
+// environment="prod", or a comma separated list in braces such as {job="node",rack=~"r0.*"}.
+// Values may be unquoted.
+func ParseMatchers(args ...string) ([]*Matcher, error) {
+	matchers := make([]*Matcher, 0)
+	for _, arg := range args {
+		s := strings.TrimSpace(arg)
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			s = s[1 : len(s)-1]
+		}
+
+		parts, err := splitMatchers(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: matcher %s: %w", os.ErrInvalid, arg, err)
+		}
+
+		for _, p := range parts {
+			m, err := parseMatcher(p)
+			if err != nil {
+				return nil, fmt.Errorf("%w: matcher %s: %w", os.ErrInvalid, arg, err)
+			}
+
+			matchers = append(matchers, m)
+		}
+	}
+
+	return matchers, nil
+}
+
+// splitMatchers splits s on the commas that are not in a quoted value. Empty parts are skipped.
+func splitMatchers(s string) ([]string, error) {
+	parts := make([]string, 0)
+	var quote rune
+	start := 0
+	escaped := false
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quoted value")
+	}
+
+	parts = append(parts, s[start:])
+	return slices.DeleteFunc(parts, func(p string) bool { return strings.TrimSpace(p) == "" }), nil
+}
+
+func parseMatcher(s string) (*Matcher, error) {
+	match := matcherRE.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("must be <label><op><value> with op one of =, !=, =~, !~: %s", strings.TrimSpace(s))
+	}
+
+	m := &Matcher{Name: match[1], Type: match[2], Value: match[3]}
+	switch {
+	case len(m.Value) >= 2 && m.Value[0] == '\'' && m.Value[len(m.Value)-1] == '\'':
+		m.Value = strings.ReplaceAll(m.Value[1:len(m.Value)-1], `\'`, `'`)
+	case len(m.Value) >= 2 && m.Value[0] == '"' && m.Value[len(m.Value)-1] == '"':
+		v, err := strconv.Unquote(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted value: %s", match[3])
+		}
+
+		m.Value = v
+	}
+
+	if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+		// Like Prometheus, regexes match the whole value.
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+
+		m.re = re
+	}
+
+	return m, nil
+}
+
+// Matches returns true if v satisfies the matcher.
+func (m *Matcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	default:
+		return v == m.Value
+	}
+}
+
+// String returns the matcher the way Prometheus writes it.
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// QueryTarget is an exported target with the labels and targets file it is exported with.
+type QueryTarget struct {
+	Job     string            `json:"job" yaml:"job"`
+	Address string            `json:"target" yaml:"target"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+	// File is the file_sd targets file the target is written to after target_split.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+}
+
+// QueryTargets is a list of QueryTarget sorted by job and address.
+type QueryTargets []*QueryTarget
+
+// Query returns every exported target whose labels satisfy all of matchers. The targets are
+// merged and relabeled the same way they are exported. The groups are expected to be expanded.
+func (t TargetGroups) Query(config *core.Config, matchers []*Matcher) QueryTargets {
+	fileSD := len(config.ExportTypes) == 0 || config.ExportTypes[core.ExportTypeFileSD]
+	qts := make(QueryTargets, 0)
+	for _, mj := range t.mergeJobs(config) {
+		for _, eg := range mj.exportGroups(config.MergePrecedence) {
+			for _, addr := range eg.Targets {
+				if !matchesAll(matchers, eg.Labels, addr) {
+					continue
+				}
+
+				qt := &QueryTarget{Job: mj.job, Address: addr, Labels: eg.Labels}
+				if fileSD {
+					qt.File = targetsFileName(config, eg.Labels)
+				}
+
+				qts = append(qts, qt)
+			}
+		}
+	}
+
+	slices.SortFunc(qts, func(a, b *QueryTarget) int {
+		if c := strings.Compare(a.Job, b.Job); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Address, b.Address)
+	})
+
+	return qts
+}
+
+func matchesAll(matchers []*Matcher, labels map[string]string, addr string) bool {
+	for _, m := range matchers {
+		v := labels[m.Name]
+		if m.Name == addressLabel {
+			v = addr
+		}
+
+		if !m.Matches(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// JobSummary is the number of targets in a job and the targets files they are written to.
+type JobSummary struct {
+	Job     string   `json:"job" yaml:"job"`
+	Targets int      `json:"targets" yaml:"targets"`
+	Files   []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// Jobs summarizes the targets by job in job order.
+func (q QueryTargets) Jobs() []*JobSummary {
+	jobs := make([]*JobSummary, 0)
+	for _, qt := range q {
+		if len(jobs) == 0 || jobs[len(jobs)-1].Job != qt.Job {
+			jobs = append(jobs, &JobSummary{Job: qt.Job, Files: make([]string, 0)})
+		}
+
+		js := jobs[len(jobs)-1]
+		js.Targets++
+		if qt.File != "" && !slices.Contains(js.Files, qt.File) {
+			js.Files = append(js.Files, qt.File)
+		}
+	}
+
+	for _, js := range jobs {
+		slices.Sort(js.Files)
+	}
+
+	return jobs
+}
+
+// LabelSummary lists the values of a label.
+type LabelSummary struct {
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values" yaml:"values"`
+}
+
+// Labels lists every label of the targets with its values, sorted by name and value.
+func (q QueryTargets) Labels() []*LabelSummary {
+	values := make(map[string][]string)
+	for _, qt := range q {
+		for k, v := range qt.Labels {
+			if !slices.Contains(values[k], v) {
+				values[k] = append(values[k], v)
+			}
+		}
+	}
+
+	labels := make([]*LabelSummary, 0, len(values))
+	for k, vs := range values {
+		slices.Sort(vs)
+		labels = append(labels, &LabelSummary{Name: k, Values: vs})
+	}
+
+	slices.SortFunc(labels, func(a, b *LabelSummary) int { return strings.Compare(a.Name, b.Name) })
+	return labels
+}
@@ -0,0 +1,142 @@
+package targets
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryParseMatchers(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		ms, err := ParseMatchers(`environment="prod"`, `{job=~"node|mysql", rack!='r,1'}`, "datacenter!~atl.*")
+		require.NoError(err, "ParseMatchers returned an unexpected error")
+		require.Len(ms, 4, "wrong number of matchers")
+
+		want := []string{`environment="prod"`, `job=~"node|mysql"`, `rack!="r,1"`, `datacenter!~"atl.*"`}
+		for i, m := range ms {
+			require.Equal(want[i], m.String(), "matcher %d did not match", i)
+		}
+	})
+
+	tests := []struct {
+		name string
+		arg  string
+	}{
+		{"NoOperator", "environment"},
+		{"InvalidName", `9rack="r01"`},
+		{"InvalidRegex", `rack=~"r0("`},
+		{"Unterminated", `{rack="r01}`},
+		{"InvalidQuote", `rack="r01\x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMatchers(tt.arg)
+			require.ErrorIs(err, os.ErrInvalid, "ParseMatchers did not return an ErrInvalid")
+		})
+	}
+}
+
+func TestQueryMatcherMatches(t *testing.T) {
+	require := require.New(t)
+
+	tests := []struct {
+		matcher string
+		value   string
+		want    bool
+	}{
+		{`env="prod"`, "prod", true},
+		{`env="prod"`, "production", false},
+		{`env!="prod"`, "", true},
+		{`env=~"pro.*"`, "production", true},
+		{`env=~"pro"`, "production", false},
+		{`env!~"pro.*"`, "stg", true},
+		{`env=""`, "", true},
+	}
+
+	for _, tt := range tests {
+		ms, err := ParseMatchers(tt.matcher)
+		require.NoError(err, "ParseMatchers returned an unexpected error")
+		require.Equal(tt.want, ms[0].Matches(tt.value), "%s matching %q did not match", tt.matcher, tt.value)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	require := require.New(t)
+
+	config := core.DefaultConfig()
+	config.TargetSplit = []string{"environment"}
+	config.Jobs = map[string]*core.JobConfig{"node": {Port: 9100}}
+	tgs := TargetGroups{
+		{
+			Jobs:    []string{"node", "blackbox_icmp"},
+			Labels:  map[string]string{"environment": "prod", "rack": "r01"},
+			Targets: []Target{{Address: "host02"}, {Address: "host01"}},
+		},
+		{
+			Jobs:    []string{"blackbox_icmp"},
+			Labels:  map[string]string{"environment": "stg"},
+			Targets: []Target{{Address: "host03"}},
+		},
+	}
+
+	t.Run("Matchers", func(t *testing.T) {
+		ms, err := ParseMatchers(`{job="blackbox_icmp",environment="prod"}`)
+		require.NoError(err, "ParseMatchers returned an unexpected error")
+
+		qts := tgs.Query(config, ms)
+		require.Equal(QueryTargets{
+			{
+				Job:     "blackbox_icmp",
+				Address: "host01",
+				Labels:  map[string]string{"environment": "prod", "job": "blackbox_icmp", "rack": "r01"},
+				File:    "blackbox_icmp_prod_targets.json",
+			},
+			{
+				Job:     "blackbox_icmp",
+				Address: "host02",
+				Labels:  map[string]string{"environment": "prod", "job": "blackbox_icmp", "rack": "r01"},
+				File:    "blackbox_icmp_prod_targets.json",
+			},
+		}, qts, "targets did not match")
+	})
+
+	t.Run("Address", func(t *testing.T) {
+		ms, err := ParseMatchers(`__address__=~".*:9100"`)
+		require.NoError(err, "ParseMatchers returned an unexpected error")
+
+		qts := tgs.Query(config, ms)
+		require.Len(qts, 2, "wrong number of targets")
+		require.Equal("host01:9100", qts[0].Address, "address did not match")
+	})
+
+	t.Run("Summaries", func(t *testing.T) {
+		qts := tgs.Query(config, nil)
+		require.Len(qts, 5, "wrong number of targets")
+		require.Equal([]*JobSummary{
+			{
+				Job:     "blackbox_icmp",
+				Targets: 3,
+				Files:   []string{"blackbox_icmp_prod_targets.json", "blackbox_icmp_stg_targets.json"},
+			},
+			{Job: "node", Targets: 2, Files: []string{"node_prod_targets.json"}},
+		}, qts.Jobs(), "jobs did not match")
+		require.Equal([]*LabelSummary{
+			{Name: "environment", Values: []string{"prod", "stg"}},
+			{Name: "job", Values: []string{"blackbox_icmp", "node"}},
+			{Name: "rack", Values: []string{"r01"}},
+		}, qts.Labels(), "labels did not match")
+	})
+
+	t.Run("HTTPSDOnly", func(t *testing.T) {
+		c := *config
+		c.ExportTypes = map[string]bool{core.ExportTypeHTTPSD: true}
+		for _, qt := range tgs.Query(&c, nil) {
+			require.Empty(qt.File, "file was set without file_sd")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package targets
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// addressLabel holds the target address while relabeling, like in Prometheus.
+const addressLabel = "__address__"
+
+// relabelRule is a core.RelabelConfig with its regex compiled.
+type relabelRule struct {
+	*core.RelabelConfig
+	regex *regexp.Regexp
+}
+
+// newRelabelRules compiles configs. The rules are checked when the config is loaded, so a rule
+// built in code with a bad regex is skipped.
+func newRelabelRules(configs ...[]*core.RelabelConfig) []relabelRule {
+	rules := make([]relabelRule, 0)
+	for _, list := range configs {
+		for _, rc := range list {
+			if rc == nil {
+				continue
+			}
+
+			re, err := rc.Regexp()
+			if err != nil {
+				continue
+			}
+
+			rules = append(rules, relabelRule{RelabelConfig: rc, regex: re})
+		}
+	}
+
+	return rules
+}
+
+// relabel applies rules to a target with labels and addr. It returns the new labels and address,
+// and false if the target was dropped. Labels starting with __ are removed after relabeling. The
+// job label can not be changed since it decides which job the target is exported for.
+func relabel(rules []relabelRule, labels map[string]string, addr string) (map[string]string, string, bool) {
+	if len(rules) == 0 {
+		return labels, addr, true
+	}
+
+	ls := maps.Clone(labels)
+	ls[addressLabel] = addr
+	for _, r := range rules {
+		if !r.apply(ls) {
+			return nil, "", false
+		}
+	}
+
+	return relabeled(ls, labels["job"])
+}
+
+// relabeled finishes a target once every rule has been applied to ls. It returns false if the rules
+// emptied __address__. Otherwise the address is returned, labels starting with __ are removed from
+// ls and the job label is set back to job.
+func relabeled(ls map[string]string, job string) (map[string]string, string, bool) {
+	addr := ls[addressLabel]
+	if addr == "" {
+		return nil, "", false
+	}
+
+	for name := range ls {
+		if strings.HasPrefix(name, "__") {
+			delete(ls, name)
+		}
+	}
+
+	ls["job"] = job
+	return ls, addr, true
+}
+
+// apply changes ls in place and returns false if the target should be dropped.
+func (r relabelRule) apply(ls map[string]string) bool {
+	values := make([]string, len(r.SourceLabels))
+	for i, name := range r.SourceLabels {
+		values[i] = ls[name]
+	}
+	value := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case core.RelabelKeep:
+		return r.regex.MatchString(value)
+	case core.RelabelDrop:
+		return !r.regex.MatchString(value)
+	case core.RelabelReplace:
+		m := r.regex.FindStringSubmatchIndex(value)
+		if m == nil {
+			return true
+		}
+
+		target := string(r.regex.ExpandString(nil, r.TargetLabel, value, m))
+		if !labelNameRE.MatchString(target) {
+			return true
+		}
+
+		res := string(r.regex.ExpandString(nil, r.Replacement, value, m))
+		if res == "" {
+			delete(ls, target)
+			return true
+		}
+
+		ls[target] = res
+	case core.RelabelHashMod:
+		sum := md5.Sum([]byte(value))
+		ls[r.TargetLabel] = strconv.FormatUint(binary.BigEndian.Uint64(sum[8:])%r.Modulus, 10)
+	case core.RelabelLabelMap:
+		for _, name := range sortedKeys(ls) {
+			if r.regex.MatchString(name) {
+				ls[r.regex.ReplaceAllString(name, r.Replacement)] = ls[name]
+			}
+		}
+	// Unlike Prometheus, labeldrop and labelkeep never remove the address so a labelkeep rule
+	// does not have to list it.
+	case core.RelabelLabelDrop:
+		for name := range ls {
+			if name != addressLabel && r.regex.MatchString(name) {
+				delete(ls, name)
+			}
+		}
+	case core.RelabelLabelKeep:
+		for name := range ls {
+			if name != addressLabel && !r.regex.MatchString(name) {
+				delete(ls, name)
+			}
+		}
+	}
+
+	return true
+}
+
+// relabelGroups applies the job relabel rules to every target in egs. Targets that end up with the
+// same labels are grouped together in the order they are first seen.
+func (jd *jobDefaults) relabelGroups(egs ExportGroups) ExportGroups {
+	if len(jd.relabel) == 0 {
+		return egs
+	}
+
+	out := make(ExportGroups, 0, len(egs))
+	groups := make(map[string]*ExportGroup)
+	for _, eg := range egs {
+		for _, addr := range eg.Targets {
+			labels, addr, ok := relabel(jd.relabel, eg.Labels, addr)
+			if !ok {
+				continue
+			}
+
+			key := labelsKey(labels)
+			g, ok := groups[key]
+			if !ok {
+				g = &ExportGroup{Labels: labels, Targets: make([]string, 0)}
+				groups[key] = g
+				out = append(out, g)
+			}
+
+			g.Targets = append(g.Targets, addr)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,235 @@
+package targets
+
+import (
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRule(f func(r *core.RelabelConfig)) *core.RelabelConfig {
+	r := core.NewRelabelConfig()
+	f(r)
+	return r
+}
+
+func TestRelabel(t *testing.T) {
+	require := require.New(t)
+	base := map[string]string{"job": "node", "environment": "prod", "tmp_owner": "dba"}
+
+	tests := []struct {
+		name       string
+		rule       *core.RelabelConfig
+		addr       string
+		wantLabels map[string]string
+		wantAddr   string
+		wantKeep   bool
+	}{
+		{
+			"Replace",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.SourceLabels = []string{"__address__"}
+				r.Regex = "([a-z]{3})[a-z]+[0-9]+(:[0-9]+)?"
+				r.TargetLabel = "datacenter"
+			}),
+			"atlwebapp01:9100",
+			map[string]string{"job": "node", "environment": "prod", "tmp_owner": "dba", "datacenter": "atl"},
+			"atlwebapp01:9100",
+			true,
+		},
+		{
+			"ReplaceNoMatch",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.SourceLabels = []string{"__address__"}
+				r.Regex = "([a-z]{3})[a-z]+[0-9]+"
+				r.TargetLabel = "datacenter"
+			}),
+			"10.1.2.3",
+			base,
+			"10.1.2.3",
+			true,
+		},
+		{
+			"ReplaceAddress",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.SourceLabels = []string{"__address__", "environment"}
+				r.Regex = "(.+);(.+)"
+				r.TargetLabel = "__address__"
+				r.Replacement = "${1}.${2}.example.com"
+			}),
+			"atlwebapp01",
+			base,
+			"atlwebapp01.prod.example.com",
+			true,
+		},
+		{
+			"ReplaceEmptyDeletes",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.TargetLabel = "environment"
+				r.Replacement = ""
+			}),
+			"host01",
+			map[string]string{"job": "node", "tmp_owner": "dba"},
+			"host01",
+			true,
+		},
+		{
+			"Keep",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelKeep
+				r.SourceLabels = []string{"environment"}
+				r.Regex = "dev"
+			}),
+			"host01",
+			nil,
+			"",
+			false,
+		},
+		{
+			"Drop",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelDrop
+				r.SourceLabels = []string{"__address__"}
+				r.Regex = "host0[1-3]"
+			}),
+			"host02",
+			nil,
+			"",
+			false,
+		},
+		{
+			"HashMod",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelHashMod
+				r.SourceLabels = []string{"__address__"}
+				r.TargetLabel = "shard"
+				r.Modulus = 4
+			}),
+			"host01",
+			map[string]string{"job": "node", "environment": "prod", "tmp_owner": "dba", "shard": "1"},
+			"host01",
+			true,
+		},
+		{
+			"LabelMap",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelLabelMap
+				r.Regex = "tmp_(.+)"
+			}),
+			"host01",
+			map[string]string{"job": "node", "environment": "prod", "tmp_owner": "dba", "owner": "dba"},
+			"host01",
+			true,
+		},
+		{
+			"LabelDrop",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelLabelDrop
+				r.Regex = "tmp_.+"
+			}),
+			"host01",
+			map[string]string{"job": "node", "environment": "prod"},
+			"host01",
+			true,
+		},
+		{
+			"LabelKeep",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.Action = core.RelabelLabelKeep
+				r.Regex = "environment"
+			}),
+			"host01",
+			map[string]string{"job": "node", "environment": "prod"},
+			"host01",
+			true,
+		},
+		{
+			"JobUnchanged",
+			newTestRule(func(r *core.RelabelConfig) {
+				r.TargetLabel = "job"
+				r.Replacement = "other"
+			}),
+			"host01",
+			base,
+			"host01",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, addr, keep := relabel(newRelabelRules([]*core.RelabelConfig{tt.rule}), base, tt.addr)
+			require.Equal(tt.wantKeep, keep, "keep did not match")
+			require.Equal(tt.wantLabels, labels, "labels did not match")
+			require.Equal(tt.wantAddr, addr, "address did not match")
+		})
+	}
+
+	t.Run("NoRules", func(t *testing.T) {
+		labels, addr, keep := relabel(nil, base, "host01")
+		require.True(keep, "target was dropped")
+		require.Equal(base, labels, "labels did not match")
+		require.Equal("host01", addr, "address did not match")
+	})
+}
+
+func TestRelabeled(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Labels", func(t *testing.T) {
+		ls := map[string]string{"__address__": "web01:9100", "__tmp": "x", "job": "other", "env": "prod"}
+		labels, addr, ok := relabeled(ls, "node")
+		require.True(ok, "relabeled dropped the target")
+		require.Equal("web01:9100", addr, "address did not match")
+		require.Equal(map[string]string{"job": "node", "env": "prod"}, labels, "labels did not match")
+	})
+
+	t.Run("NoAddress", func(t *testing.T) {
+		_, _, ok := relabeled(map[string]string{"__address__": "", "job": "node"}, "node")
+		require.False(ok, "relabeled kept a target without an address")
+	})
+}
+
+func TestRelabelExportGroups(t *testing.T) {
+	require := require.New(t)
+	config := core.DefaultConfig()
+	config.RelabelConfigs = []*core.RelabelConfig{
+		newTestRule(func(r *core.RelabelConfig) {
+			r.SourceLabels = []string{"__address__"}
+			r.Regex = "([a-z]{3}).*"
+			r.TargetLabel = "datacenter"
+		}),
+	}
+	config.Jobs = map[string]*core.JobConfig{
+		"node": {
+			Port: 9100,
+			RelabelConfigs: []*core.RelabelConfig{
+				newTestRule(func(r *core.RelabelConfig) {
+					r.Action = core.RelabelDrop
+					r.SourceLabels = []string{"__address__"}
+					r.Regex = "jfkdb02:9100"
+				}),
+			},
+		},
+	}
+	config.TargetSplit = []string{"datacenter"}
+
+	tgs := TargetGroups{{
+		Jobs:    []string{"node"},
+		Targets: []Target{{Address: "atlweb01"}, {Address: "jfkdb01"}, {Address: "atlweb02"}, {Address: "jfkdb02"}},
+	}}
+
+	want := TargetMap{
+		"node_atl_targets.json": {{
+			Labels:  map[string]string{"job": "node", "datacenter": "atl"},
+			Targets: []string{"atlweb01:9100", "atlweb02:9100"},
+		}},
+		"node_jfk_targets.json": {{
+			Labels:  map[string]string{"job": "node", "datacenter": "jfk"},
+			Targets: []string{"jfkdb01:9100"},
+		}},
+	}
+	got, err := tgs.splitByJob(config)
+	require.NoError(err, "splitByJob returned an unexpected error")
+	require.Equal(want, got, "split targets did not match")
+}
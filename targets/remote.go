@@ -0,0 +1,216 @@
+package targets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+const (
+	// remoteMetaExt is the extension of the file that holds the cache validators of a remote
+	// source.
+	remoteMetaExt = ".meta"
+	// MaxRemoteSize is the largest remote sources response pim will read.
+	MaxRemoteSize = 64 << 20
+)
+
+// remoteCache is stored next to the cached copy of a remote source so the next fetch can ask the
+// server if it changed.
+type remoteCache struct {
+	URL          string `json:"url"`
+	File         string `json:"file"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// IsRemote returns true if sources is an http or https URL.
+func IsRemote(sources string) bool {
+	u, err := url.Parse(sources)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// fetchRemote downloads the config sources URL to the sources cache dir and returns the cached
+// file. The server is asked for the sources only if they changed since the last fetch. If the
+// server can not be reached, does not return 200 or 304, or returns sources that can not be read,
+// the last good copy is returned instead. An error is only returned if there is no cached copy.
+func fetchRemote(config *core.Config) (string, error) {
+	if config.SourcesCacheDir == "" {
+		return "", fmt.Errorf("%w: sources_cache_dir is required for remote sources", os.ErrInvalid)
+	}
+
+	if err := os.MkdirAll(config.SourcesCacheDir, 0o755); err != nil {
+		return "", err
+	}
+
+	base := filepath.Join(config.SourcesCacheDir, remoteCacheName(config.Sources))
+	cache := readRemoteCache(config.Sources, base)
+	file, err := fetchSources(config, base, cache)
+	if err == nil {
+		return file, nil
+	}
+
+	if cache == nil {
+		remoteFetches.WithLabelValues("failure").Inc()
+		return "", fmt.Errorf("remote sources %s: %w", config.Sources, err)
+	}
+
+	remoteFetches.WithLabelValues("fallback").Inc()
+	return filepath.Join(config.SourcesCacheDir, cache.File), nil
+}
+
+// cachedRemote returns the cached copy of the config sources URL without fetching it. An empty
+// string is returned if there is no cached copy.
+func cachedRemote(config *core.Config) string {
+	base := filepath.Join(config.SourcesCacheDir, remoteCacheName(config.Sources))
+	cache := readRemoteCache(config.Sources, base)
+	if cache == nil {
+		return ""
+	}
+
+	return filepath.Join(config.SourcesCacheDir, cache.File)
+}
+
+// remoteCacheName returns the name of the cache files for u without an extension.
+func remoteCacheName(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return "remote_" + hex.EncodeToString(sum[:8])
+}
+
+// readRemoteCache returns the cache of u from base. nil is returned if there is no usable cached
+// copy.
+func readRemoteCache(u, base string) *remoteCache {
+	data, err := os.ReadFile(base + remoteMetaExt)
+	if err != nil {
+		return nil
+	}
+
+	cache := &remoteCache{}
+	if err := json.Unmarshal(data, cache); err != nil || cache.URL != u || cache.File == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(base), cache.File)); err != nil {
+		return nil
+	}
+
+	return cache
+}
+
+// fetchSources requests the sources and updates the cache. The cached file is returned.
+func fetchSources(config *core.Config, base string, cache *remoteCache) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, config.Sources, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, text/yaml;q=0.9")
+	if cache != nil {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	timeout := config.SourcesTimeout
+	if timeout < 1 {
+		timeout = core.DefaultSourcesTimeout
+	}
+
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cache != nil:
+		remoteFetches.WithLabelValues("not_modified").Inc()
+		return filepath.Join(filepath.Dir(base), cache.File), nil
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxRemoteSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) > MaxRemoteSize {
+		return "", fmt.Errorf("response is larger than %d bytes", MaxRemoteSize)
+	}
+
+	// Only keep sources that can be read so a bad response never replaces the last good copy.
+	file := base + remoteExt(resp)
+	if _, err := decodeSource(file, data); err != nil {
+		return "", fmt.Errorf("invalid sources: %w", err)
+	}
+
+	// Leave unchanged sources alone so the watcher does not see a change.
+	status, err := fileStatus(file, data)
+	if err != nil {
+		return "", err
+	}
+
+	if status != FileUnchanged {
+		if err := core.WriteFileAtomic(file, data, 0o644); err != nil {
+			return "", err
+		}
+	}
+
+	if cache != nil && cache.File != filepath.Base(file) {
+		err := os.Remove(filepath.Join(filepath.Dir(base), cache.File))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	meta := &remoteCache{
+		URL:          config.Sources,
+		File:         filepath.Base(file),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if err := core.WriteJSON(base+remoteMetaExt, meta, core.PermStdRead); err != nil {
+		return "", err
+	}
+
+	remoteFetches.WithLabelValues("updated").Inc()
+	return file, nil
+}
+
+// remoteExt returns the file extension for the format of resp. The Content-Type is used first and
+// then the extension of the URL path. YAML is assumed if neither is known.
+func remoteExt(resp *http.Response) string {
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		switch {
+		case strings.HasSuffix(mt, "json"):
+			return core.DefaultJSONFileExt
+		case strings.HasSuffix(mt, "yaml"):
+			return core.DefaultYAMLFileExt
+		}
+	}
+
+	ext := filepath.Ext(resp.Request.URL.Path)
+	if slices.Contains([]string{core.DefaultJSONFileExt, core.DefaultYAMLFileExt, ".yaml"}, ext) {
+		return ext
+	}
+
+	return core.DefaultYAMLFileExt
+}
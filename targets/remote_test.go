@@ -0,0 +1,147 @@
+package targets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+const testRemoteSources = `- jobs: [node]
+  labels:
+    datacenter: atl
+  targets:
+    - atlweb01
+    - atlweb02
+`
+
+func TestIsRemote(t *testing.T) {
+	require := require.New(t)
+
+	require.True(IsRemote("http://inventory:8080/pim"), "http url was not remote")
+	require.True(IsRemote("https://inventory/pim.json"), "https url was not remote")
+	require.False(IsRemote("/etc/pim/sources"), "path was remote")
+	require.False(IsRemote("ftp://inventory/pim.yml"), "ftp url was remote")
+	require.False(IsRemote("http:///pim.yml"), "url without a host was remote")
+}
+
+func TestRemoteSources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "remote_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	var body atomic.Value
+	var status, requests atomic.Int32
+	body.Store(testRemoteSources)
+	status.Store(http.StatusOK)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if s := int(status.Load()); s != http.StatusOK {
+			w.WriteHeader(s)
+			return
+		}
+
+		etag := `"` + remoteCacheName(body.Load().(string)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+
+	config := core.DefaultConfig()
+	config.Sources = srv.URL + "/sources"
+	config.SourcesCacheDir = filepath.Join(tempDir, "cache")
+	base := filepath.Join(config.SourcesCacheDir, remoteCacheName(config.Sources))
+
+	t.Run("Fetch", func(t *testing.T) {
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 1, "wrong number of groups")
+		require.Equal([]string{"node"}, tgs[0].Jobs, "jobs did not match")
+		require.FileExists(base+core.DefaultYAMLFileExt, "sources were not cached")
+		require.False(IsWritable(config, base+core.DefaultYAMLFileExt), "remote sources were writable")
+	})
+
+	t.Run("NotModified", func(t *testing.T) {
+		info, err := os.Stat(base + core.DefaultYAMLFileExt)
+		require.NoError(err, "failed to stat cached sources")
+
+		requests.Store(0)
+		files, err := findFiles(config)
+		require.NoError(err, "findFiles returned an unexpected error")
+		require.Equal([]string{base + core.DefaultYAMLFileExt}, files, "files did not match")
+		require.Equal(int32(1), requests.Load(), "wrong number of requests")
+
+		after, err := os.Stat(base + core.DefaultYAMLFileExt)
+		require.NoError(err, "failed to stat cached sources")
+		require.Equal(info.ModTime(), after.ModTime(), "unchanged sources were rewritten")
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		body.Store(testRemoteSources + "- jobs: [mysql]\n  targets: [jfkdb01]\n")
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 2, "wrong number of groups")
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		status.Store(http.StatusServiceUnavailable)
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 2, "cached groups were not used")
+		status.Store(http.StatusOK)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		body.Store("- jobs: node\n")
+		tgs, err := NewTargetGroups(config)
+		require.NoError(err, "NewTargetGroups returned an unexpected error")
+		require.Len(tgs, 2, "invalid sources replaced the cached groups")
+	})
+
+	t.Run("NoCache", func(t *testing.T) {
+		config := core.DefaultConfig()
+		config.Sources = srv.URL + "/other"
+		config.SourcesCacheDir = filepath.Join(tempDir, "cache")
+		status.Store(http.StatusInternalServerError)
+		defer status.Store(http.StatusOK)
+
+		_, err := NewTargetGroups(config)
+		require.ErrorContains(err, "unexpected status: 500 Internal Server Error", "error did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		jsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`[{"jobs": ["node"], "targets": ["host01"]}]`))
+		}))
+		defer jsrv.Close()
+
+		config := core.DefaultConfig()
+		config.Sources = jsrv.URL
+		config.SourcesCacheDir = filepath.Join(tempDir, "cache")
+		files, err := findFiles(config)
+		require.NoError(err, "findFiles returned an unexpected error")
+		require.Equal(core.DefaultJSONFileExt, filepath.Ext(files[0]), "extension did not match")
+	})
+
+	t.Run("NoCacheDir", func(t *testing.T) {
+		config := core.DefaultConfig()
+		config.Sources = srv.URL
+		config.SourcesCacheDir = ""
+		_, err := findFiles(config)
+		require.ErrorIs(err, os.ErrInvalid, "findFiles did not return the correct error")
+	})
+}
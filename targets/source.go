@@ -0,0 +1,181 @@
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceFiles returns every source file in config.Sources. An empty list is returned if there are
+// no source files yet.
+func SourceFiles(config *core.Config) ([]string, error) {
+	files, err := findFiles(config)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	return files, err
+}
+
+// IsSourceFileName returns true if name is a file name pim reads sources from, such as
+// targets.yml or webapp_targets.json.
+func IsSourceFileName(name string) bool {
+	if name != filepath.Base(name) {
+		return false
+	}
+
+	for _, p := range targetsSourceFiles {
+		if name == p || (strings.HasSuffix(name, "_"+p) && len(name) > len(p)+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadSource reads the target groups from a single source file in the format set by config and
+// the file extension. Files from a source in the config sources list use the source format. The
+// source priority and labels are not applied.
+func ReadSource(config *core.Config, file string) (TargetGroups, error) {
+	config = fileConfig(config, file)
+	if IsInventory(config, file) {
+		return ReadInventoryFile(config, file)
+	}
+
+	switch {
+	case filepath.Ext(file) == csvFileExt:
+		return ReadCSVFile(file)
+	case isListFile(file):
+		return ReadListFile(config, file)
+	}
+
+	return ReadSourceFile(file)
+}
+
+// IsWritable returns true if file is a pim source file that WriteSourceFile can write. Ansible
+// inventories, CSV and list sources and the cached copy of remote sources are only read.
+func IsWritable(config *core.Config, file string) bool {
+	if IsInventory(fileConfig(config, file), file) || isCached(config, file) {
+		return false
+	}
+
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt, core.DefaultYAMLFileExt, ".yaml":
+		return true
+	}
+
+	return false
+}
+
+// isCached returns true if file is the cached copy of a remote source.
+func isCached(config *core.Config, file string) bool {
+	return config.SourcesCacheDir != "" && filepath.Dir(filepath.Clean(file)) == filepath.Clean(config.SourcesCacheDir)
+}
+
+// lineError is a problem found at a line of a source file that is not YAML or JSON.
+type lineError struct {
+	line int
+	msg  string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+func newLineError(line int, format string, a ...any) *lineError {
+	return &lineError{line: line, msg: fmt.Sprintf(format, a...)}
+}
+
+// lineProblem returns err as a Problem in file, at its line if it is a lineError.
+func lineProblem(file string, err error) Problem {
+	p := Problem{File: file, Message: err.Error()}
+	var lerr *lineError
+	if errors.As(err, &lerr) {
+		p.Line, p.Message = lerr.line, lerr.msg
+	}
+
+	return p
+}
+
+// ReadSourceFile reads the target groups from a single source file. The format is determined by
+// the file extension. An empty file returns no groups.
+func ReadSourceFile(file string) (TargetGroups, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSource(file, data)
+}
+
+// decodeSource decodes the contents of a source file in the format set by the file extension.
+func decodeSource(file string, data []byte) (TargetGroups, error) {
+	var err error
+	tgs := make(TargetGroups, 0)
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt:
+		err = json.Unmarshal(data, &tgs)
+	case core.DefaultYAMLFileExt, ".yaml":
+		err = yaml.Unmarshal(data, &tgs)
+	default:
+		return nil, fmt.Errorf("%w: unknown file extension for file: %s", os.ErrInvalid, file)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tg := range tgs {
+		tg.source, tg.index = file, i
+	}
+
+	return tgs, nil
+}
+
+// WriteSourceFile writes tgs to a source file in the format set by the file extension. The file
+// is replaced atomically so a running export never reads a partial file. Comments in YAML source
+// files are not preserved.
+func WriteSourceFile(file string, tgs TargetGroups) error {
+	var data []byte
+	var err error
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt:
+		data, err = core.MarshalJSON(&tgs)
+	case core.DefaultYAMLFileExt, ".yaml":
+		data, err = core.MarshalYAML(&tgs)
+	default:
+		return fmt.Errorf("%w: unknown file extension for file: %s", os.ErrInvalid, file)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return core.WriteFileAtomic(file, data, 0o644)
+}
+
+// Validate checks tg with the same rules used to lint source files. Problems do not include a
+// file or position. If config is set, a group without jobs is allowed when a job selector picks
+// it up.
+func (tg *TargetGroup) Validate(config *core.Config) Problems {
+	var node yaml.Node
+	if err := node.Encode(tg); err != nil {
+		return Problems{{Message: err.Error()}}
+	}
+
+	v := &validator{config: config, problems: make(Problems, 0)}
+	v.group(0, &node)
+
+	// There is only one group so drop the group index from the messages.
+	for i := range v.problems {
+		v.problems[i].Message = strings.TrimPrefix(v.problems[i].Message, "group 0: ")
+	}
+
+	return v.problems
+}
@@ -0,0 +1,82 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSourceFileName(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{"targets.yml", "targets.json", "webapp_targets.yaml"} {
+		require.True(IsSourceFileName(name), "%s was not a source file name", name)
+	}
+
+	for _, name := range []string{"_targets.yml", "hosts.yml", "targets.txt", "../targets.yml", "a/b_targets.yml"} {
+		require.False(IsSourceFileName(name), "%s was a source file name", name)
+	}
+}
+
+func TestSourceFileReadWrite(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "source_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	tgs := TargetGroups{
+		&TargetGroup{
+			Name:    "webapp",
+			Jobs:    []string{"node"},
+			Labels:  map[string]string{"environment": "prod"},
+			Targets: []Target{{Address: "host01"}, {Address: "host02", Labels: map[string]string{"rack": "r12"}}},
+		},
+	}
+
+	for _, name := range []string{"a_targets.yml", "a_targets.json"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(tempDir, name)
+			tgs[0].source = file
+			err := WriteSourceFile(file, tgs)
+			require.NoError(err, "WriteSourceFile returned an unexpected error")
+
+			got, err := ReadSourceFile(file)
+			require.NoError(err, "ReadSourceFile returned an unexpected error")
+			require.Equal(tgs, got, "target groups did not match")
+		})
+	}
+
+	t.Run("UnknownExtension", func(t *testing.T) {
+		err := WriteSourceFile(filepath.Join(tempDir, "a_targets.txt"), tgs)
+		require.ErrorIs(err, os.ErrInvalid, "WriteSourceFile did not return the correct error")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		file := filepath.Join(tempDir, "empty_targets.yml")
+		err := os.WriteFile(file, []byte(""), 0o644)
+		require.NoError(err, "failed to write %s", file)
+
+		got, err := ReadSourceFile(file)
+		require.NoError(err, "ReadSourceFile returned an unexpected error")
+		require.Empty(got, "target groups were not empty")
+	})
+}
+
+func TestTargetGroupValidate(t *testing.T) {
+	require := require.New(t)
+
+	require.Empty(expectedTargetGroups[0].Validate(nil), "valid group had problems")
+
+	tg := &TargetGroup{
+		Jobs:    []string{"node"},
+		Labels:  map[string]string{"bad-name": "x"},
+		Targets: []Target{{Address: "host01"}, {Address: "host01"}},
+	}
+	require.Equal(Problems{
+		{Message: `invalid label name "bad-name"`},
+		{Message: `duplicate target "host01"`},
+	}, tg.Validate(nil), "problems did not match")
+}
@@ -1,24 +1,70 @@
 package targets
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/chadeldridge/prometheus-import-manager/core"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
-var targetsSourceFiles = []string{"targets.yml", "targets.yaml", "targets.json"}
+// splitMissingValue is used in place of a target_split label value when a group does not have
+// the label.
+const splitMissingValue = "none"
+
+var (
+	targetsSourceFiles = []string{"targets.yml", "targets.yaml", "targets.json"}
+	// inventorySourceFiles are found along with targetsSourceFiles and read as Ansible
+	// inventories.
+	inventorySourceFiles = []string{"targets.ini"}
+	// Sources in other formats that are found along with targetsSourceFiles.
+	otherSourceFiles = []string{"targets.csv", "targets.txt", "targets.list"}
+	// Characters that should not end up in a targets file name.
+	unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+)
 
 type TargetGroup struct {
-	Jobs    []string          `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	// Name is optional and is used to address the group through the API.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Priority decides which group's labels win when the same target is in more than one group
+	// for a job and merge_precedence is priority. Higher wins.
+	Priority int               `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Jobs     []string          `json:"jobs,omitempty" yaml:"jobs,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Targets  []Target          `json:"targets,omitempty" yaml:"targets,omitempty"`
+
+	// source is the file the group was read from and index its position in the file. origin is
+	// the name of the source in the config sources list it was read from.
+	source string
+	index  int
+	origin string
+}
+
+// Target is a single target address. Labels are merged over the TargetGroup labels for this
+// target only. In source files a target can be a plain string or an object.
+//
+//	targets:
+//	  - host01
+//	  - address: host02
+//	    labels:
+//	      rack: r12
+type Target struct {
+	Address string            `json:"address" yaml:"address"`
 	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
-	Targets []string          `json:"targets,omitempty" yaml:"targets,omitempty"`
 }
 
+// target is used to unmarshal the object form of Target without recursing into the custom
+// unmarshalers.
+type target Target
+
 type ExportGroup struct {
 	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Targets []string          `json:"targets,omitempty" yaml:"targets,omitempty"`
@@ -30,16 +76,107 @@ type (
 	TargetMap    map[string]ExportGroups
 )
 
+// FileStatus describes what an export did to a targets file.
+type FileStatus string
+
+const (
+	FileCreated   FileStatus = "created"
+	FileUpdated   FileStatus = "updated"
+	FileUnchanged FileStatus = "unchanged"
+	FileRemoved   FileStatus = "removed"
+)
+
+// FileResult is the outcome of an export for a single targets file.
+type FileResult struct {
+	File   string     `json:"file" yaml:"file"`
+	Status FileStatus `json:"status" yaml:"status"`
+	// Targets and Groups are the number of targets and groups in a file an export wrote. They are
+	// recorded in the metrics.
+	Targets int `json:"-" yaml:"-"`
+	Groups  int `json:"-" yaml:"-"`
+}
+
+// ExportResults lists the outcome of an export for each targets file, sorted by file name.
+type ExportResults []FileResult
+
 // NewTargetGroup creates a new TargetGroup with initialized fields.
 func NewTargetGroup() *TargetGroup {
 	return &TargetGroup{
 		Labels:  make(map[string]string),
-		Targets: make([]string, 0),
+		Targets: make([]Target, 0),
 		Jobs:    make([]string, 0),
 	}
 }
 
-func findFiles(config *core.Config) ([]string, error) {
+// MarshalJSON writes targets without labels as a plain string.
+func (t Target) MarshalJSON() ([]byte, error) {
+	if len(t.Labels) == 0 {
+		return json.Marshal(t.Address)
+	}
+
+	return json.Marshal(target(t))
+}
+
+// UnmarshalJSON accepts either a plain string or an object with an address and labels.
+func (t *Target) UnmarshalJSON(data []byte) error {
+	var addr string
+	if err := json.Unmarshal(data, &addr); err == nil {
+		*t = Target{Address: addr}
+		return nil
+	}
+
+	var obj target
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	return t.set(obj)
+}
+
+// MarshalYAML writes targets without labels as a plain string.
+func (t Target) MarshalYAML() (interface{}, error) {
+	if len(t.Labels) == 0 {
+		return t.Address, nil
+	}
+
+	return target(t), nil
+}
+
+// UnmarshalYAML accepts either a plain string or a mapping with an address and labels.
+func (t *Target) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = Target{Address: value.Value}
+		return nil
+	}
+
+	var obj target
+	if err := value.Decode(&obj); err != nil {
+		return err
+	}
+
+	return t.set(obj)
+}
+
+func (t *Target) set(obj target) error {
+	if obj.Address == "" {
+		return fmt.Errorf("%w: target is missing an address", os.ErrInvalid)
+	}
+
+	*t = Target(obj)
+	return nil
+}
+
+// findSourceFiles returns the source files in config.Sources.
+func findSourceFiles(config *core.Config) ([]string, error) {
+	if IsRemote(config.Sources) {
+		file, err := fetchRemote(config)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{file}, nil
+	}
+
 	info, err := os.Stat(config.Sources)
 	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("error reading source file %s: %w", config.Sources, err)
@@ -54,7 +191,7 @@ func findFiles(config *core.Config) ([]string, error) {
 	}
 
 	// See if an exact match exists. (e.g. targets.yml, targets.json)
-	for _, p := range targetsSourceFiles {
+	for _, p := range slices.Concat(targetsSourceFiles, inventorySourceFiles, otherSourceFiles) {
 		f := filepath.Join(config.Sources, p)
 		_, err := os.Stat(f)
 		if os.IsNotExist(err) {
@@ -74,46 +211,49 @@ func findFiles(config *core.Config) ([]string, error) {
 	// names are ${descriptor}_${targetsSourceFile} and return a list of all
 	// matches.
 	// Example: blackbox_targets.yml
-	for _, p := range targetsSourceFiles {
-		p = "_" + p
-		files, err := filepath.Glob(filepath.Join(config.Sources, p))
+	files, err := PatternSourceFiles(config.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) > 0 {
+		return files, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// PatternSourceFiles returns the sorted ${descriptor}_targets source files in dir. They are only
+// read when dir has no exact match like targets.yml.
+func PatternSourceFiles(dir string) ([]string, error) {
+	files := make([]string, 0)
+	for _, p := range slices.Concat(targetsSourceFiles, inventorySourceFiles, otherSourceFiles) {
+		p = "*_" + p
+		matches, err := filepath.Glob(filepath.Join(dir, p))
 		if err != nil {
 			return nil, err
 		}
 
-		if len(files) > 0 {
-			return files, nil
-		}
+		files = append(files, matches...)
 	}
 
-	return nil, os.ErrNotExist
+	slices.Sort(files)
+	return files, nil
 }
 
-func readSources(files []string) (TargetGroups, error) {
+// readSources reads the target groups from every file in the format set by config. Groups from
+// the config sources list get the origin, priority and labels of their source.
+func readSources(config *core.Config, files []string) (TargetGroups, error) {
 	tgs := make(TargetGroups, 0)
 
 	for _, f := range files {
-		t := make(TargetGroups, 0)
-
-		// Get the contents of fileYAML
-		data, err := os.ReadFile(f)
+		t, err := ReadSource(config, f)
 		if err != nil {
 			return nil, err
 		}
 
-		// Determine if the file is JSON or YAML
-		if strings.HasSuffix(f, core.DefaultJSONFileExt) {
-			// If JSON, unmarshal file as JSON
-			if err := json.Unmarshal(data, &t); err != nil {
-				return nil, err
-			}
-		} else if strings.HasSuffix(f, core.DefaultYAMLFileExt) || strings.HasSuffix(f, "yaml") {
-			// If YAML, unmarshal file as YAML
-			if err := yaml.Unmarshal(data, &t); err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, fmt.Errorf("%w: unknown file extension for file: %s", os.ErrInvalid, f)
+		for _, tg := range t {
+			tg.applySource(config)
 		}
 
 		tgs = append(tgs, t...)
@@ -126,7 +266,9 @@ func readSources(files []string) (TargetGroups, error) {
 	return tgs, nil
 }
 
-// NewTargetGroups loads target groups from a file in the sources directory.
+// NewTargetGroups loads target groups from a file in the sources directory. Target address
+// patterns are expanded. If merge_precedence is error, targets with conflicting labels return an
+// error.
 func NewTargetGroups(config *core.Config) (TargetGroups, error) {
 	// Look for a valid targets source file in the sources directory.
 	files, err := findFiles(config)
@@ -142,76 +284,268 @@ func NewTargetGroups(config *core.Config) (TargetGroups, error) {
 		)
 	}
 
-	return readSources(files)
+	tgs, err := readSources(config, files)
+	if err != nil {
+		return nil, err
+	}
+
+	tgs, err = tgs.Expand()
+	if err != nil {
+		return nil, err
+	}
+
+	return tgs, tgs.checkMerge(config)
 }
 
 // ExportTargets arranges jobs, targets, and labels into target files based on config settings.
-func (t TargetGroups) ExportTargets(config *core.Config) error {
-	files := t.splitByJob(config)
+// Returns what was done to each targets file.
+func (t TargetGroups) ExportTargets(config *core.Config) (ExportResults, error) {
+	files, err := t.splitByJob(config)
+	if err != nil {
+		return nil, err
+	}
 
-	err := writeTargets(config, files)
+	results, err := writeTargets(config, files)
 	if err != nil {
-		return err
+		return results, err
 	}
 
-	return nil
+	return results, nil
 }
 
-func (t TargetGroups) splitByJob(config *core.Config) TargetMap {
+// splitByJob creates ExportGroups for each job in each TargetGroup, including the jobs that select
+// the group by its labels, and arranges them by file name. Targets in more than one group for a
+// job are merged into one and targets with the same labels share an ExportGroup. Files are always
+// split by job and then further split by the config TargetSplit labels. Label values that end up
+// with the same file name return an error instead of writing their targets to one file.
+func (t TargetGroups) splitByJob(config *core.Config) (TargetMap, error) {
 	files := make(TargetMap)
-	for _, tg := range t {
-		for _, job := range tg.Jobs {
-			filename := job + core.DefaultTargetsFileSuffix + config.TargetsFileExt
-			if files[filename] == nil {
-				files[filename] = make(ExportGroups, 0)
+	values := make(map[string][]string)
+	for _, mj := range t.mergeJobs(config) {
+		for _, eg := range mj.exportGroups(config.MergePrecedence) {
+			filename := targetsFileName(config, eg.Labels)
+			v := splitValues(config, eg.Labels)
+			if other, ok := values[filename]; ok && !slices.Equal(other, v) {
+				return nil, fmt.Errorf(
+					"%w: target_split values %q and %q both use the targets file %s",
+					os.ErrInvalid,
+					strings.Join(other, ","),
+					strings.Join(v, ","),
+					filename,
+				)
 			}
 
-			tg.Labels["job"] = job
-			files[filename] = append(files[filename], &ExportGroup{
-				Labels:  tg.Labels,
-				Targets: tg.Targets,
-			})
+			values[filename] = v
+			files[filename] = append(files[filename], eg)
 		}
 	}
 
-	return files
+	return files, nil
 }
 
-// writeTargets writes the target groups to files based on the config settings.
-func writeTargets(config *core.Config, files TargetMap) error {
-	for filename, tgs := range files {
-		// Write the file
-		f := filepath.Join(config.TargetsDir, filename)
+// exportGroups returns the ExportGroups for the job of jd. Targets without labels share a single
+// group with the TargetGroup labels. Targets with labels are grouped by their label overrides, in
+// the order they are first seen, and the overrides are merged over the TargetGroup labels. The job
+// defaults are applied to every address and their labels are merged under the group labels. The
+// relabel rules run last and may regroup or drop targets.
+func (tg *TargetGroup) exportGroups(jd *jobDefaults) ExportGroups {
+	base := &ExportGroup{Labels: jobLabels(jd.job, jd.labels, tg.Labels), Targets: make([]string, 0)}
+	egs := ExportGroups{base}
+	overrides := make(map[string]*ExportGroup)
+	// Jobs the group does not list only get the targets their selector matches.
+	listed := slices.Contains(tg.Jobs, jd.job)
+
+	for _, target := range tg.Targets {
+		if !listed && !jd.selects(tg, target) {
+			continue
+		}
 
-		// Ensure the directory exists
-		dir := filepath.Dir(f)
-		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
-			return fmt.Errorf("targets dir %w: %s", os.ErrNotExist, dir)
+		if len(target.Labels) == 0 {
+			base.Targets = append(base.Targets, jd.rewrite(target.Address, base.Labels))
+			continue
 		}
 
-		// We were creating the dir path if it diesn't exist but this can be unwanted or
-		// dangerous and probably shouldn't be the defautl behavior. If target path is
-		// inside a mounted dir and the dir is unmounted we would create a new dir instead
-		// of erroring. This would then get overwritten when the dir mounts.
-		/*
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				return err
-			}
-		*/
+		key := labelsKey(target.Labels)
+		eg, ok := overrides[key]
+		if !ok {
+			eg = &ExportGroup{Labels: jobLabels(jd.job, jd.labels, tg.Labels, target.Labels)}
+			overrides[key] = eg
+			egs = append(egs, eg)
+		}
 
-		// Write the file
-		if config.TargetsFileExt == core.DefaultJSONFileExt {
-			if err := core.WriteJSON(f, &tgs, 0o644); err != nil {
-				return err
-			}
+		eg.Targets = append(eg.Targets, jd.rewrite(target.Address, eg.Labels))
+	}
 
-			continue
+	// Drop the base group if every target had its own labels.
+	if len(base.Targets) == 0 && len(egs) > 1 {
+		egs = egs[1:]
+	}
+
+	return jd.relabelGroups(egs)
+}
+
+// jobLabels returns a new label set with each of sets merged over the ones before it and the job
+// label set.
+func jobLabels(job string, sets ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, labels := range sets {
+		maps.Copy(merged, labels)
+	}
+
+	merged["job"] = job
+	return merged
+}
+
+// labelsKey returns a stable string representation of labels.
+func labelsKey(labels map[string]string) string {
+	keys := sortedKeys(labels)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, labels[k])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
+
+// targetsFileName builds the targets file name from the label values listed in the config
+// TargetSplit. If job is not listed in TargetSplit it will be the first part of the name.
+//
+//	target_split: [job, datacenter]  =>  ${job}_${datacenter}${suffix}${ext}
+func targetsFileName(config *core.Config, labels map[string]string) string {
+	parts := splitValues(config, labels)
+	for i, v := range parts {
+		if v == "" {
+			v = splitMissingValue
 		}
 
-		if err := core.WriteYAML(f, &tgs, 0o644); err != nil {
+		parts[i] = unsafeFileChars.ReplaceAllString(v, "_")
+	}
+
+	return strings.Join(parts, "_") + config.TargetsFileSuffix + config.TargetsFileExt
+}
+
+// splitValues returns the values of the labels the targets files are split by, with job first if
+// it is not listed in TargetSplit.
+func splitValues(config *core.Config, labels map[string]string) []string {
+	split := config.TargetSplit
+	if !slices.Contains(split, "job") {
+		split = append([]string{"job"}, split...)
+	}
+
+	values := make([]string, 0, len(split))
+	for _, l := range split {
+		values = append(values, labels[l])
+	}
+
+	return values
+}
+
+// renderTargets returns the contents of a targets file in the format set by TargetsFileExt.
+func renderTargets(config *core.Config, egs ExportGroups) ([]byte, error) {
+	if config.TargetsFileExt == core.DefaultJSONFileExt {
+		return core.MarshalJSON(&egs)
+	}
+
+	return core.MarshalYAML(&egs)
+}
+
+// fileStatus compares data with the contents of file and returns the status writing data to
+// file would result in.
+func fileStatus(file string, data []byte) (FileStatus, error) {
+	existing, err := os.ReadFile(file)
+	switch {
+	case err == nil && bytes.Equal(existing, data):
+		return FileUnchanged, nil
+	case err == nil:
+		return FileUpdated, nil
+	case errors.Is(err, os.ErrNotExist):
+		return FileCreated, nil
+	default:
+		return "", err
+	}
+}
+
+// writeTargets writes the target groups to files based on the config settings. Files are written
+// to a temp file and renamed into place and files whose contents have not changed are not
+// written at all. Targets files written by a previous export that are no longer generated are
+// removed.
+func writeTargets(config *core.Config, files TargetMap) (ExportResults, error) {
+	results := make(ExportResults, 0, len(files))
+
+	// Ensure the directory exists
+	dir := config.TargetsDir
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return results, fmt.Errorf("targets dir %w: %s", os.ErrNotExist, dir)
+	}
+
+	// We were creating the dir path if it diesn't exist but this can be unwanted or
+	// dangerous and probably shouldn't be the defautl behavior. If target path is
+	// inside a mounted dir and the dir is unmounted we would create a new dir instead
+	// of erroring. This would then get overwritten when the dir mounts.
+	/*
+		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return err
 		}
+	*/
+
+	old, err := readManifest(dir)
+	if err != nil {
+		return results, err
 	}
 
-	return nil
+	// Record every file we are about to write before writing them. If a write fails we still
+	// own the files and can clean them up on a later export.
+	current := newManifest(files)
+	if err := old.union(current).write(dir); err != nil {
+		return results, err
+	}
+
+	for _, filename := range current.Files {
+		data, err := renderTargets(config, files[filename])
+		if err != nil {
+			return results, err
+		}
+
+		f := filepath.Join(dir, filename)
+		status, err := fileStatus(f, data)
+		if err != nil {
+			return results, err
+		}
+
+		egs := files[filename]
+		result := FileResult{File: filename, Status: status, Targets: countTargets(egs), Groups: len(egs)}
+		if status == FileUnchanged {
+			results = append(results, result)
+			continue
+		}
+
+		if err := core.WriteFileAtomic(f, data, core.PermStdRead); err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	removed, err := removeStaleTargets(dir, old, current)
+	for _, filename := range removed {
+		results = append(results, FileResult{File: filename, Status: FileRemoved})
+	}
+
+	slices.SortFunc(results, func(a, b FileResult) int { return strings.Compare(a.File, b.File) })
+	if err != nil {
+		return results, err
+	}
+
+	return results, current.write(dir)
 }
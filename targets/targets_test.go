@@ -22,7 +22,7 @@ var (
 				"datacenter":  "us-east-1",
 				"role":        "monitoring",
 			},
-			Targets: []string{"prom.example.com", "grafana.example.com"},
+			Targets: []Target{{Address: "prom.example.com"}, {Address: "grafana.example.com"}},
 		},
 		&TargetGroup{
 			Jobs: []string{"node-exporter", "mysql-exporter"},
@@ -30,14 +30,14 @@ var (
 				"environment": "stg",
 				"datacenter":  "us-east-1",
 			},
-			Targets: []string{"node1.example.com", "node2.example.com"},
+			Targets: []Target{{Address: "node1.example.com"}, {Address: "node2.example.com"}},
 		},
 	}
 	splitTargetGroups = TargetMap{
 		"blackbox_icmp_targets.yml": {
-			&TargetGroup{
-				Jobs: []string{"blackbox_icmp"},
+			&ExportGroup{
 				Labels: map[string]string{
+					"job":         "blackbox_icmp",
 					"environment": "prod",
 					"datacenter":  "us-east-1",
 					"role":        "monitoring",
@@ -46,9 +46,9 @@ var (
 			},
 		},
 		"node-exporter_targets.yml": {
-			&TargetGroup{
-				Jobs: []string{"node-exporter"},
+			&ExportGroup{
 				Labels: map[string]string{
+					"job":         "node-exporter",
 					"environment": "stg",
 					"datacenter":  "us-east-1",
 				},
@@ -56,9 +56,9 @@ var (
 			},
 		},
 		"mysql-exporter_targets.yml": {
-			&TargetGroup{
-				Jobs: []string{"mysql-exporter"},
+			&ExportGroup{
 				Labels: map[string]string{
+					"job":         "mysql-exporter",
 					"environment": "stg",
 					"datacenter":  "us-east-1",
 				},
@@ -116,7 +116,7 @@ func newExpectedTargetGroups() *TargetGroups {
 				"datacenter":  "us-east-1",
 				"role":        "monitoring",
 			},
-			Targets: []string{"prom.example.com", "grafana.example.com"},
+			Targets: []Target{{Address: "prom.example.com"}, {Address: "grafana.example.com"}},
 		},
 		&TargetGroup{
 			Jobs: []string{"node-exporter", "mysql-exporter"},
@@ -124,7 +124,7 @@ func newExpectedTargetGroups() *TargetGroups {
 				"environment": "stg",
 				"datacenter":  "us-east-1",
 			},
-			Targets: []string{"node1.example.com", "node2.example.com"},
+			Targets: []Target{{Address: "node1.example.com"}, {Address: "node2.example.com"}},
 		},
 	}
 }
@@ -145,7 +145,7 @@ func testTargetGroupTags(t *testing.T, format string) {
 	tg := &TargetGroup{
 		Jobs:    []string{"prometheus"},
 		Labels:  map[string]string{"environment": "test"},
-		Targets: []string{"localhost:9090"},
+		Targets: []Target{{Address: "localhost:9090"}},
 	}
 	expYAML := `jobs:
     - prometheus
@@ -188,6 +188,47 @@ targets:
 	require.Equal(*tg, got, "got did not match original tg")
 }
 
+func TestTarget(t *testing.T) {
+	require := require.New(t)
+	plain := Target{Address: "host01"}
+	labeled := Target{Address: "host02", Labels: map[string]string{"rack": "r12"}}
+
+	t.Run("MarshalJSON", func(t *testing.T) {
+		data, err := json.Marshal([]Target{plain, labeled})
+		require.NoError(err, "failed to marshal targets to JSON")
+		require.Equal(`["host01",{"address":"host02","labels":{"rack":"r12"}}]`, string(data))
+	})
+
+	t.Run("MarshalYAML", func(t *testing.T) {
+		data, err := yaml.Marshal([]Target{plain, labeled})
+		require.NoError(err, "failed to marshal targets to YAML")
+		require.Equal("- host01\n- address: host02\n  labels:\n    rack: r12\n", string(data))
+	})
+
+	t.Run("UnmarshalJSON", func(t *testing.T) {
+		var got []Target
+		err := json.Unmarshal([]byte(`["host01", {"address": "host02", "labels": {"rack": "r12"}}]`), &got)
+		require.NoError(err, "failed to unmarshal targets from JSON")
+		require.Equal([]Target{plain, labeled}, got, "targets did not match")
+	})
+
+	t.Run("UnmarshalYAML", func(t *testing.T) {
+		var got []Target
+		err := yaml.Unmarshal([]byte("- host01\n- address: host02\n  labels:\n    rack: r12\n"), &got)
+		require.NoError(err, "failed to unmarshal targets from YAML")
+		require.Equal([]Target{plain, labeled}, got, "targets did not match")
+	})
+
+	t.Run("MissingAddress", func(t *testing.T) {
+		var got []Target
+		err := json.Unmarshal([]byte(`[{"labels": {"rack": "r12"}}]`), &got)
+		require.ErrorIs(err, os.ErrInvalid, "JSON did not return the correct error")
+
+		err = yaml.Unmarshal([]byte("- labels:\n    rack: r12\n"), &got)
+		require.ErrorIs(err, os.ErrInvalid, "YAML did not return the correct error")
+	})
+}
+
 func TestTargetGroups(t *testing.T) {
 	require := require.New(t)
 	t.Run("Types", func(t *testing.T) {
@@ -220,7 +261,7 @@ func TestNewTargetGroup(t *testing.T) {
 
 	// Test that we can add items to the initialized collections
 	tg.Labels["environment"] = "prod"
-	tg.Targets = append(tg.Targets, "prom.example.com")
+	tg.Targets = append(tg.Targets, Target{Address: "prom.example.com"})
 	tg.Jobs = append(tg.Jobs, "blackbox_icmp")
 	require.Len(tg.Jobs, 1, "Jobs did not contain the correct number of items")
 	require.Len(tg.Labels, 1, "Labels did not contain the correct number of items")
@@ -376,7 +417,7 @@ func testNewTargetGroups(t *testing.T, file string, content string) {
 
 func TestReadSources(t *testing.T) {
 	t.Run("Unknown", func(t *testing.T) {
-		testReadSources(t, "targets.txt")
+		testReadSources(t, "targets.conf")
 	})
 	t.Run("yml", func(t *testing.T) {
 		testReadSources(t, "targets.yml")
@@ -406,7 +447,7 @@ func testReadSources(t *testing.T, file string) {
 		&TargetGroup{
 			Jobs:    []string{"prometheus"},
 			Labels:  map[string]string{"environment": "test"},
-			Targets: []string{"localhost:9090"},
+			Targets: []Target{{Address: "localhost:9090"}},
 		},
 	}
 	var content string
@@ -438,9 +479,9 @@ func testReadSources(t *testing.T, file string) {
 	err = os.WriteFile(targetsFile, []byte(content), 0o644)
 	require.NoError(err, "failed to write test file: %s", file)
 
-	got, err := readSources([]string{targetsFile})
+	got, err := readSources(core.DefaultConfig(), []string{targetsFile})
 
-	if strings.HasSuffix(file, "txt") {
+	if strings.HasSuffix(file, "conf") {
 		require.Error(err, "readSources did not return an error")
 		require.ErrorIs(err, os.ErrInvalid, "readSources did not return the correct error")
 		require.Nil(got, "readSources did not return a nil TargetGroups")
@@ -449,9 +490,59 @@ func testReadSources(t *testing.T, file string) {
 
 	require.NoError(err, "readSources did not return an error")
 	require.NotNil(got, "readSources returned a nil TargetGroups")
+	tgs[0].source = targetsFile
 	require.Equal(tgs, got, "TargetGroups did not match")
 }
 
+func TestReadSourcesTargetObjects(t *testing.T) {
+	require := require.New(t)
+	want := TargetGroups{
+		&TargetGroup{
+			Jobs:   []string{"node"},
+			Labels: map[string]string{"environment": "prod"},
+			Targets: []Target{
+				{Address: "host01"},
+				{Address: "host02", Labels: map[string]string{"rack": "r12"}},
+			},
+		},
+	}
+
+	files := map[string]string{
+		"targets.yml": `- jobs:
+    - node
+  labels:
+    environment: prod
+  targets:
+    - host01
+    - address: host02
+      labels:
+        rack: r12
+`,
+		"targets.json": `[{
+  "jobs": ["node"],
+  "labels": {"environment": "prod"},
+  "targets": ["host01", {"address": "host02", "labels": {"rack": "r12"}}]
+}]`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "targets_test")
+			require.NoError(err, "failed to create temp dir")
+			defer os.RemoveAll(tempDir)
+
+			f := filepath.Join(tempDir, name)
+			err = os.WriteFile(f, []byte(content), 0o644)
+			require.NoError(err, "failed to write test file: %s", name)
+
+			got, err := readSources(core.DefaultConfig(), []string{f})
+			require.NoError(err, "readSources returned an unexpected error")
+			want[0].source = f
+			require.Equal(want, got, "TargetGroups did not match")
+		})
+	}
+}
+
 func TestSplitByJob(t *testing.T) {
 	require := require.New(t)
 
@@ -459,7 +550,8 @@ func TestSplitByJob(t *testing.T) {
 		config := core.DefaultConfig()
 		config.TargetsFileExt = core.DefaultYAMLFileExt
 
-		got := TargetGroups{}.splitByJob(config)
+		got, err := TargetGroups{}.splitByJob(config)
+		require.NoError(err, "splitByJob returned an unexpected error")
 		// require.NotNil(got, "splitByJob returned nil TargetGroups")
 		require.Empty(got, "splitByJob returned a non-empty TargetGroups")
 	})
@@ -469,11 +561,172 @@ func TestSplitByJob(t *testing.T) {
 		config := core.DefaultConfig()
 		config.TargetsFileExt = core.DefaultYAMLFileExt
 
-		got := tgs.splitByJob(config)
+		got, err := tgs.splitByJob(config)
+		require.NoError(err, "splitByJob returned an unexpected error")
 		require.NotNil(got, "splitByJob returned nil TargetGroups")
 		require.NotEmpty(got, "splitByJob returned an empty TargetGroups")
 		require.Equal(splitTargetGroups, got, "TargetGroups did not match")
 	})
+
+	t.Run("SharedLabels", func(t *testing.T) {
+		tgs := newExpectedTargetGroups()
+		config := core.DefaultConfig()
+
+		got, err := tgs.splitByJob(config)
+		require.NoError(err, "splitByJob returned an unexpected error")
+		node := got["node-exporter_targets.json"]
+		mysql := got["mysql-exporter_targets.json"]
+		require.Len(node, 1, "wrong number of node-exporter groups")
+		require.Len(mysql, 1, "wrong number of mysql-exporter groups")
+		require.Equal("node-exporter", node[0].Labels["job"], "node-exporter job label did not match")
+		require.Equal("mysql-exporter", mysql[0].Labels["job"], "mysql-exporter job label did not match")
+		require.NotContains((*tgs)[1].Labels, "job", "source group labels were modified")
+	})
+
+	t.Run("TargetSplit", func(t *testing.T) {
+		tgs := TargetGroups{
+			&TargetGroup{
+				Jobs:    []string{"blackbox_icmp"},
+				Labels:  map[string]string{"datacenter": "atl", "application": "webapp"},
+				Targets: []Target{{Address: "atlwebapp01"}},
+			},
+			&TargetGroup{
+				Jobs:    []string{"blackbox_icmp"},
+				Labels:  map[string]string{"datacenter": "jfk", "application": "mysql"},
+				Targets: []Target{{Address: "jfkdb01"}},
+			},
+			&TargetGroup{
+				Jobs:    []string{"blackbox_icmp"},
+				Labels:  map[string]string{"datacenter": "atl", "application": "webapp"},
+				Targets: []Target{{Address: "atlwebapp02"}},
+			},
+			&TargetGroup{
+				Jobs:    []string{"blackbox_icmp"},
+				Labels:  map[string]string{"application": "dns/bind"},
+				Targets: []Target{{Address: "ns01"}},
+			},
+		}
+		config := core.DefaultConfig()
+		config.TargetSplit = []string{"datacenter", "application"}
+
+		got, err := tgs.splitByJob(config)
+		require.NoError(err, "splitByJob returned an unexpected error")
+		require.Len(got, 3, "wrong number of files")
+		atl := got["blackbox_icmp_atl_webapp_targets.json"]
+		require.Len(atl, 1, "wrong number of atl groups")
+		require.Equal([]string{"atlwebapp01", "atlwebapp02"}, atl[0].Targets, "atl targets did not match")
+		require.Len(got["blackbox_icmp_jfk_mysql_targets.json"], 1, "wrong number of jfk groups")
+		require.Len(got["blackbox_icmp_none_dns_bind_targets.json"], 1, "wrong number of none groups")
+	})
+
+	t.Run("FileNameCollision", func(t *testing.T) {
+		tests := map[string][2]map[string]string{
+			"UnsafeChars": {{"region": "us/east"}, {"region": "us_east"}},
+			"Separator":   {{"region": "us_east", "zone": "a"}, {"region": "us", "zone": "east_a"}},
+			"Missing":     {{"region": "none", "zone": "a"}, {"zone": "a"}},
+		}
+
+		for name, labels := range tests {
+			t.Run(name, func(t *testing.T) {
+				tgs := TargetGroups{
+					{Jobs: []string{"node"}, Labels: labels[0], Targets: []Target{{Address: "a"}}},
+					{Jobs: []string{"node"}, Labels: labels[1], Targets: []Target{{Address: "b"}}},
+				}
+				config := core.DefaultConfig()
+				config.TargetSplit = []string{"region", "zone"}
+
+				_, err := tgs.splitByJob(config)
+				require.ErrorIs(err, os.ErrInvalid, "splitByJob did not return an error")
+				require.ErrorContains(err, "both use the targets file", "error did not match")
+			})
+		}
+	})
+}
+
+func TestExportGroups(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Overrides", func(t *testing.T) {
+		tg := &TargetGroup{
+			Jobs:   []string{"node"},
+			Labels: map[string]string{"environment": "prod", "rack": "r01"},
+			Targets: []Target{
+				{Address: "host01"},
+				{Address: "host02", Labels: map[string]string{"rack": "r12"}},
+				{Address: "host03"},
+				{Address: "host04", Labels: map[string]string{"rack": "r12"}},
+				{Address: "host05", Labels: map[string]string{"owner": "dba"}},
+			},
+		}
+
+		want := ExportGroups{
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "prod", "rack": "r01"},
+				Targets: []string{"host01", "host03"},
+			},
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "prod", "rack": "r12"},
+				Targets: []string{"host02", "host04"},
+			},
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "environment": "prod", "rack": "r01", "owner": "dba"},
+				Targets: []string{"host05"},
+			},
+		}
+		require.Equal(want, tg.exportGroups(&jobDefaults{job: "node"}), "export groups did not match")
+		require.Equal("r01", tg.Labels["rack"], "group labels were modified")
+	})
+
+	t.Run("OnlyOverrides", func(t *testing.T) {
+		tg := &TargetGroup{
+			Jobs:    []string{"node"},
+			Targets: []Target{{Address: "host01", Labels: map[string]string{"rack": "r12"}}},
+		}
+
+		want := ExportGroups{
+			&ExportGroup{
+				Labels:  map[string]string{"job": "node", "rack": "r12"},
+				Targets: []string{"host01"},
+			},
+		}
+		require.Equal(want, tg.exportGroups(&jobDefaults{job: "node"}), "export groups did not match")
+	})
+
+	t.Run("JobLabelOverride", func(t *testing.T) {
+		tg := &TargetGroup{
+			Jobs:    []string{"node"},
+			Targets: []Target{{Address: "host01", Labels: map[string]string{"job": "other"}}},
+		}
+
+		got := tg.exportGroups(&jobDefaults{job: "node"})
+		require.Len(got, 1, "wrong number of export groups")
+		require.Equal("node", got[0].Labels["job"], "job label was overridden")
+	})
+}
+
+func TestTargetsFileName(t *testing.T) {
+	require := require.New(t)
+	labels := map[string]string{"job": "node", "datacenter": "atl", "application": "webapp"}
+
+	tests := []struct {
+		name  string
+		split []string
+		want  string
+	}{
+		{"Default", []string{}, "node_targets.json"},
+		{"JobOnly", []string{"job"}, "node_targets.json"},
+		{"ImplicitJob", []string{"datacenter"}, "node_atl_targets.json"},
+		{"JobLast", []string{"datacenter", "application", "job"}, "atl_webapp_node_targets.json"},
+		{"MissingLabel", []string{"job", "rack"}, "node_none_targets.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := core.DefaultConfig()
+			config.TargetSplit = tt.split
+			require.Equal(tt.want, targetsFileName(config, labels), "file name did not match")
+		})
+	}
 }
 
 func TestWriteTargets(t *testing.T) {
@@ -502,22 +755,63 @@ func testWriteTargets(t *testing.T, ext string) {
 	// Create sample target groups
 	filename := "blackbox_icmp" + core.DefaultTargetsFileSuffix + config.TargetsFileExt
 	targetGroups := make(TargetMap)
-	targetGroups[filename] = TargetGroups{
-		&TargetGroup{
-			Jobs:    []string{"blackbox_icmp"},
-			Labels:  map[string]string{"environment": "dev"},
+	targetGroups[filename] = ExportGroups{
+		&ExportGroup{
+			Labels:  map[string]string{"job": "blackbox_icmp", "environment": "dev"},
 			Targets: []string{"prom.example.com"},
 		},
 	}
 
 	// Write the targets
-	err = writeTargets(config, targetGroups)
+	results, err := writeTargets(config, targetGroups)
 	require.NoError(err, "failed to write targets")
+	want := ExportResults{{File: filename, Status: FileCreated, Targets: 1, Groups: 1}}
+	require.Equal(want, results, "results did not match")
 
 	// testYAMLFile(t, filepath.Join(tempDir, filename), targetGroups, filename)
 	testFile(t, tempDir, targetGroups)
 }
 
+func TestWriteTargetsStatus(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "write_targets_test")
+	require.NoError(err, "failed to create temp directory")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.TargetsDir = tempDir
+	filename := "node_targets.json"
+	files := TargetMap{
+		filename: ExportGroups{
+			&ExportGroup{Labels: map[string]string{"job": "node"}, Targets: []string{"host01"}},
+		},
+	}
+
+	want := []FileStatus{FileCreated, FileUnchanged}
+	for _, status := range want {
+		results, err := writeTargets(config, files)
+		require.NoError(err, "failed to write targets")
+		require.Equal(ExportResults{{File: filename, Status: status, Targets: 1, Groups: 1}}, results, "results did not match")
+	}
+
+	files[filename][0].Targets = append(files[filename][0].Targets, "host02")
+	results, err := writeTargets(config, files)
+	require.NoError(err, "failed to write targets")
+	require.Equal(ExportResults{{File: filename, Status: FileUpdated, Targets: 2, Groups: 1}}, results, "results did not match")
+	testFile(t, tempDir, files)
+
+	// An unchanged export must not rewrite the file.
+	info, err := os.Stat(filepath.Join(tempDir, filename))
+	require.NoError(err, "failed to stat targets file")
+	modTime := info.ModTime()
+	_, err = writeTargets(config, files)
+	require.NoError(err, "failed to write targets")
+	info, err = os.Stat(filepath.Join(tempDir, filename))
+	require.NoError(err, "failed to stat targets file")
+	require.Equal(modTime, info.ModTime(), "unchanged targets file was rewritten")
+}
+
 // Read the YAML test file and compare it to content.
 // func testYAMLFile(t *testing.T, file string, tgs TargetMap, filename string) {
 func testFile(t *testing.T, dir string, targets TargetMap) {
@@ -568,8 +862,11 @@ func testExportTargets(t *testing.T, ext string) {
 		config.TargetsFileExt = core.DefaultYAMLFileExt
 	}
 
-	expect := expectedTargetGroups.splitByJob(config)
-	err = expectedTargetGroups.ExportTargets(config)
+	expect, err := expectedTargetGroups.splitByJob(config)
+	if err != nil {
+		t.Fatalf("Failed to split targets: %v", err)
+	}
+	_, err = expectedTargetGroups.ExportTargets(config)
 	if err != nil {
 		t.Fatalf("Failed to export targets: %v", err)
 	}
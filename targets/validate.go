@@ -0,0 +1,387 @@
+package targets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// labelNameRE matches valid Prometheus label names.
+	labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// The keys allowed in a target group and in a target object.
+	groupKeys  = []string{"name", "priority", "jobs", "labels", "targets"}
+	targetKeys = []string{"address", "labels"}
+)
+
+// Problem is a single issue found in a source file. Line and Column are 0 when the problem is not
+// tied to a position in the file. Warnings do not stop the sources from being exported.
+type Problem struct {
+	File    string `json:"file" yaml:"file"`
+	Line    int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Column  int    `json:"column,omitempty" yaml:"column,omitempty"`
+	Message string `json:"message" yaml:"message"`
+	Warning bool   `json:"warning,omitempty" yaml:"warning,omitempty"`
+}
+
+// Problems lists every Problem found while validating sources.
+type Problems []Problem
+
+func (p Problem) String() string {
+	msg := p.Message
+	if p.Warning {
+		msg = "warning: " + msg
+	}
+
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, msg)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, msg)
+}
+
+// Errors returns the number of problems that are not warnings.
+func (p Problems) Errors() int {
+	count := 0
+	for _, problem := range p {
+		if !problem.Warning {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ValidateSources lints every source file found in config.Sources and returns all of the problems
+// found instead of stopping at the first one. If every file is valid, targets with conflicting
+// labels across groups are reported as well. They are warnings unless merge_precedence is error.
+// An error is only returned if no source files could be found.
+func ValidateSources(config *core.Config) (Problems, error) {
+	files, err := findFiles(config)
+	if err != nil {
+		return nil, fmt.Errorf("error finding sources file: %w", err)
+	}
+
+	problems := make(Problems, 0)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			problems = append(problems, Problem{File: f, Message: err.Error()})
+			continue
+		}
+
+		fc := fileConfig(config, f)
+		switch {
+		case IsInventory(fc, f):
+			problems = append(problems, validateInventory(fc, f, data)...)
+		case filepath.Ext(f) == csvFileExt:
+			problems = append(problems, validateCSV(fc, f, data)...)
+		case isListFile(f):
+			problems = append(problems, validateList(fc, f, data)...)
+		default:
+			problems = append(problems, validateSource(fc, f, data)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	return conflictProblems(config, files), nil
+}
+
+// conflictProblems returns a Problem for every Conflict in files. Each one is reported against
+// the file of the first group the target is in.
+func conflictProblems(config *core.Config, files []string) Problems {
+	problems := make(Problems, 0)
+	tgs, err := readSources(config, files)
+	if err != nil {
+		return problems
+	}
+
+	tgs, err = tgs.Expand()
+	if err != nil {
+		return problems
+	}
+
+	for _, c := range tgs.Conflicts(config) {
+		problems = append(problems, Problem{
+			File:    c.Groups[0].File,
+			Message: c.String(),
+			Warning: config.MergePrecedence != core.MergeError,
+		})
+	}
+
+	return problems
+}
+
+// ValidateSource lints the contents of a single source file. The file name is used to determine
+// the format and is included in each Problem.
+func ValidateSource(file string, data []byte) Problems {
+	return validateSource(nil, file, data)
+}
+
+// validateSource lints a single source file. If config is set, groups without jobs are allowed
+// when a job selector picks them up.
+func validateSource(config *core.Config, file string, data []byte) Problems {
+	v := &validator{config: config, file: file, problems: make(Problems, 0)}
+
+	switch filepath.Ext(file) {
+	case core.DefaultJSONFileExt:
+		// yaml.v3 can parse JSON and gives us positions, but report JSON syntax errors the
+		// way a JSON parser sees them.
+		var raw any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			v.jsonError(data, err)
+			return v.problems
+		}
+	case core.DefaultYAMLFileExt, ".yaml":
+	default:
+		v.add(nil, "unknown file extension")
+		return v.problems
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		v.add(nil, err.Error())
+		return v.problems
+	}
+
+	if len(doc.Content) == 0 {
+		v.add(nil, "no content found in targets source file")
+		return v.problems
+	}
+
+	v.groups(doc.Content[0])
+	return v.problems
+}
+
+// validator collects problems while walking the yaml nodes of a single source file.
+type validator struct {
+	config   *core.Config
+	file     string
+	problems Problems
+}
+
+func (v *validator) add(node *yaml.Node, format string, a ...any) {
+	p := Problem{File: v.file, Message: fmt.Sprintf(format, a...)}
+	if node != nil {
+		p.Line = node.Line
+		p.Column = node.Column
+	}
+
+	v.problems = append(v.problems, p)
+}
+
+// jsonError converts a JSON syntax error offset into a line and column.
+func (v *validator) jsonError(data []byte, err error) {
+	var serr *json.SyntaxError
+	var terr *json.UnmarshalTypeError
+	var offset int64
+	switch {
+	case errors.As(err, &serr):
+		offset = serr.Offset
+	case errors.As(err, &terr):
+		offset = terr.Offset
+	default:
+		v.add(nil, err.Error())
+		return
+	}
+
+	before := data[:min(int(offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	v.problems = append(v.problems, Problem{File: v.file, Line: line, Column: col, Message: err.Error()})
+}
+
+func (v *validator) groups(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		v.add(node, "sources must be a list of target groups")
+		return
+	}
+
+	if len(node.Content) == 0 {
+		v.add(node, "no target groups found")
+		return
+	}
+
+	for i, g := range node.Content {
+		v.group(i, g)
+	}
+}
+
+func (v *validator) group(index int, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.add(node, "group %d: must be a mapping with jobs, labels and targets", index)
+		return
+	}
+
+	var jobs, targets *yaml.Node
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "name":
+			if value.Kind != yaml.ScalarNode || value.Value == "" {
+				v.add(value, "group %d: name must be a non-empty string", index)
+			}
+		case "priority":
+			if value.Kind != yaml.ScalarNode || value.Tag != "!!int" {
+				v.add(value, "group %d: priority must be a whole number", index)
+			}
+		case "jobs":
+			jobs = value
+			v.jobs(index, value)
+		case "labels":
+			v.labels(fmt.Sprintf("group %d", index), value)
+		case "targets":
+			targets = value
+			v.targets(index, value)
+		default:
+			v.add(key, "group %d: unknown key %q; must be one of: %s", index, key.Value, strings.Join(groupKeys, ", "))
+		}
+	}
+
+	if (jobs == nil || len(jobs.Content) == 0) && !v.selected(node) {
+		v.add(node, "group %d: has no jobs", index)
+	}
+
+	if targets == nil || len(targets.Content) == 0 {
+		v.add(node, "group %d: has no targets", index)
+	}
+}
+
+func (v *validator) jobs(index int, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		v.add(node, "group %d: jobs must be a list", index)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, j := range node.Content {
+		if j.Kind != yaml.ScalarNode || j.Value == "" {
+			v.add(j, "group %d: job names must be a non-empty string", index)
+			continue
+		}
+
+		if seen[j.Value] {
+			v.add(j, "group %d: duplicate job %q", index, j.Value)
+		}
+		seen[j.Value] = true
+	}
+}
+
+// labels validates a labels mapping. where describes the owner of the labels for messages.
+func (v *validator) labels(where string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		v.add(node, "%s: labels must be a mapping of label names to values", where)
+		return
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := key.Value
+		switch {
+		case !labelNameRE.MatchString(name):
+			v.add(key, "%s: invalid label name %q", where, name)
+		case strings.HasPrefix(name, "__"):
+			v.add(key, "%s: label name %q is reserved for internal use", where, name)
+		case name == "job":
+			v.add(key, "%s: label job will be overwritten by the group jobs", where)
+		}
+
+		if value.Kind != yaml.ScalarNode {
+			v.add(value, "%s: label %q value must be a string", where, name)
+		}
+	}
+}
+
+func (v *validator) targets(index int, node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		v.add(node, "group %d: targets must be a list", index)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range node.Content {
+		addr := v.target(index, t)
+		if addr == "" {
+			continue
+		}
+
+		addrs, err := expandAddress(addr)
+		if err != nil {
+			v.add(t, "group %d: %s", index, err)
+			continue
+		}
+
+		// Patterns can overlap, so check the expanded addresses but only report each entry once.
+		for _, a := range addrs {
+			if seen[a] {
+				v.add(t, "group %d: duplicate target %q", index, a)
+				break
+			}
+		}
+
+		for _, a := range addrs {
+			seen[a] = true
+		}
+	}
+}
+
+// target validates a single target and returns its address.
+func (v *validator) target(index int, node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == "" {
+			v.add(node, "group %d: empty target", index)
+		}
+
+		return node.Value
+	case yaml.MappingNode:
+	default:
+		v.add(node, "group %d: target must be a string or a mapping with an address", index)
+		return ""
+	}
+
+	var addr string
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "address":
+			addr = value.Value
+		case "labels":
+			v.labels(fmt.Sprintf("group %d: target", index), value)
+		default:
+			v.add(key, "group %d: unknown target key %q; must be one of: %s",
+				index, key.Value, strings.Join(targetKeys, ", "))
+		}
+	}
+
+	if addr == "" {
+		v.add(node, "group %d: target is missing an address", index)
+	}
+
+	return addr
+}
+
+// selected returns true if a config job selector picks up at least one target of the group.
+func (v *validator) selected(node *yaml.Node) bool {
+	if v.config == nil {
+		return false
+	}
+
+	var tg TargetGroup
+	if err := node.Decode(&tg); err != nil {
+		return false
+	}
+
+	return len(tg.jobs(v.config, make(jobDefaultsCache))) > 0
+}
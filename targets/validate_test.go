@@ -0,0 +1,201 @@
+package targets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSource(t *testing.T) {
+	require := require.New(t)
+
+	t.Run("Valid", func(t *testing.T) {
+		require.Empty(ValidateSource("targets.yml", []byte(yamlContent)), "valid YAML had problems")
+		require.Empty(ValidateSource("targets.json", []byte(jsonContent)), "valid JSON had problems")
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		content := `- jobs:
+    - node
+    - node
+  labels:
+    environment: prod
+    9bad: value
+    __meta: value
+    job: other
+  targets:
+    - host01
+    - host01
+    - address: host02
+      labels:
+        rack: r12
+      port: 9100
+    - labels:
+        rack: r12
+  owner: dba
+- labels:
+    environment: prod
+`
+		want := Problems{
+			{File: "targets.yml", Line: 3, Column: 7, Message: `group 0: duplicate job "node"`},
+			{File: "targets.yml", Line: 6, Column: 5, Message: `group 0: invalid label name "9bad"`},
+			{File: "targets.yml", Line: 7, Column: 5, Message: `group 0: label name "__meta" is reserved for internal use`},
+			{File: "targets.yml", Line: 8, Column: 5, Message: "group 0: label job will be overwritten by the group jobs"},
+			{File: "targets.yml", Line: 11, Column: 7, Message: `group 0: duplicate target "host01"`},
+			{File: "targets.yml", Line: 15, Column: 7, Message: `group 0: unknown target key "port"; must be one of: address, labels`},
+			{File: "targets.yml", Line: 16, Column: 7, Message: "group 0: target is missing an address"},
+			{File: "targets.yml", Line: 18, Column: 3, Message: `group 0: unknown key "owner"; must be one of: name, priority, jobs, labels, targets`},
+			{File: "targets.yml", Line: 19, Column: 3, Message: "group 1: has no jobs"},
+			{File: "targets.yml", Line: 19, Column: 3, Message: "group 1: has no targets"},
+		}
+		require.Equal(want, ValidateSource("targets.yml", []byte(content)), "problems did not match")
+	})
+
+	t.Run("Patterns", func(t *testing.T) {
+		content := `- jobs:
+    - node
+  targets:
+    - web[01:10]
+    - web05
+    - web[01:10
+    - 10.1.2.0/8
+    - "{atl,jfk}db01"
+`
+		want := Problems{
+			{File: "targets.yml", Line: 5, Column: 7, Message: `group 0: duplicate target "web05"`},
+			{File: "targets.yml", Line: 6, Column: 7, Message: `group 0: target "web[01:10": unclosed range`},
+			{File: "targets.yml", Line: 7, Column: 7, Message: `group 0: target "10.1.2.0/8" expands to more than 4096 addresses`},
+		}
+		require.Equal(want, ValidateSource("targets.yml", []byte(content)), "problems did not match")
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		content := `- jobs:
+    - node
+  priority: high
+  targets:
+    - host01
+- jobs:
+    - node
+  priority: 10
+  targets:
+    - host01
+`
+		want := Problems{
+			{File: "targets.yml", Line: 3, Column: 13, Message: "group 0: priority must be a whole number"},
+		}
+		require.Equal(want, ValidateSource("targets.yml", []byte(content)), "problems did not match")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		content := `[
+  {
+    "jobs": ["node"],
+    "labels": {"bad-name": "value"},
+    "targets": ["host01"]
+  }
+]`
+		want := Problems{
+			{File: "targets.json", Line: 4, Column: 16, Message: `group 0: invalid label name "bad-name"`},
+		}
+		require.Equal(want, ValidateSource("targets.json", []byte(content)), "problems did not match")
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		content := "[\n  {\n    \"jobs\": [\"node\"],\n  }\n]"
+		got := ValidateSource("targets.json", []byte(content))
+		require.Len(got, 1, "wrong number of problems")
+		require.Equal(4, got[0].Line, "line did not match")
+	})
+
+	t.Run("InvalidYAML", func(t *testing.T) {
+		got := ValidateSource("targets.yml", []byte("- jobs: [unclosed"))
+		require.Len(got, 1, "wrong number of problems")
+	})
+
+	t.Run("NotAList", func(t *testing.T) {
+		got := ValidateSource("targets.yml", []byte("jobs:\n  - node\n"))
+		require.Equal(Problems{{File: "targets.yml", Line: 1, Column: 1, Message: "sources must be a list of target groups"}}, got)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		got := ValidateSource("targets.yml", []byte(""))
+		require.Equal(Problems{{File: "targets.yml", Message: "no content found in targets source file"}}, got)
+	})
+
+	t.Run("UnknownExtension", func(t *testing.T) {
+		got := ValidateSource("targets.txt", []byte("host01"))
+		require.Len(got, 1, "wrong number of problems")
+	})
+}
+
+func TestValidateSources(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "validate_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+
+	t.Run("NoFiles", func(t *testing.T) {
+		_, err := ValidateSources(config)
+		require.ErrorIs(err, os.ErrNotExist, "ValidateSources did not return the correct error")
+	})
+
+	// Every file should be checked even if an earlier file is invalid.
+	files := map[string]string{
+		"a_targets.yml": "- jobs: [unclosed",
+		"b_targets.yml": "- jobs: [node]\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644)
+		require.NoError(err, "failed to write %s", name)
+	}
+
+	t.Run("AllFiles", func(t *testing.T) {
+		got, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Len(got, 2, "wrong number of problems")
+		require.Equal(filepath.Join(tempDir, "a_targets.yml"), got[0].File, "first problem file did not match")
+		require.Equal(filepath.Join(tempDir, "b_targets.yml"), got[1].File, "second problem file did not match")
+		require.Equal("group 0: has no targets", got[1].Message, "second problem did not match")
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		files := map[string]string{
+			"a_targets.yml": "- jobs: [node]\n  labels:\n    environment: prod\n  targets: [host01]\n",
+			"b_targets.yml": "- jobs: [node]\n  labels:\n    environment: stg\n  targets: [host01]\n",
+		}
+		for name, content := range files {
+			err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644)
+			require.NoError(err, "failed to write %s", name)
+		}
+
+		got, err := ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.Len(got, 1, "wrong number of problems")
+		require.Equal(filepath.Join(tempDir, "a_targets.yml"), got[0].File, "problem file did not match")
+		require.Contains(got[0].Message, `job node target "host01" has conflicting labels environment`)
+		require.True(got[0].Warning, "conflict was not a warning")
+		require.Zero(got.Errors(), "conflict was counted as an error")
+
+		config.MergePrecedence = core.MergeError
+		defer func() { config.MergePrecedence = core.DefaultMergePrecedence }()
+		got, err = ValidateSources(config)
+		require.NoError(err, "ValidateSources returned an unexpected error")
+		require.False(got[0].Warning, "conflict was a warning with merge_precedence error")
+		require.Equal(1, got.Errors(), "conflict was not counted as an error")
+	})
+}
+
+func TestProblemString(t *testing.T) {
+	require := require.New(t)
+	require.Equal("a.yml: bad", Problem{File: "a.yml", Message: "bad"}.String())
+	require.Equal("a.yml:2:3: bad", Problem{File: "a.yml", Line: 2, Column: 3, Message: "bad"}.String())
+	require.Equal("a.yml: warning: odd", Problem{File: "a.yml", Message: "odd", Warning: true}.String())
+}
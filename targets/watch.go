@@ -0,0 +1,141 @@
+package targets
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+)
+
+// fileState is what the Watcher compares between polls to decide if a source file changed.
+type fileState struct {
+	modTime int64
+	size    int64
+}
+
+// DefaultRemoteInterval is the least time between fetches of remote sources by a Watcher.
+const DefaultRemoteInterval = time.Minute
+
+// Watcher polls config.Sources for changes. Polling is used instead of inotify so it works the
+// same on every platform and on network filesystems. Files added to a sources dir are picked up
+// on the next poll. Remote sources are only fetched every RemoteInterval; polls in between check
+// their cached copy.
+type Watcher struct {
+	// How often to check the sources for changes.
+	Interval time.Duration
+	// How often to fetch remote sources.
+	RemoteInterval time.Duration
+
+	config    *core.Config
+	snapshot  map[string]fileState
+	lastFetch time.Time
+}
+
+// NewWatcher creates a Watcher for config.Sources using config.WatchInterval and takes the
+// initial snapshot of the sources.
+func NewWatcher(config *core.Config) *Watcher {
+	interval := config.WatchInterval
+	if interval < 1 {
+		interval = core.DefaultWatchInterval
+	}
+
+	w := &Watcher{
+		Interval:       time.Duration(interval) * time.Second,
+		RemoteInterval: max(time.Duration(interval)*time.Second, DefaultRemoteInterval),
+		config:         config,
+		// The sources were just loaded, so the first snapshot uses the cached remote sources.
+		lastFetch: time.Now(),
+	}
+	w.snapshot = w.scan()
+	return w
+}
+
+// Watch polls the sources until ctx is done and calls onChange after they change. Bursts of
+// edits are debounced by waiting until a poll finds no further changes before calling onChange.
+func (w *Watcher) Watch(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		switch {
+		case w.changed():
+			// Wait for the sources to settle before calling onChange.
+			pending = true
+		case pending:
+			pending = false
+			onChange()
+		}
+	}
+}
+
+// changed takes a new snapshot of the sources and returns true if it differs from the last one.
+func (w *Watcher) changed() bool {
+	snapshot := w.scan()
+	if maps.Equal(w.snapshot, snapshot) {
+		return false
+	}
+
+	w.snapshot = snapshot
+	return true
+}
+
+// scan records the state of every source file. Missing files are left out so removing a file or
+// the whole sources dir is seen as a change. Remote sources are fetched if RemoteInterval has
+// passed since the last fetch, otherwise the state of their cached copy is recorded.
+func (w *Watcher) scan() map[string]fileState {
+	snapshot := make(map[string]fileState)
+	fetch := time.Since(w.lastFetch) >= w.RemoteInterval
+	files, err := findFilesWith(w.config, func(config *core.Config) ([]string, error) {
+		if !IsRemote(config.Sources) {
+			return findSourceFiles(config)
+		}
+
+		// A failed fetch is left for the export to report. Without a cached copy the remote
+		// source has no files so the local sources are still watched.
+		if fetch {
+			if files, err := findSourceFiles(config); err == nil {
+				return files, nil
+			}
+		}
+
+		if file := cachedRemote(config); file != "" {
+			return []string{file}, nil
+		}
+
+		return []string{}, nil
+	})
+	if fetch {
+		w.lastFetch = time.Now()
+	}
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return snapshot
+	}
+
+	for _, f := range files {
+		if s := sidecarFile(f); s != "" {
+			files = append(files, s)
+		}
+	}
+
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+
+		snapshot[f] = fileState{modTime: info.ModTime().UnixNano(), size: info.Size()}
+	}
+
+	return snapshot
+}
@@ -0,0 +1,137 @@
+package targets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chadeldridge/prometheus-import-manager/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherChanged(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "watch_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	a := filepath.Join(tempDir, "a_targets.yml")
+	err = os.WriteFile(a, []byte(yamlContent), 0o644)
+	require.NoError(err, "failed to write %s", a)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+	w := NewWatcher(config)
+	require.Equal(time.Duration(core.DefaultWatchInterval)*time.Second, w.Interval, "interval did not match")
+	require.False(w.changed(), "unchanged sources were reported as changed")
+
+	t.Run("Modified", func(t *testing.T) {
+		err := os.WriteFile(a, []byte(yamlContent+"\n"), 0o644)
+		require.NoError(err, "failed to write %s", a)
+		require.True(w.changed(), "modified file was not reported")
+		require.False(w.changed(), "change was reported twice")
+	})
+
+	t.Run("Added", func(t *testing.T) {
+		b := filepath.Join(tempDir, "b_targets.yml")
+		err := os.WriteFile(b, []byte(yamlContent), 0o644)
+		require.NoError(err, "failed to write %s", b)
+		require.True(w.changed(), "added file was not reported")
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		err := os.Remove(a)
+		require.NoError(err, "failed to remove %s", a)
+		require.True(w.changed(), "removed file was not reported")
+	})
+}
+
+func TestWatcherRemote(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "watch_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	var body atomic.Value
+	var requests atomic.Int32
+	body.Store(testRemoteSources)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+
+	config := core.DefaultConfig()
+	config.Sources = srv.URL + "/sources"
+	config.SourcesCacheDir = filepath.Join(tempDir, "cache")
+	_, err = NewTargetGroups(config)
+	require.NoError(err, "NewTargetGroups returned an unexpected error")
+	require.Equal(int32(1), requests.Load(), "wrong number of requests")
+
+	w := NewWatcher(config)
+	require.Equal(DefaultRemoteInterval, w.RemoteInterval, "remote interval did not match")
+	require.Len(w.snapshot, 1, "cached sources were not in the snapshot")
+
+	t.Run("Cached", func(t *testing.T) {
+		body.Store(testRemoteSources + "    - atlweb03\n")
+		for range 3 {
+			require.False(w.changed(), "cached sources were reported as changed")
+		}
+		require.Equal(int32(1), requests.Load(), "remote sources were fetched before RemoteInterval")
+	})
+
+	t.Run("Fetch", func(t *testing.T) {
+		w.lastFetch = time.Now().Add(-w.RemoteInterval)
+		require.True(w.changed(), "changed remote sources were not reported")
+		require.Equal(int32(2), requests.Load(), "remote sources were not fetched")
+		require.False(w.changed(), "change was reported twice")
+		require.Equal(int32(2), requests.Load(), "remote sources were fetched twice")
+	})
+}
+
+func TestWatcherWatch(t *testing.T) {
+	require := require.New(t)
+
+	tempDir, err := os.MkdirTemp("", "watch_test")
+	require.NoError(err, "failed to create temp dir")
+	defer os.RemoveAll(tempDir)
+
+	config := core.DefaultConfig()
+	config.Sources = tempDir
+	w := NewWatcher(config)
+	w.Interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan struct{}, 10)
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx, func() { calls <- struct{}{} })
+		close(done)
+	}()
+
+	// Several quick edits should only call onChange once the sources settle.
+	for i := range 3 {
+		f := filepath.Join(tempDir, "targets.yml")
+		err := os.WriteFile(f, []byte(yamlContent+strings.Repeat("\n", i)), 0o644)
+		require.NoError(err, "failed to write %s", f)
+	}
+
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		require.Fail("onChange was not called")
+	}
+
+	cancel()
+	<-done
+	require.Empty(calls, "onChange was called more than once")
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/chadeldridge/prometheus-import-manager/router"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
 )
 
 func handleIndex(server *router.HTTPServer) http.Handler {
@@ -18,6 +19,18 @@ func handleIndex(server *router.HTTPServer) http.Handler {
 		})
 }
 
+// handleSD serves the target groups for a job in the format Prometheus http_sd_configs expects.
+func handleSD(server *router.HTTPServer, store *targets.Store) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			egs := store.Get(r.PathValue("job"))
+			err := router.RenderJSON(w, http.StatusOK, egs)
+			if err != nil {
+				server.Logger.Printf("%s %s: %s\n", r.Method, r.RequestURI, err)
+			}
+		})
+}
+
 /*
 type ErrorHandler func(error) templ.Component
 
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/chadeldridge/prometheus-import-manager/router"
+	"github.com/chadeldridge/prometheus-import-manager/targets"
 )
 
 func AddRoutes(server *router.HTTPServer) error {
@@ -21,14 +22,35 @@ func AddRoutes(server *router.HTTPServer) error {
 	// Handle static assets
 	server.Mux.Handle(
 		"/sources/",
-		http.StripPrefix("/sources/", http.FileServer(http.Dir(server.Config.Sources))),
+		router.Instrument(
+			"/sources/",
+			http.StripPrefix("/sources/", http.FileServer(http.Dir(server.Config.Sources))),
+		),
 	)
 	server.Mux.Handle(
 		"/targets/",
-		http.StripPrefix("/targets/", http.FileServer(http.Dir(server.Config.TargetsDir))),
+		router.Instrument(
+			"/targets/",
+			http.StripPrefix("/targets/", http.FileServer(http.Dir(server.Config.TargetsDir))),
+		),
 	)
 	// root.GET("/index.html", handleIndex(server), mwAuth)
 	root.GET("/index.html", handleIndex(server))
+	root.GET("/metrics", router.HandleMetrics())
+
+	return nil
+}
+
+// AddSDRoutes adds the http_sd endpoint which serves the target groups held in store.
+func AddSDRoutes(server *router.HTTPServer, store *targets.Store) error {
+	mwLogger := router.LoggerMiddleware(server.Logger)
+	root, err := router.NewRouterGroup(server.Mux, "/", mwLogger)
+	if err != nil {
+		return err
+	}
+
+	server.Logger.Debug("adding http_sd routes")
+	root.GET("/sd/{job}", handleSD(server, store))
 
 	return nil
 }